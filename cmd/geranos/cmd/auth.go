@@ -6,6 +6,7 @@ import (
 	"github.com/docker/cli/cli/config/types"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/macvmio/geranos/pkg/transporter"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 	"log"
@@ -34,6 +35,10 @@ func NewCmdAuthLogin() *cobra.Command {
 			}
 
 			opts.serverAddress = reg.Name()
+			opts.checkOptions = []transporter.Option{
+				transporter.WithContext(cmd.Context()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+			}
 
 			return login(opts)
 		},
@@ -53,6 +58,7 @@ type loginOptions struct {
 	user          string
 	password      string
 	passwordStdin bool
+	checkOptions  []transporter.Option
 }
 
 func login(opts loginOptions) error {
@@ -68,6 +74,9 @@ func login(opts loginOptions) error {
 	if opts.user == "" || opts.password == "" {
 		return errors.New("username and password required")
 	}
+	if err := transporter.CheckCredentials(opts.serverAddress, opts.user, opts.password, opts.checkOptions...); err != nil {
+		return err
+	}
 	cf, err := config.Load(os.Getenv("DOCKER_CONFIG"))
 	if err != nil {
 		return err
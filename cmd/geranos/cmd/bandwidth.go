@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/macvmio/geranos/pkg/throttle"
+)
+
+func parseRate(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return parseByteSize(s)
+}
+
+// bandwidthSchedule builds schedule from the bandwidth section of the config.
+// A non-empty flagLimitRate replaces it with a constant rate.
+func bandwidthSchedule(flagLimitRate string) (*throttle.Schedule, error) {
+	if flagLimitRate != "" {
+		rate, err := parseRate(flagLimitRate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --limit-rate: %w", err)
+		}
+		return throttle.Constant(rate), nil
+	}
+	cfg := TheAppConfig.Bandwidth
+	rate, err := parseRate(cfg.LimitRate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bandwidth limit_rate: %w", err)
+	}
+	schedule := &throttle.Schedule{Rate: rate}
+	for _, w := range cfg.Schedule {
+		from, err := throttle.ParseClock(w.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bandwidth schedule: %w", err)
+		}
+		to, err := throttle.ParseClock(w.To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bandwidth schedule: %w", err)
+		}
+		rate, err := parseRate(w.LimitRate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bandwidth schedule: %w", err)
+		}
+		schedule.Windows = append(schedule.Windows, throttle.Window{From: from, To: to, Rate: rate})
+	}
+	return schedule, nil
+}
+
+// bandwidthLimiter returns limiter shared by all transfers of the command, or nil if bandwidth is unlimited.
+func bandwidthLimiter(flagLimitRate string) (*throttle.Limiter, error) {
+	schedule, err := bandwidthSchedule(flagLimitRate)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.IsUnlimited() {
+		return nil, nil
+	}
+	return throttle.NewLimiter(schedule), nil
+}
+
+// diskLimiter returns limiter of local disk I/O shared by all workers of the command, or nil if it is unlimited.
+func diskLimiter() (*throttle.Limiter, error) {
+	rate, err := parseRate(TheAppConfig.DiskIO.LimitRate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid disk I/O limit: %w", err)
+	}
+	if rate <= 0 {
+		return nil, nil
+	}
+	return throttle.NewLimiter(throttle.Constant(rate)), nil
+}
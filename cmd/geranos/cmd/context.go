@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/macvmio/geranos/pkg/appconfig"
+	"github.com/macvmio/geranos/pkg/keychain"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -16,14 +18,39 @@ func NewCmdContext() *cobra.Command {
 	var contextSetCmd = &cobra.Command{
 		Use:   "set [name] --registry=REGISTRY --user=USER --password=PASSWORD",
 		Short: "Set a new context or modify an existing one",
-		Args:  cobra.ExactArgs(1),
+		Long: `Sets a new context or modifies an existing one. The password is not written to the config file,
+it is kept by a Docker credential helper: the one given by --credentials-store, the 'credsStore' of the Docker config,
+or the helper of the OS keychain, e.g. docker-credential-osxkeychain on macOS.`,
+		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			name := args[0]
 			registry, _ := cmd.Flags().GetString("registry")
 			user, _ := cmd.Flags().GetString("user")
 			password, _ := cmd.Flags().GetString("password")
 
-			newContext := appconfig.Context{Name: name, Registry: registry, User: user, Password: password}
+			newContext := appconfig.Context{Name: name, Registry: registry, User: user}
+			newContext.CredentialsStore, _ = cmd.Flags().GetString("credentials-store")
+			if password != "" {
+				store := newContext.SecretStore()
+				if store == nil {
+					fmt.Println("Error storing password: no credential helper found, install docker-credential-osxkeychain or set --credentials-store")
+					return
+				}
+				if err := store.Store(newContext.ServerURL(), user, password); err != nil {
+					fmt.Println("Error storing password:", err)
+					return
+				}
+				fmt.Printf("Password stored in %s.\n", store.Name())
+			}
+			newContext.PlainHTTP, _ = cmd.Flags().GetBool("plain-http")
+			newContext.CAFile, _ = cmd.Flags().GetString("ca-file")
+			newContext.ClientCertFile, _ = cmd.Flags().GetString("client-cert")
+			newContext.ClientKeyFile, _ = cmd.Flags().GetString("client-key")
+			newContext.InsecureSkipVerify, _ = cmd.Flags().GetBool("insecure-skip-verify")
+			newContext.Proxy, _ = cmd.Flags().GetString("proxy")
+			newContext.DialTimeout, _ = cmd.Flags().GetDuration("dial-timeout")
+			newContext.ResponseTimeout, _ = cmd.Flags().GetDuration("response-timeout")
+			newContext.MaxIdleConns, _ = cmd.Flags().GetInt("max-idle-conns")
 
 			// Add or update context
 			found := false
@@ -48,7 +75,17 @@ func NewCmdContext() *cobra.Command {
 
 	contextSetCmd.Flags().String("registry", "", "Registry URL")
 	contextSetCmd.Flags().String("user", "", "Registry username")
-	contextSetCmd.Flags().String("password", "", "Registry password")
+	contextSetCmd.Flags().String("password", "", "Registry password, kept by a credential helper")
+	contextSetCmd.Flags().String("credentials-store", "", "Docker credential helper keeping the password, e.g. osxkeychain, pass or secretservice")
+	contextSetCmd.Flags().Bool("plain-http", false, "Access registry over plain HTTP")
+	contextSetCmd.Flags().String("ca-file", "", "PEM bundle of additional trusted CA certificates")
+	contextSetCmd.Flags().String("client-cert", "", "PEM client certificate for mutual TLS")
+	contextSetCmd.Flags().String("client-key", "", "PEM client key for mutual TLS")
+	contextSetCmd.Flags().Bool("insecure-skip-verify", false, "Skip verification of registry certificate (development only)")
+	contextSetCmd.Flags().String("proxy", "", "HTTP proxy URL")
+	contextSetCmd.Flags().Duration("dial-timeout", 0, "Timeout of establishing a connection")
+	contextSetCmd.Flags().Duration("response-timeout", 0, "Timeout of waiting for response headers")
+	contextSetCmd.Flags().Int("max-idle-conns", 0, "Maximum number of idle connections")
 
 	var contextUnsetCmd = &cobra.Command{
 		Use:   "unset",
@@ -109,6 +146,11 @@ func NewCmdContext() *cobra.Command {
 				if ctx.Name == TheAppConfig.CurrentContext {
 					fmt.Printf("Current context: %s\nRegistry: %s\nUser: %s\n",
 						ctx.Name, ctx.Registry, ctx.User)
+					if ctx.Password != "" {
+						fmt.Println("Password: in config file, run 'context set' again to move it to a credential helper")
+					} else if store := ctx.SecretStore(); ctx.User != "" && store != nil {
+						fmt.Printf("Password: %s\n", store.Name())
+					}
 					return
 				}
 			}
@@ -144,6 +186,7 @@ func NewCmdContext() *cobra.Command {
 					newContexts = append(newContexts, ctx)
 				} else {
 					found = true
+					eraseContextSecret(ctx)
 				}
 			}
 
@@ -172,3 +215,18 @@ func NewCmdContext() *cobra.Command {
 
 	return contextCmd
 }
+
+// eraseContextSecret removes the password of the deleted context from its credential helper. Only the entry
+// written by 'context set' is erased, credentials of 'docker login' for the registry are kept.
+func eraseContextSecret(deleted appconfig.Context) {
+	if deleted.User == "" || deleted.Password != "" {
+		return
+	}
+	store := deleted.SecretStore()
+	if store == nil {
+		return
+	}
+	if err := store.Erase(deleted.ServerURL()); err != nil && !errors.Is(err, keychain.ErrNotFound) {
+		fmt.Println("Error erasing password:", err)
+	}
+}
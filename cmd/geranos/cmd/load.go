@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdLoad() *cobra.Command {
+	var loadCmd = &cobra.Command{
+		Use:   "load [path] [image name]",
+		Short: "Load an image from an OCI image layout written by save.",
+		Long: `Reads the image from an OCI image layout, a tar archive or a directory, and writes it as pull does,
+cloning matching local files first. The image is stored under the name it was saved with, unless another one is given.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dst := ""
+			if len(args) > 1 {
+				dst = TheAppConfig.Override(args[1])
+			}
+			ioLimiter, err := diskLimiter()
+			if err != nil {
+				return err
+			}
+			progress := make(chan transporter.ProgressUpdate)
+			opts := []transporter.Option{
+				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+				transporter.WithContext(cmd.Context()),
+				transporter.WithVerbose(TheAppConfig.Verbose),
+				transporter.WithProgressChannel(progress),
+				transporter.WithDiskLimiter(ioLimiter),
+			}
+			progressDone := make(chan struct{})
+			go func() {
+				transporter.PrintProgress(progress)
+				close(progressDone)
+			}()
+			ref, err := transporter.Load(args[0], dst, opts...)
+			close(progress)
+			<-progressDone
+			if err != nil {
+				return err
+			}
+			fmt.Printf("loaded %v\n", ref)
+			return nil
+		},
+	}
+
+	return loadCmd
+}
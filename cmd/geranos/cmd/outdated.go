@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdOutdated() *cobra.Command {
+	var outdatedCmd = &cobra.Command{
+		Use:   "outdated",
+		Short: "List local images whose tags point to other manifests remotely.",
+		Long: `Compares manifest digest of each local image pulled from a registry with what its tag resolves to remotely.
+Only HEAD requests are made, nothing is downloaded. Clones and adopted images are skipped.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statuses, err := transporter.CheckUpdates(nil,
+				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+				transporter.WithContext(cmd.Context()),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()))
+			if err != nil {
+				return err
+			}
+			printImageStatuses(statuses, false)
+			return nil
+		},
+	}
+
+	return outdatedCmd
+}
+
+func shortDigest(digest string) string {
+	if len(digest) > 19 {
+		return digest[:19]
+	}
+	return digest
+}
+
+// printImageStatuses prints outdated images and images which could not be checked, or all images if all is set.
+func printImageStatuses(statuses []transporter.ImageStatus, all bool) {
+	outdated := 0
+	for _, s := range statuses {
+		switch {
+		case s.Err != nil:
+			fmt.Printf("warning: %v\n", s.Err)
+		case s.Outdated:
+			outdated++
+			fmt.Printf("%v: %v -> %v\n", s.Reference, shortDigest(s.LocalDigest), shortDigest(s.RemoteDigest))
+		case all:
+			fmt.Printf("%v is up to date\n", s.Reference)
+		}
+	}
+	if outdated == 0 && !all {
+		fmt.Println("all images are up to date")
+	}
+}
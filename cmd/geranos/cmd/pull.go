@@ -1,31 +1,140 @@
 package cmd
 
 import (
+	"fmt"
+	"github.com/macvmio/geranos/pkg/layout"
 	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/macvmio/geranos/pkg/variant"
 	"github.com/spf13/cobra"
 )
 
 func NewCmdPull() *cobra.Command {
+	var (
+		flagDryRun    bool
+		flagLimitRate string
+		flagVariant   string
+		flagFormat    string
+		flagRehash    bool
+	)
+
 	var pullCmd = &cobra.Command{
 		Use:   "pull [image name]",
 		Short: "Pull an OCI image from a registry and extract the file.",
-		Long:  `Downloads an OCI image from a specified container registry and extracts the file to a specified local path.`,
-		Args:  cobra.ExactArgs(1),
+		Long: `Downloads an OCI image from a specified container registry and extracts the file to a specified local path.
+With --dry-run only manifest and config are downloaded, and the plan of cloning local files and downloading segments is printed.
+If the reference points to an image index, the first variant matching this host is pulled, unless --variant selects one.
+With --format tart images pushed by Tart are imported as config.json, disk.img and nvram.bin, and --rehash hashes them
+into geranos segments right away, so the image can be pushed and cloned like a native one.
+Other OCI artifacts are written as files named by their title annotations, and their tar layers are extracted.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			src := TheAppConfig.Override(args[0])
+			selector, err := variant.Parse(flagVariant)
+			if err != nil {
+				return err
+			}
+			if err := transporter.ValidateFormat(flagFormat); err != nil {
+				return err
+			}
+			if flagFormat == transporter.FormatTart && flagDryRun {
+				return fmt.Errorf("--dry-run is not supported with --format %v", transporter.FormatTart)
+			}
+			if flagDryRun {
+				plan, err := transporter.PlanPull(src,
+					transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+					transporter.WithContext(cmd.Context()),
+					transporter.WithPullPolicy(&TheAppConfig.Policy),
+					transporter.WithKeychain(TheAppConfig.Keychain()),
+					transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+					transporter.WithVariant(selector))
+				if err != nil {
+					return err
+				}
+				printWritePlan(plan)
+				return nil
+			}
+			limiter, err := bandwidthLimiter(flagLimitRate)
+			if err != nil {
+				return err
+			}
+			ioLimiter, err := diskLimiter()
+			if err != nil {
+				return err
+			}
 			progress := make(chan transporter.ProgressUpdate)
-			defer close(progress)
+			stats := &transporter.PullStatistics{}
 
 			opts := []transporter.Option{
 				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
 				transporter.WithContext(cmd.Context()),
 				transporter.WithVerbose(TheAppConfig.Verbose),
 				transporter.WithProgressChannel(progress),
+				transporter.WithPullPolicy(&TheAppConfig.Policy),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+				transporter.WithPullStatistics(stats),
+				transporter.WithBandwidthLimiter(limiter),
+				transporter.WithDiskLimiter(ioLimiter),
+				transporter.WithVariant(selector),
+				transporter.WithFormat(flagFormat),
+				transporter.WithRehash(flagRehash),
+			}
+			progressDone := make(chan struct{})
+			go func() {
+				transporter.PrintProgress(progress)
+				close(progressDone)
+			}()
+			err = transporter.Pull(src, opts...)
+			close(progress)
+			<-progressDone
+			if err != nil {
+				return err
 			}
-			go transporter.PrintProgress(progress)
-			return transporter.Pull(src, opts...)
+			printPullStatistics(stats)
+			return nil
 		},
 	}
 
+	pullCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the transfer plan without modifying anything")
+
+	pullCmd.Flags().StringVar(&flagVariant, "variant", "", "Select variant of an image index with given properties, e.g. os.version=14,cpu=M2")
+
+	pullCmd.Flags().StringVar(&flagFormat, "format", transporter.FormatGeranos, "Format of the image: geranos or tart")
+
+	pullCmd.Flags().BoolVar(&flagRehash, "rehash", false, "Hash files imported with --format tart into geranos segments")
+
+	pullCmd.Flags().StringVar(&flagLimitRate, "limit-rate", "", "Limit bandwidth of registry traffic, e.g. 20M per second; overrides bandwidth section of the config, 0 means unlimited")
+
 	return pullCmd
 }
+
+func printWritePlan(plan *layout.WritePlan) {
+	if plan.UpToDate {
+		fmt.Printf("%v is up to date, nothing to download\n", plan.Reference)
+		return
+	}
+	fmt.Printf("%-30s %-10s %-12s %-12s %s\n", "FILE", "SEGMENTS", "MATCHING", "DOWNLOAD", "SOURCE")
+	for _, f := range plan.Files {
+		source := "-"
+		switch {
+		case f.Exists:
+			source = "existing file"
+		case f.CloneSource != "":
+			source = "clone of " + f.CloneSource
+		}
+		fmt.Printf("%-30s %-10d %-12d %-12s %s\n", f.Filename, len(f.Segments), f.MatchingSegments,
+			formatByteSize(f.CompressedBytesToDownload), source)
+	}
+	fmt.Printf("\nwould download %s (%s uncompressed) to '%v'\n",
+		formatByteSize(plan.CompressedBytesToDownload), formatByteSize(plan.BytesToDownload), plan.Directory)
+}
+
+func printPullStatistics(stats *transporter.PullStatistics) {
+	for _, e := range stats.Endpoints() {
+		if e.Failures > 0 {
+			fmt.Printf("downloaded %s from %s (%d failed requests)\n", formatByteSize(e.Bytes), e.Endpoint, e.Failures)
+			continue
+		}
+		fmt.Printf("downloaded %s from %s\n", formatByteSize(e.Bytes), e.Endpoint)
+	}
+}
@@ -3,27 +3,101 @@ package cmd
 import (
 	"fmt"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/macvmio/geranos/pkg/dirimage"
 	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/macvmio/geranos/pkg/variant"
 	"github.com/spf13/cobra"
+	"time"
 )
 
+// manifestSizeLimit returns limit of manifest size from the flag, the config or the default.
+func manifestSizeLimit(flagMaxManifestSize string) (int64, error) {
+	value := flagMaxManifestSize
+	if value == "" {
+		value = TheAppConfig.MaxManifestSize
+	}
+	if value == "" {
+		return dirimage.DefaultMaxManifestSize, nil
+	}
+	size, err := parseByteSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max manifest size: %w", err)
+	}
+	return size, nil
+}
+
 func NewCmdPush() *cobra.Command {
 	var (
 		flagMountedReference  string // Declares a variable to hold the value of the "--mountable-image" flag.
 		flagConcurrentWorkers int
+		flagLimitRate         string
+		flagIndex             string
+		flagVariant           string
+		flagMaxManifestSize   string
+		flagArtifact          bool
+		flagManifestFormat    string
 	)
 
 	var pushCmd = &cobra.Command{
 		Use:   "push [image name]",
 		Short: "Push a large file as an OCI image to a registry.",
-		Long:  `Uploads a specified file from the local system and packages it as an OCI image to be pushed to a specified container registry.`,
-		Args:  cobra.ExactArgs(1),
+		Long: `Uploads a specified file from the local system and packages it as an OCI image to be pushed to a specified container registry.
+With --index the image is also added to the image index with given reference as a variant described by --variant,
+replacing a variant with the same properties. The index must be in the same repository and is created if missing.
+Images whose manifest exceeds --max-manifest-size are pushed as several manifests under an index, pull joins them back.
+With --artifact files can also be fetched with artifact tools like oras, files of a single segment are stored uncompressed.
+Registries rejecting OCI manifests get Docker v2 schema 2 manifests instead, unless --manifest-format says otherwise.`,
+		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			src := TheAppConfig.Override(args[0])
+			properties, err := variant.Parse(flagVariant)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if len(properties) > 0 && flagIndex == "" {
+				fmt.Println("--variant requires --index")
+				return
+			}
+			if err := transporter.ValidateManifestFormat(flagManifestFormat); err != nil {
+				fmt.Println(err)
+				return
+			}
+			maxManifestSize, err := manifestSizeLimit(flagMaxManifestSize)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			limiter, err := bandwidthLimiter(flagLimitRate)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			ioLimiter, err := diskLimiter()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			progress := make(chan transporter.ProgressUpdate)
+			stats := &transporter.PushStatistics{}
 			opts := []transporter.Option{
 				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
 				transporter.WithContext(cmd.Context()),
+				transporter.WithProgressChannel(progress),
+				transporter.WithPushStatistics(stats),
 				transporter.WithWorkersCount(flagConcurrentWorkers),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+				transporter.WithBandwidthLimiter(limiter),
+				transporter.WithDiskLimiter(ioLimiter),
+				transporter.WithMaxManifestSize(maxManifestSize),
+				transporter.WithManifestFormat(flagManifestFormat),
+			}
+			if flagArtifact {
+				opts = append(opts, transporter.WithArtifactMode())
+			}
+			if flagIndex != "" {
+				opts = append(opts, transporter.WithIndex(TheAppConfig.Override(flagIndex)), transporter.WithVariant(properties))
 			}
 
 			// Since mountedReference is directly bound to the flag,
@@ -37,10 +111,18 @@ func NewCmdPush() *cobra.Command {
 				opts = append(opts, transporter.WithMountedReference(ref))
 			}
 
-			err := transporter.Push(src, opts...)
+			progressDone := make(chan struct{})
+			go func() {
+				transporter.PrintProgress(progress)
+				close(progressDone)
+			}()
+			err = transporter.Push(src, opts...)
+			close(progress)
+			<-progressDone
 			if err != nil {
 				fmt.Println(err)
 			} else {
+				printPushSummary(stats.Summary())
 				fmt.Println("push has completed successfully")
 			}
 		},
@@ -53,5 +135,25 @@ func NewCmdPush() *cobra.Command {
 	pushCmd.Flags().IntVar(&flagConcurrentWorkers, "concurrent-workers", 8,
 		"Specifies number of concurrent workers to use when uploading layers to a registry")
 
+	pushCmd.Flags().StringVar(&flagIndex, "index", "", "Add the image to the image index with given reference, e.g. the tag shared by all variants")
+
+	pushCmd.Flags().StringVar(&flagVariant, "variant", "", "Properties of the variant added to the index, e.g. os=darwin,arch=arm64,os.version=14,cpu=M2")
+
+	pushCmd.Flags().StringVar(&flagMaxManifestSize, "max-manifest-size", "", "Split larger manifests into parts under an index, e.g. 4M (default); overrides max_manifest_size of the config, 0 disables splitting")
+
+	pushCmd.Flags().BoolVar(&flagArtifact, "artifact", false, "Add title annotations and artifactType for artifact tools like oras, store files of a single segment uncompressed")
+
+	pushCmd.Flags().StringVar(&flagManifestFormat, "manifest-format", transporter.ManifestFormatAuto, "Manifest format: auto falls back from oci to docker if the registry rejects OCI manifests; overrides manifest_format of the registry")
+
+	pushCmd.Flags().StringVar(&flagLimitRate, "limit-rate", "", "Limit bandwidth of registry traffic, e.g. 20M per second; overrides bandwidth section of the config, 0 means unlimited")
+
 	return pushCmd
 }
+
+func printPushSummary(summary transporter.PushSummary) {
+	fmt.Printf("uploaded %d blobs (%s), %d blobs (%s) were already in the registry, mounted %d blobs (%s) in %v\n",
+		summary.UploadedBlobs, formatByteSize(summary.UploadedBytes),
+		summary.ExistingBlobs, formatByteSize(summary.ExistingBytes),
+		summary.MountedBlobs, formatByteSize(summary.MountedBytes),
+		summary.Elapsed.Round(time.Millisecond))
+}
@@ -13,9 +13,14 @@ func NewCmdRehash() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			src := TheAppConfig.Override(args[0])
+			ioLimiter, err := diskLimiter()
+			if err != nil {
+				return err
+			}
 			return transporter.Rehash(src,
 				transporter.WithContext(cmd.Context()),
-				transporter.WithImagesPath(TheAppConfig.ImagesDirectory))
+				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+				transporter.WithDiskLimiter(ioLimiter))
 		},
 	}
 
@@ -1,12 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/macvmio/geranos/pkg/variant"
 	"github.com/spf13/cobra"
+	"sort"
+	"time"
 )
 
 func NewCmdRemoteRepos() *cobra.Command {
@@ -15,7 +16,7 @@ func NewCmdRemoteRepos() *cobra.Command {
 		Use:       "remote",
 		Short:     "Manipulate remote repositories",
 		Long:      `Manipulate remote repositories`,
-		ValidArgs: []string{"catalog", "images", "tag"},
+		ValidArgs: []string{"catalog", "images", "tag", "copy", "rm", "prune", "inspect", "diff"},
 		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 		Run: func(cmd *cobra.Command, args []string) {
 		},
@@ -30,12 +31,10 @@ func NewCmdRemoteRepos() *cobra.Command {
 			if len(args) == 0 {
 				args = append(args, TheAppConfig.CurrentRegistry())
 			}
-			reg, err := name.NewRegistry(args[0])
-			if err != nil {
-				fmt.Println("Error parsing registry name:", err)
-				return
-			}
-			catalog, err := remote.Catalog(cmd.Context(), reg, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+			catalog, err := transporter.CatalogRemotely(args[0],
+				transporter.WithContext(cmd.Context()),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()))
 			if err != nil {
 				fmt.Println("Error fetching catalog:", err)
 				return
@@ -53,12 +52,10 @@ func NewCmdRemoteRepos() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			args[0] = TheAppConfig.Override(args[0])
-			repo, err := name.NewRepository(args[0])
-			if err != nil {
-				fmt.Println("Error parsing registry name:", err)
-				return
-			}
-			images, err := remote.List(repo, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+			images, err := transporter.ListTagsRemotely(args[0],
+				transporter.WithContext(cmd.Context()),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()))
 			if err != nil {
 				fmt.Println("Error fetching repos:", err)
 				return
@@ -77,15 +74,302 @@ func NewCmdRemoteRepos() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			args[0] = TheAppConfig.Override(args[0])
 			args[1] = TheAppConfig.Override(args[1])
-			err := transporter.RetagRemotely(args[0], args[1])
+			err := transporter.RetagRemotely(args[0], args[1],
+				transporter.WithContext(cmd.Context()),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()))
 			if err != nil {
 				fmt.Printf("Unable to retag '%s' to '%s': %v\n", args[0], args[1], err)
 			}
 		},
 	}
 
+	var flagConcurrentWorkers int
+	var flagLimitRate string
+	var copyImage = &cobra.Command{
+		Use:   "copy <srcRef> <dstRef>",
+		Short: "Copy image from one registry to another",
+		Long: `Streams image from source registry to destination registry without storing it on local disk.
+Blobs already present in destination are skipped, blobs within the same registry are mounted.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			args[0] = TheAppConfig.Override(args[0])
+			args[1] = TheAppConfig.Override(args[1])
+			limiter, err := bandwidthLimiter(flagLimitRate)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			progress := make(chan transporter.ProgressUpdate)
+			stats := &transporter.PushStatistics{}
+			progressDone := make(chan struct{})
+			go func() {
+				transporter.PrintProgress(progress)
+				close(progressDone)
+			}()
+			err = transporter.Copy(args[0], args[1],
+				transporter.WithContext(cmd.Context()),
+				transporter.WithProgressChannel(progress),
+				transporter.WithPushStatistics(stats),
+				transporter.WithWorkersCount(flagConcurrentWorkers),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+				transporter.WithBandwidthLimiter(limiter))
+			close(progress)
+			<-progressDone
+			if err != nil {
+				fmt.Printf("Unable to copy '%s' to '%s': %v\n", args[0], args[1], err)
+			} else {
+				printPushSummary(stats.Summary())
+				fmt.Println("copy has completed successfully")
+			}
+		},
+	}
+	copyImage.Flags().IntVar(&flagConcurrentWorkers, "concurrent-workers", 8,
+		"Specifies number of concurrent workers to use when copying layers")
+	copyImage.Flags().StringVar(&flagLimitRate, "limit-rate", "", "Limit bandwidth of registry traffic, e.g. 20M per second; overrides bandwidth section of the config, 0 means unlimited")
+
+	var removeImage = &cobra.Command{
+		Use:     "rm <ref>",
+		Short:   "Delete remote image by tag or digest",
+		Long:    `Deletes manifest referenced by tag or digest from the registry`,
+		Args:    cobra.ExactArgs(1),
+		Aliases: []string{"delete"},
+		Run: func(cmd *cobra.Command, args []string) {
+			args[0] = TheAppConfig.Override(args[0])
+			err := transporter.RemoveRemotely(args[0],
+				transporter.WithContext(cmd.Context()),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()))
+			if err != nil {
+				fmt.Printf("Unable to remove '%s': %v\n", args[0], err)
+			} else {
+				fmt.Printf("successfully removed %v\n", args[0])
+			}
+		},
+	}
+
+	var (
+		flagKeepLast     int
+		flagKeepPatterns []string
+		flagOlderThan    string
+		flagDryRun       bool
+	)
+	var pruneRepo = &cobra.Command{
+		Use:   "prune <repo>",
+		Short: "Delete remote tags not retained by policy",
+		Long: `Deletes tags of a remote repository which are not retained by policy.
+A tag is kept if it is one of --keep-last most recently created tags or matches one of --keep patterns.
+With --older-than only tags created earlier than that are deleted. Creation time is taken from image config.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			args[0] = TheAppConfig.Override(args[0])
+			policy := transporter.RetentionPolicy{
+				KeepLast:     flagKeepLast,
+				KeepPatterns: flagKeepPatterns,
+			}
+			if flagOlderThan != "" {
+				d, err := parseDuration(flagOlderThan)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				policy.OlderThan = d
+			}
+			decisions, err := transporter.PruneRemotely(args[0], policy,
+				transporter.WithContext(cmd.Context()),
+				transporter.WithVerbose(TheAppConfig.Verbose),
+				transporter.WithDryRun(flagDryRun),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()))
+			for _, d := range decisions {
+				action := "keep"
+				if d.Delete {
+					action = "delete"
+				}
+				created := "-"
+				if !d.Created.IsZero() {
+					created = d.Created.Format(time.RFC3339)
+				}
+				fmt.Printf("%-6s %-30s %-25s %s\n", action, d.Tag, created, d.Reason)
+			}
+			if err != nil {
+				fmt.Printf("Unable to prune '%s': %v\n", args[0], err)
+			}
+		},
+	}
+	pruneRepo.Flags().IntVar(&flagKeepLast, "keep-last", 0, "Keep given number of most recently created tags")
+	pruneRepo.Flags().StringArrayVar(&flagKeepPatterns, "keep", nil, "Keep tags matching regular expression, can be repeated")
+	pruneRepo.Flags().StringVar(&flagOlderThan, "older-than", "", "Delete only tags created earlier than given duration ago (e.g. 72h or 30d)")
+	pruneRepo.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the plan without deleting anything")
+
+	var (
+		flagJSON           bool
+		flagInspectVariant string
+	)
+	var inspectImage = &cobra.Command{
+		Use:   "inspect <ref>",
+		Short: "Inspect remote image without pulling it",
+		Long: `Shows files, sizes, segments, labels and creation time of remote image, only manifest and config are downloaded.
+For an image index its variants are listed, and the variant selected by --variant or matching this host is described.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			args[0] = TheAppConfig.Override(args[0])
+			selector, err := variant.Parse(flagInspectVariant)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			summary, err := transporter.InspectRemotely(args[0],
+				transporter.WithContext(cmd.Context()),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+				transporter.WithVariant(selector))
+			if err != nil {
+				fmt.Printf("Unable to inspect '%s': %v\n", args[0], err)
+				return
+			}
+			if flagJSON {
+				out, err := json.MarshalIndent(summary, "", "  ")
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				fmt.Println(string(out))
+				return
+			}
+			printImageSummary(summary)
+		},
+	}
+	inspectImage.Flags().BoolVar(&flagJSON, "json", false, "Print as JSON")
+	inspectImage.Flags().StringVar(&flagInspectVariant, "variant", "", "Describe variant of an image index with given properties, e.g. os.version=14,cpu=M2")
+
+	var (
+		flagDiffJSON bool
+		flagDiffMap  bool
+	)
+	var diffImages = &cobra.Command{
+		Use:   "diff <fromRef> <toRef>",
+		Short: "Compare two remote images without pulling them",
+		Long: `Reports per file how many segments and bytes are shared, changed, added or removed,
+and how much a host holding the first image would download to get the second one. Only manifests and configs are downloaded.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			args[0] = TheAppConfig.Override(args[0])
+			args[1] = TheAppConfig.Override(args[1])
+			diff, err := transporter.DiffRemotely(args[0], args[1],
+				transporter.WithContext(cmd.Context()),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()))
+			if err != nil {
+				fmt.Printf("Unable to compare '%s' with '%s': %v\n", args[0], args[1], err)
+				return
+			}
+			if flagDiffJSON {
+				out, err := json.MarshalIndent(diff, "", "  ")
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				fmt.Println(string(out))
+				return
+			}
+			printImageDiff(diff, flagDiffMap)
+		},
+	}
+	diffImages.Flags().BoolVar(&flagDiffJSON, "json", false, "Print as JSON")
+	diffImages.Flags().BoolVar(&flagDiffMap, "map", false, "Show side-by-side segment map of each file")
+
 	remoteReposCmd.AddCommand(catalogCmd)
 	remoteReposCmd.AddCommand(listImages)
 	remoteReposCmd.AddCommand(tagImage)
+	remoteReposCmd.AddCommand(copyImage)
+	remoteReposCmd.AddCommand(removeImage)
+	remoteReposCmd.AddCommand(pruneRepo)
+	remoteReposCmd.AddCommand(inspectImage)
+	remoteReposCmd.AddCommand(diffImages)
 	return remoteReposCmd
 }
+
+func printImageSummary(s *transporter.ImageSummary) {
+	fmt.Printf("Reference: %s\n", s.Reference)
+	if len(s.Variants) > 0 {
+		fmt.Printf("Index:     %s\n", s.Index)
+		fmt.Println("Variants:")
+		selected := false
+		for _, v := range s.Variants {
+			marker := " "
+			if v.Selected {
+				marker = "*"
+				selected = true
+			}
+			fmt.Printf("%s %s %s\n", marker, v.Digest, v.Properties)
+		}
+		if !selected {
+			fmt.Println("No variant matches this host, use --variant to select one")
+			return
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Digest:    %s\n", s.Digest)
+	if s.Parts > 0 {
+		fmt.Printf("Parts:     %d manifests under index %s\n", s.Parts, s.Index)
+	}
+	fmt.Printf("Created:   %s\n", s.Created.Format(time.RFC3339))
+	if len(s.Labels) > 0 {
+		keys := make([]string, 0, len(s.Labels))
+		for k := range s.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Println("Labels:")
+		for _, k := range keys {
+			fmt.Printf("  %s=%s\n", k, s.Labels[k])
+		}
+	}
+	fmt.Printf("\n%-40s %-12s %-10s %-12s\n", "FILE", "SIZE", "SEGMENTS", "COMPRESSED")
+	for _, f := range s.Files {
+		fmt.Printf("%-40s %-12s %-10d %-12s\n", f.Filename, formatByteSize(f.Size), f.Segments, formatByteSize(f.CompressedSize))
+	}
+	fmt.Printf("%-40s %-12s %-10d %-12s\n", "TOTAL", formatByteSize(s.Size), s.Segments, formatByteSize(s.CompressedSize))
+}
+
+var segmentStateSymbols = map[transporter.SegmentState]byte{
+	transporter.SegmentShared:  '=',
+	transporter.SegmentChanged: '~',
+	transporter.SegmentAdded:   '+',
+	transporter.SegmentRemoved: '-',
+}
+
+func segmentMap(states []transporter.SegmentState, from, to int) string {
+	res := make([]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		if i < len(states) {
+			res = append(res, segmentStateSymbols[states[i]])
+		} else {
+			res = append(res, ' ')
+		}
+	}
+	return string(res)
+}
+
+func printImageDiff(d *transporter.ImageDiff, withMap bool) {
+	const mapWidth = 64
+	fmt.Printf("%-30s %-10s %-12s %-12s %-12s %-12s %-12s\n", "FILE", "STATUS", "SHARED", "CHANGED", "ADDED", "REMOVED", "DOWNLOAD")
+	for _, f := range d.Files {
+		fmt.Printf("%-30s %-10s %-12s %-12s %-12s %-12s %-12s\n", f.Filename, f.Status, formatByteSize(f.SharedBytes),
+			formatByteSize(f.ChangedBytes), formatByteSize(f.AddedBytes), formatByteSize(f.RemovedBytes), formatByteSize(f.DownloadBytes))
+		if !withMap {
+			continue
+		}
+		for i := 0; i < max(len(f.From), len(f.To)); i += mapWidth {
+			fmt.Printf("  %6d  from |%s|  to |%s|\n", i, segmentMap(f.From, i, i+mapWidth), segmentMap(f.To, i, i+mapWidth))
+		}
+	}
+	fmt.Printf("%-30s %-10s %-12s %-12s %-12s %-12s %-12s\n", "TOTAL", "", formatByteSize(d.SharedBytes),
+		formatByteSize(d.ChangedBytes), formatByteSize(d.AddedBytes), formatByteSize(d.RemovedBytes), formatByteSize(d.DownloadBytes))
+	if withMap {
+		fmt.Println("\nsegments: '=' shared, '~' changed, '+' added, '-' removed")
+	}
+	fmt.Printf("\nhost holding %v would download %s to get %v\n", d.From, formatByteSize(d.DownloadBytes), d.To)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/google/go-containerregistry/cmd/crane/cmd"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/macvmio/geranos/pkg/throttle"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"os"
@@ -24,6 +26,11 @@ It relies on sparse files and Copy-on-Write filesystem features to optimize disk
 			if err := initConfig(); err != nil {
 				return fmt.Errorf("failed to initialize config: %v", err)
 			}
+			if TheAppConfig.DiskIO.LowPriority {
+				if err := throttle.LowerProcessPriority(); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -39,6 +46,11 @@ It relies on sparse files and Copy-on-Write filesystem features to optimize disk
 	// Bind the verbose flag to Viper
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 
+	rootCmd.PersistentFlags().String("io-limit-rate", "", "limit local disk reads and writes, e.g. 100M per second")
+	rootCmd.PersistentFlags().Bool("low-priority", false, "run with low disk I/O and CPU priority")
+	viper.BindPFlag("disk_io.limit_rate", rootCmd.PersistentFlags().Lookup("io-limit-rate"))
+	viper.BindPFlag("disk_io.low_priority", rootCmd.PersistentFlags().Lookup("low-priority"))
+
 	rootCmd.AddCommand(
 		NewCmdPull(),
 		NewCmdPush(),
@@ -53,6 +65,12 @@ It relies on sparse files and Copy-on-Write filesystem features to optimize disk
 		NewCmdRemoteRepos(),
 		NewCmdContext(),
 		NewCmdRehash(),
+		NewCmdServe(),
+		NewCmdSave(),
+		NewCmdLoad(),
+		NewCmdOutdated(),
+		NewCmdUpdate(),
+		NewCmdSync(),
 	)
 
 	return rootCmd
@@ -60,6 +78,9 @@ It relies on sparse files and Copy-on-Write filesystem features to optimize disk
 
 func Execute(rootCmd *cobra.Command) {
 	rootCmd.Version = cmd.Version
+	if Version != "" {
+		layout.Version = Version
+	}
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
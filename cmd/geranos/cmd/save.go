@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdSave() *cobra.Command {
+	var (
+		flagOutput string
+		flagBase   string
+	)
+
+	var saveCmd = &cobra.Command{
+		Use:   "save [image name]",
+		Short: "Save a local image to an OCI image layout for offline transfer.",
+		Long: `Writes the local image to an OCI image layout, a tar archive if the output ends with .tar or a directory otherwise.
+With --base segments of the given local image are left out, so only changes are transferred; the base image
+has to be present where the layout is loaded.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flagOutput == "" {
+				return errors.New("output has to be set with -o")
+			}
+			src := TheAppConfig.Override(args[0])
+			ioLimiter, err := diskLimiter()
+			if err != nil {
+				return err
+			}
+			opts := []transporter.Option{
+				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+				transporter.WithContext(cmd.Context()),
+				transporter.WithVerbose(TheAppConfig.Verbose),
+				transporter.WithDiskLimiter(ioLimiter),
+			}
+			if flagBase != "" {
+				opts = append(opts, transporter.WithSaveBase(TheAppConfig.Override(flagBase)))
+			}
+			stats, err := transporter.Save(src, flagOutput, opts...)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("saved %d blobs (%s) to '%v'\n", stats.Blobs, formatByteSize(stats.Bytes), flagOutput)
+			if stats.ExcludedBlobs > 0 {
+				fmt.Printf("left out %d blobs (%s) of the base image\n", stats.ExcludedBlobs, formatByteSize(stats.ExcludedBytes))
+			}
+			return nil
+		},
+	}
+
+	saveCmd.Flags().StringVarP(&flagOutput, "output", "o", "", "Path of the tar archive (ending with .tar) or directory to write")
+
+	saveCmd.Flags().StringVar(&flagBase, "base", "", "Leave out segments of this local image")
+
+	return saveCmd
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdServe() *cobra.Command {
+	var (
+		flagAddr      string
+		flagCacheDir  string
+		flagCacheSize string
+		flagUpstream  string
+	)
+
+	var serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Expose local images as a read-only OCI registry.",
+		Long: `Serves images from the local images directory using the OCI distribution API, so other hosts can pull from it.
+Repository names are full names of local images, e.g. 'geranos pull myhost:5000/ghcr.io/macvmio/macos-sonoma:14.5'.
+With --upstream it works as a pull-through caching proxy of the upstream registry instead,
+e.g. 'geranos pull myhost:5000/macvmio/macos-sonoma:14.5'. Cache statistics are reported at '/_geranos/status'.`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := []transporter.Option{
+				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+				transporter.WithContext(cmd.Context()),
+				transporter.WithVerbose(TheAppConfig.Verbose),
+				transporter.WithServeCachePath(flagCacheDir),
+				transporter.WithServeUpstream(flagUpstream),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+			}
+			if flagCacheSize != "" {
+				size, err := parseByteSize(flagCacheSize)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, transporter.WithServeCacheSize(size))
+			}
+			return transporter.Serve(flagAddr, opts...)
+		},
+	}
+
+	serveCmd.Flags().StringVar(&flagAddr, "addr", ":5000", "Address to listen on")
+	serveCmd.Flags().StringVar(&flagCacheDir, "cache-dir", "",
+		"Directory where compressed segments are kept, by default segments are compressed on every request")
+	serveCmd.Flags().StringVar(&flagCacheSize, "cache-size", "",
+		"Maximum size of the cache directory (e.g. 200GB), least recently used blobs are evicted first")
+	serveCmd.Flags().StringVar(&flagUpstream, "upstream", "",
+		"Registry to forward requests to, enables pull-through caching proxy mode")
+
+	return serveCmd
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultLockPath returns path of the lock file next to the spec, e.g. images.lock.yaml for images.yaml.
+func defaultLockPath(specPath string) string {
+	ext := filepath.Ext(specPath)
+	return strings.TrimSuffix(specPath, ext) + ".lock" + ext
+}
+
+func printSyncSteps(steps []transporter.SyncStep, dryRun bool) {
+	changes := 0
+	for _, s := range steps {
+		ref := "<invalid>"
+		if s.Reference != nil {
+			ref = s.Reference.String()
+		}
+		switch {
+		case s.Err != nil:
+			fmt.Printf("%-10s %v: %v\n", "error", ref, s.Err)
+		case s.Action == transporter.SyncActionPull || s.Action == transporter.SyncActionUpdate:
+			changes++
+			fmt.Printf("%-10s %v %v\n", s.Action, ref, shortDigest(s.Digest))
+		case s.Action == transporter.SyncActionRemove:
+			changes++
+			fmt.Printf("%-10s %v\n", s.Action, ref)
+		default:
+			fmt.Printf("%-10s %v %v\n", s.Action, ref, shortDigest(s.LocalDigest))
+		}
+	}
+	if changes == 0 {
+		fmt.Println("local images are in sync")
+	} else if dryRun {
+		fmt.Printf("%d change(s) planned, nothing was modified\n", changes)
+	}
+}
+
+func NewCmdSync() *cobra.Command {
+	var (
+		flagFile      string
+		flagLock      string
+		flagPrune     bool
+		flagDryRun    bool
+		flagLimitRate string
+	)
+
+	var syncCmd = &cobra.Command{
+		Use:   "sync -f <images.yaml>",
+		Short: "Bring local images to the state described in a file.",
+		Long: `Reads a list of images, optionally pinned to digests, and pulls those which are missing or whose tags point to
+other manifests. Entries with 'no_update: true' are pulled only if missing. With --prune local images not listed
+are removed. Entries with 'retain: true' are recorded in the lock file, and their images are kept even after
+the entries are dropped from the file. Running it again changes nothing.
+
+After syncing, the resolved digests are written to a lock file, which can be used as the file of another sync
+to get the same images. With --dry-run the plan is printed and nothing is modified.
+
+Example of the file:
+  images:
+    - reference: ghcr.io/macvmio/macos-sonoma:14.5
+    - reference: ghcr.io/macvmio/macos-ventura:13.6
+      digest: sha256:...
+    - reference: ghcr.io/macvmio/base:latest
+      retain: true`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			spec, err := transporter.ReadSyncSpec(flagFile)
+			if err != nil {
+				return err
+			}
+			for i := range spec.Images {
+				spec.Images[i].Reference = TheAppConfig.Override(spec.Images[i].Reference)
+			}
+			if flagLock == "" {
+				flagLock = defaultLockPath(flagFile)
+			}
+			limiter, err := bandwidthLimiter(flagLimitRate)
+			if err != nil {
+				return err
+			}
+			ioLimiter, err := diskLimiter()
+			if err != nil {
+				return err
+			}
+			progress := make(chan transporter.ProgressUpdate)
+			opts := []transporter.Option{
+				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+				transporter.WithContext(cmd.Context()),
+				transporter.WithVerbose(TheAppConfig.Verbose),
+				transporter.WithDryRun(flagDryRun),
+				transporter.WithProgressChannel(progress),
+				transporter.WithPullPolicy(&TheAppConfig.Policy),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+				transporter.WithBandwidthLimiter(limiter),
+				transporter.WithDiskLimiter(ioLimiter),
+			}
+			if _, err := os.Stat(flagLock); err == nil {
+				lock, err := transporter.ReadSyncSpec(flagLock)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, transporter.WithSyncLock(lock))
+			}
+			progressDone := make(chan struct{})
+			go func() {
+				transporter.PrintProgress(progress)
+				close(progressDone)
+			}()
+			steps, err := transporter.Sync(spec, flagPrune, opts...)
+			close(progress)
+			<-progressDone
+			printSyncSteps(steps, flagDryRun)
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, s := range steps {
+				if s.Err != nil {
+					failed++
+				}
+			}
+			if flagDryRun {
+				return nil
+			}
+			if err := transporter.WriteSyncSpec(flagLock, spec.Lock(steps)); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d image(s) could not be synced", failed)
+			}
+			return nil
+		},
+	}
+
+	syncCmd.Flags().StringVarP(&flagFile, "file", "f", "", "File with the desired images")
+	syncCmd.Flags().StringVar(&flagLock, "lock", "", "Lock file with resolved digests, by default next to the file, e.g. images.lock.yaml")
+	syncCmd.Flags().BoolVar(&flagPrune, "prune", false, "Remove local images not listed in the file")
+	syncCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the plan without modifying anything")
+	syncCmd.Flags().StringVar(&flagLimitRate, "limit-rate", "", "Limit bandwidth of registry traffic, e.g. 20M per second; overrides bandwidth section of the config, 0 means unlimited")
+	syncCmd.MarkFlagRequired("file")
+
+	return syncCmd
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseByteSize parses sizes like '512', '10MB', '1.5G' or '20GiB', multiples are powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	units := []string{"K", "M", "G", "T"}
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := int64(1)
+	for i, u := range units {
+		if strings.HasSuffix(value, u) {
+			value = strings.TrimSuffix(value, u)
+			multiplier = int64(1) << (10 * (i + 1))
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size '%v', expected value like '512MB' or '20GB'", s)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// formatByteSize formats size using the same units as parseByteSize, e.g. '1.5G'.
+func formatByteSize(size int64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.1f%s", value, units[i])
+}
+
+// parseDuration extends time.ParseDuration with days, e.g. '30d'.
+func parseDuration(s string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(strings.TrimSpace(s), "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration '%v'", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%v': %w", s, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"github.com/macvmio/geranos/pkg/transporter"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdUpdate() *cobra.Command {
+	var flagLimitRate string
+
+	var updateCmd = &cobra.Command{
+		Use:   "update [image name...]",
+		Short: "Pull newer versions of outdated local images.",
+		Long: `Checks given local images, or all images pulled from a registry if none are given, with HEAD requests,
+and pulls those whose tags point to other manifests. Matching local files are reused, as with pull.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			refs := make([]string, 0, len(args))
+			for _, a := range args {
+				refs = append(refs, TheAppConfig.Override(a))
+			}
+			limiter, err := bandwidthLimiter(flagLimitRate)
+			if err != nil {
+				return err
+			}
+			ioLimiter, err := diskLimiter()
+			if err != nil {
+				return err
+			}
+			progress := make(chan transporter.ProgressUpdate)
+			opts := []transporter.Option{
+				transporter.WithImagesPath(TheAppConfig.ImagesDirectory),
+				transporter.WithContext(cmd.Context()),
+				transporter.WithVerbose(TheAppConfig.Verbose),
+				transporter.WithProgressChannel(progress),
+				transporter.WithPullPolicy(&TheAppConfig.Policy),
+				transporter.WithKeychain(TheAppConfig.Keychain()),
+				transporter.WithTransportHosts(TheAppConfig.TransportHosts()),
+				transporter.WithBandwidthLimiter(limiter),
+				transporter.WithDiskLimiter(ioLimiter),
+			}
+			progressDone := make(chan struct{})
+			go func() {
+				transporter.PrintProgress(progress)
+				close(progressDone)
+			}()
+			statuses, err := transporter.Update(refs, opts...)
+			close(progress)
+			<-progressDone
+			printImageStatuses(statuses, true)
+			return err
+		},
+	}
+
+	updateCmd.Flags().StringVar(&flagLimitRate, "limit-rate", "", "Limit bandwidth of registry traffic, e.g. 20M per second; overrides bandwidth section of the config, 0 means unlimited")
+
+	return updateCmd
+}
@@ -4,6 +4,7 @@ go 1.23
 
 require (
 	github.com/docker/cli v26.0.0+incompatible
+	github.com/docker/docker-credential-helpers v0.7.0
 	github.com/google/go-containerregistry v0.19.1
 	github.com/klauspost/compress v1.17.7
 	github.com/spf13/cobra v1.8.0
@@ -12,6 +13,7 @@ require (
 	golang.org/x/sync v0.5.0
 	golang.org/x/sys v0.22.0
 	golang.org/x/term v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -19,7 +21,6 @@ require (
 	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/docker/distribution v2.8.2+incompatible // indirect
 	github.com/docker/docker v27.1.1+incompatible // indirect
-	github.com/docker/docker-credential-helpers v0.7.0 // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
@@ -46,5 +47,4 @@ require (
 	golang.org/x/exp v0.0.0-20230905200255-921286631fa9 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -2,6 +2,10 @@ package appconfig
 
 import (
 	"fmt"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/macvmio/geranos/pkg/keychain"
+	"github.com/macvmio/geranos/pkg/policy"
+	"github.com/macvmio/geranos/pkg/transport"
 	"strings"
 )
 
@@ -9,7 +13,32 @@ type Context struct {
 	Name     string `mapstructure:"name"`
 	Registry string `mapstructure:"registry"`
 	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
+	// Password is only read from configs written before secrets were kept by credential helpers.
+	Password string `mapstructure:"password" yaml:"password,omitempty"`
+	// CredentialsStore is the Docker credential helper keeping the secret, e.g. 'osxkeychain'.
+	CredentialsStore string `mapstructure:"credentials_store" yaml:"credentials_store,omitempty"`
+
+	// Transport profile of the context registry
+	transport.Profile `mapstructure:",squash" yaml:",inline"`
+}
+
+// BandwidthWindow overrides the limit during part of the day, e.g. from '08:00' to '18:00'.
+type BandwidthWindow struct {
+	From      string `mapstructure:"from"`
+	To        string `mapstructure:"to"`
+	LimitRate string `mapstructure:"limit_rate"`
+}
+
+// Bandwidth limits registry traffic, rates are sizes per second like '20M'. Empty or '0' means unlimited.
+type Bandwidth struct {
+	LimitRate string            `mapstructure:"limit_rate"`
+	Schedule  []BandwidthWindow `mapstructure:"schedule"`
+}
+
+// DiskIO limits local disk traffic of hashing and writing. LimitRate is size per second like '100M', empty means unlimited.
+type DiskIO struct {
+	LimitRate   string `mapstructure:"limit_rate"`
+	LowPriority bool   `mapstructure:"low_priority"`
 }
 
 type Config struct {
@@ -17,6 +46,14 @@ type Config struct {
 	Contexts        []Context `mapstructure:"contexts"`
 	CurrentContext  string    `mapstructure:"current_context"`
 	Verbose         bool      `mapstructure:"verbose"`
+
+	Policy     policy.Policy    `mapstructure:"policy"`
+	Registries []transport.Host `mapstructure:"registries"`
+	Bandwidth  Bandwidth        `mapstructure:"bandwidth"`
+	DiskIO     DiskIO           `mapstructure:"disk_io"`
+
+	// MaxManifestSize like '4M', larger manifests are pushed split into parts under an index. '0' disables splitting.
+	MaxManifestSize string `mapstructure:"max_manifest_size"`
 }
 
 func (c *Config) findCurrentContext() (*Context, error) {
@@ -57,3 +94,58 @@ func (c *Config) CurrentRegistry() string {
 	}
 	return currentContext.Registry
 }
+
+// Host returns the registry host the secret of the context is stored for.
+func (ctx *Context) Host() string {
+	return keychain.Context{Registry: ctx.Registry}.Host()
+}
+
+// ServerURL returns the key of the context secret in its SecretStore.
+func (ctx *Context) ServerURL() string {
+	return keychain.Context{Name: ctx.Name, Registry: ctx.Registry}.ServerURL()
+}
+
+// SecretStore returns store of the secret of the context, the default credential helper is used if
+// the context does not set one. It returns nil if no helper is available.
+func (ctx *Context) SecretStore() keychain.Store {
+	helper := ctx.CredentialsStore
+	if helper == "" {
+		helper = keychain.DefaultHelper()
+	}
+	if helper == "" {
+		return nil
+	}
+	return keychain.NewHelperStore(helper)
+}
+
+// Keychain resolves credentials of the current context registry from the context, other registries
+// and contexts without stored secret fall back to the Docker config.
+func (c *Config) Keychain() authn.Keychain {
+	currentContext, err := c.findCurrentContext()
+	if err != nil {
+		return keychain.New(keychain.Context{}, keychain.WithVerbose(c.Verbose))
+	}
+	opts := []keychain.Option{keychain.WithVerbose(c.Verbose)}
+	if currentContext.User != "" && currentContext.Password == "" {
+		opts = append(opts, keychain.WithStore(currentContext.SecretStore()))
+	}
+	return keychain.New(keychain.Context{
+		Name:     currentContext.Name,
+		Registry: currentContext.Registry,
+		User:     currentContext.User,
+		Password: currentContext.Password,
+	}, opts...)
+}
+
+// TransportHosts returns transport profiles of registries, followed by profiles set in contexts.
+// Entries of 'registries' take precedence over contexts using the same registry.
+func (c *Config) TransportHosts() []transport.Host {
+	res := make([]transport.Host, 0, len(c.Registries)+len(c.Contexts))
+	res = append(res, c.Registries...)
+	for _, ctx := range c.Contexts {
+		if ctx.Registry != "" && !ctx.Profile.IsZero() {
+			res = append(res, transport.Host{Registry: ctx.Registry, Profile: ctx.Profile})
+		}
+	}
+	return res
+}
@@ -0,0 +1,198 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/filesegment"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnpackAnnotationKey marks titled layers which oras extracts into a directory named by the title.
+const UnpackAnnotationKey = "io.deis.oras.content.unpack"
+
+// skippedMediaTypes are known layers which carry nothing to write, like signatures and attestations.
+var skippedMediaTypes = map[types.MediaType]bool{
+	"application/vnd.oci.empty.v1+json":                  true,
+	"application/vnd.dev.cosign.simplesigning.v1+json":   true,
+	"application/vnd.dsse.envelope.v1+json":              true,
+	"application/vnd.in-toto+json":                       true,
+	"application/spdx+json":                              true,
+	"application/vnd.cyclonedx+json":                     true,
+	"application/vnd.cncf.notary.signature":              true,
+	"application/vnd.docker.plugin.v1+json":              true,
+	"application/vnd.cncf.helm.chart.provenance.v1.prov": true,
+}
+
+type options struct {
+	printf   func(fmt string, args ...any)
+	reserved []string
+}
+
+type Option func(o *options)
+
+// WithLogFunction sets function printing warnings about skipped layers and tar entries.
+func WithLogFunction(log func(fmt string, args ...any)) Option {
+	return func(o *options) {
+		o.printf = log
+	}
+}
+
+// WithReservedNames sets names of files geranos keeps in the directory, e.g. the local manifest. Layers titled
+// with these names and tar entries with these paths are rejected, so they can not replace the files.
+func WithReservedNames(names ...string) Option {
+	return func(o *options) {
+		o.reserved = append(o.reserved, names...)
+	}
+}
+
+// isReserved reports whether the path relative to the directory is one of reserved names, ignoring case
+// as file systems of macOS do.
+func (o *options) isReserved(name string) bool {
+	p := filepath.Clean(filepath.FromSlash(name))
+	for _, r := range o.reserved {
+		if strings.EqualFold(p, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func makeOptions(opt ...Option) *options {
+	res := &options{
+		printf: log.Printf,
+	}
+	for _, o := range opt {
+		o(res)
+	}
+	return res
+}
+
+func isSegment(d v1.Descriptor) bool {
+	_, err := filesegment.ParseDescriptor(d, v1.Hash{})
+	return err == nil
+}
+
+// IsGeneric reports whether the manifest has layers which are not geranos segments.
+func IsGeneric(manifest *v1.Manifest) bool {
+	for _, l := range manifest.Layers {
+		if !isSegment(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract writes content of a generic artifact to the directory. Layers with title annotation are written as files
+// named by the title, tar layers are extracted, and known layers with nothing to write are skipped with a warning.
+// It returns number of bytes written.
+func Extract(ctx context.Context, img v1.Image, dir string, opt ...Option) (int64, error) {
+	opts := makeOptions(opt...)
+	manifest, err := img.Manifest()
+	if err != nil {
+		return 0, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	var written int64
+	for _, d := range manifest.Layers {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		if isSegment(d) {
+			return written, errors.New("image mixes geranos segments with other layers")
+		}
+		n, err := extractLayer(img, d, dir, opts)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("unable to extract layer %v: %w", d.Digest, err)
+		}
+	}
+	return written, nil
+}
+
+func extractLayer(img v1.Image, d v1.Descriptor, dir string, opts *options) (int64, error) {
+	title, titled := d.Annotations[filesegment.TitleAnnotationKey]
+	if titled && opts.isReserved(title) {
+		return 0, fmt.Errorf("title '%v' is reserved by geranos", title)
+	}
+	switch {
+	case titled && d.Annotations[UnpackAnnotationKey] == "true":
+		target, err := securePath(dir, title)
+		if err != nil {
+			return 0, err
+		}
+		if err := mkdirAll(dir, target); err != nil {
+			return 0, err
+		}
+		return extractTarLayer(img, d, target, nil, opts)
+	case titled:
+		return writeTitledLayer(img, d, dir, title)
+	case skippedMediaTypes[d.MediaType]:
+		opts.printf("warning: skipped layer %v of type '%v'\n", d.Digest, d.MediaType)
+		return 0, nil
+	case d.MediaType.IsLayer() && !d.MediaType.IsDistributable():
+		opts.printf("warning: skipped non-distributable layer %v of type '%v'\n", d.Digest, d.MediaType)
+		return 0, nil
+	case d.MediaType.IsLayer():
+		return extractTarLayer(img, d, dir, opts.isReserved, opts)
+	}
+	return 0, fmt.Errorf("unsupported layer type '%v' without title annotation", d.MediaType)
+}
+
+// writeTitledLayer writes the blob as it is, like artifact tools do.
+func writeTitledLayer(img v1.Image, d v1.Descriptor, dir, title string) (int64, error) {
+	target, err := securePath(dir, title)
+	if err != nil {
+		return 0, err
+	}
+	l, err := img.LayerByDigest(d.Digest)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get layer: %w", err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return 0, fmt.Errorf("unable to download layer: %w", err)
+	}
+	defer rc.Close()
+	return writeFile(dir, target, rc, 0o644)
+}
+
+func extractTarLayer(img v1.Image, d v1.Descriptor, dir string, reserved func(name string) bool, opts *options) (int64, error) {
+	l, err := img.LayerByDigest(d.Digest)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get layer: %w", err)
+	}
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return 0, fmt.Errorf("unable to download layer: %w", err)
+	}
+	defer rc.Close()
+	return extractTar(rc, dir, opts.printf, reserved)
+}
+
+// writeFile replaces the file with the content, never following a symlink placed at the path or its parents.
+func writeFile(root, path string, r io.Reader, perm os.FileMode) (int64, error) {
+	if err := mkdirAll(root, filepath.Dir(path)); err != nil {
+		return 0, err
+	}
+	if err := removeExisting(path); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create '%v': %w", path, err)
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, fmt.Errorf("unable to write '%v': %w", path, err)
+	}
+	return n, nil
+}
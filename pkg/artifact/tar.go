@@ -0,0 +1,174 @@
+package artifact
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// whiteoutPrefix marks deletions of files of lower layers in container images, there is nothing to delete here.
+const whiteoutPrefix = ".wh."
+
+// ExtractTar extracts the tar stream into the directory. Entries pointing outside the directory are rejected,
+// symlinks are never followed while extracting, and symlinks to targets outside the directory, devices and fifos
+// are skipped with a warning. It returns number of bytes of regular files written.
+func ExtractTar(r io.Reader, dir string, printf func(fmt string, args ...any)) (int64, error) {
+	return extractTar(r, dir, printf, nil)
+}
+
+// extractTar is ExtractTar rejecting entries whose paths relative to the directory are reserved.
+func extractTar(r io.Reader, dir string, printf func(fmt string, args ...any), reserved func(name string) bool) (int64, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("unable to create directory '%v': %w", dir, err)
+	}
+	tr := tar.NewReader(r)
+	var written int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("unable to read tar: %w", err)
+		}
+		if strings.HasPrefix(filepath.Base(hdr.Name), whiteoutPrefix) {
+			printf("warning: skipped whiteout entry '%v'\n", hdr.Name)
+			continue
+		}
+		path, err := securePath(dir, hdr.Name)
+		if err != nil {
+			return written, err
+		}
+		if reserved != nil {
+			if rel, err := filepath.Rel(dir, path); err == nil && reserved(rel) {
+				return written, fmt.Errorf("entry '%v' is reserved by geranos", hdr.Name)
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = mkdirAll(dir, path)
+		case tar.TypeReg:
+			var n int64
+			n, err = writeFile(dir, path, tr, os.FileMode(hdr.Mode).Perm())
+			written += n
+		case tar.TypeSymlink:
+			err = symlink(dir, path, hdr, printf)
+		case tar.TypeLink:
+			err = hardlink(dir, path, hdr)
+		default:
+			printf("warning: skipped '%v' of unsupported type '%c'\n", hdr.Name, hdr.Typeflag)
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// securePath joins the name to the root, it fails if the result would be outside of the root.
+func securePath(root, name string) (string, error) {
+	p := filepath.FromSlash(name)
+	if filepath.IsAbs(p) || filepath.VolumeName(p) != "" || strings.HasPrefix(p, string(filepath.Separator)) {
+		return "", fmt.Errorf("absolute path '%v' is not allowed", name)
+	}
+	p = filepath.Clean(p)
+	if p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path '%v' points outside of the directory", name)
+	}
+	return filepath.Join(root, p), nil
+}
+
+// checkDirs walks directories from the root to the path, failing on symlinks and files. Missing directories are
+// created if create is set.
+func checkDirs(root, path string, create bool) error {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	current := root
+	for _, component := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, component)
+		fi, err := os.Lstat(current)
+		switch {
+		case os.IsNotExist(err) && create:
+			if err := os.Mkdir(current, 0o755); err != nil {
+				return fmt.Errorf("unable to create directory '%v': %w", current, err)
+			}
+		case err != nil:
+			return fmt.Errorf("unable to check '%v': %w", current, err)
+		case fi.Mode()&os.ModeSymlink != 0:
+			return fmt.Errorf("'%v' is a symlink, it is not followed", current)
+		case !fi.IsDir():
+			return fmt.Errorf("'%v' is not a directory", current)
+		}
+	}
+	return nil
+}
+
+func mkdirAll(root, path string) error {
+	return checkDirs(root, path, true)
+}
+
+// removeExisting removes a file or symlink at the path, so it can be created again.
+func removeExisting(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check '%v': %w", path, err)
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("'%v' is a directory", path)
+	}
+	return os.Remove(path)
+}
+
+func symlink(root, path string, hdr *tar.Header, printf func(fmt string, args ...any)) error {
+	target := filepath.FromSlash(hdr.Linkname)
+	if filepath.IsAbs(target) {
+		printf("warning: skipped symlink '%v' to absolute path '%v'\n", hdr.Name, hdr.Linkname)
+		return nil
+	}
+	if _, err := securePath(root, filepath.Join(filepath.Dir(filepath.FromSlash(hdr.Name)), target)); err != nil {
+		printf("warning: skipped symlink '%v' to '%v' outside of the directory\n", hdr.Name, hdr.Linkname)
+		return nil
+	}
+	if err := mkdirAll(root, filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := removeExisting(path); err != nil {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+func hardlink(root, path string, hdr *tar.Header) error {
+	target, err := securePath(root, hdr.Linkname)
+	if err != nil {
+		return err
+	}
+	if err := checkDirs(root, filepath.Dir(target), false); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(target)
+	if err != nil {
+		return fmt.Errorf("unable to link '%v': %w", hdr.Name, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("hard link '%v' must point to a regular file", hdr.Name)
+	}
+	if err := mkdirAll(root, filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := removeExisting(path); err != nil {
+		return err
+	}
+	return os.Link(target, path)
+}
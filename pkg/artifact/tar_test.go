@@ -0,0 +1,106 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+func makeTar(t *testing.T, entries ...entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0o755, Size: int64(len(e.content))}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func discard(string, ...any) {}
+
+func TestExtractTar(t *testing.T) {
+	dir := t.TempDir()
+	tarball := makeTar(t,
+		entry{name: "scripts/", typeflag: tar.TypeDir},
+		entry{name: "scripts/provision.sh", typeflag: tar.TypeReg, content: "#!/bin/sh\n"},
+		entry{name: "./nested/deep/file.txt", typeflag: tar.TypeReg, content: "deep"},
+		entry{name: "latest.sh", typeflag: tar.TypeSymlink, linkname: "scripts/provision.sh"},
+		entry{name: "copy.sh", typeflag: tar.TypeLink, linkname: "scripts/provision.sh"},
+		entry{name: "passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+		entry{name: "scripts/up", typeflag: tar.TypeSymlink, linkname: "../../outside"},
+		entry{name: "scripts/.wh.removed", typeflag: tar.TypeReg},
+		entry{name: "fifo", typeflag: tar.TypeFifo},
+	)
+	warnings := 0
+	written, err := ExtractTar(tarball, dir, func(string, ...any) { warnings++ })
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("#!/bin/sh\n")+len("deep")), written)
+	assert.Equal(t, 4, warnings)
+
+	content, err := os.ReadFile(filepath.Join(dir, "latest.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "copy.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", string(content))
+	assert.FileExists(t, filepath.Join(dir, "nested", "deep", "file.txt"))
+	for _, name := range []string{"passwd", "scripts/up", "scripts/.wh.removed", "fifo"} {
+		assert.NoFileExists(t, filepath.Join(dir, name))
+	}
+	fi, err := os.Stat(filepath.Join(dir, "scripts", "provision.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+}
+
+func TestExtractTar_RejectsEscapes(t *testing.T) {
+	for name, tarball := range map[string][]entry{
+		"parent path":     {{name: "../evil.sh", typeflag: tar.TypeReg, content: "x"}},
+		"absolute path":   {{name: "/tmp/evil.sh", typeflag: tar.TypeReg, content: "x"}},
+		"through symlink": {{name: "link", typeflag: tar.TypeSymlink, linkname: "."}, {name: "link/evil.sh", typeflag: tar.TypeReg, content: "x"}},
+		"hard link out":   {{name: "evil", typeflag: tar.TypeLink, linkname: "../outside"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "image")
+
+			_, err := ExtractTar(makeTar(t, tarball...), dir, discard)
+			assert.Error(t, err)
+			assert.NoFileExists(t, filepath.Join(root, "evil.sh"))
+			assert.NoFileExists(t, filepath.Join(dir, "evil.sh"))
+		})
+	}
+}
+
+func TestExtractTar_ReplacesSymlinkInsteadOfFollowing(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "image")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	outside := filepath.Join(root, "outside.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("keep"), 0o644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "file.txt")))
+
+	_, err := ExtractTar(makeTar(t, entry{name: "file.txt", typeflag: tar.TypeReg, content: "new"}), dir, discard)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(outside)
+	require.NoError(t, err)
+	assert.Equal(t, "keep", string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+}
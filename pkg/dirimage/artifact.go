@@ -0,0 +1,61 @@
+package dirimage
+
+import (
+	"encoding/json"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+)
+
+// artifactManifest adds field missing in v1.Manifest.
+type artifactManifest struct {
+	*v1.Manifest
+	ArtifactType string `json:"artifactType,omitempty"`
+}
+
+// artifactImage sets ArtifactType in the manifest, which mutate does not support.
+type artifactImage struct {
+	v1.Image
+	artifactType string
+}
+
+func withArtifactType(img v1.Image, artifactType string) v1.Image {
+	if artifactType == "" {
+		return img
+	}
+	return &artifactImage{Image: img, artifactType: artifactType}
+}
+
+// artifactTypeOf returns ArtifactType from the manifest of the image, or empty string if it is not set.
+func artifactTypeOf(img v1.Image) (string, error) {
+	raw, err := img.RawManifest()
+	if err != nil {
+		return "", err
+	}
+	var m struct {
+		ArtifactType string `json:"artifactType"`
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return "", err
+	}
+	return m.ArtifactType, nil
+}
+
+func (ai *artifactImage) RawManifest() ([]byte, error) {
+	m, err := ai.Image.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(artifactManifest{Manifest: m, ArtifactType: ai.artifactType})
+}
+
+func (ai *artifactImage) Digest() (v1.Hash, error) {
+	return partial.Digest(ai)
+}
+
+func (ai *artifactImage) Size() (int64, error) {
+	return partial.Size(ai)
+}
+
+func (ai *artifactImage) ArtifactType() (string, error) {
+	return ai.artifactType, nil
+}
@@ -1,6 +1,7 @@
 package dirimage
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -79,3 +80,34 @@ func TestConvert_ValidImage(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, outImg)
 }
+
+func TestConvert_ArtifactMode(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, generateRandomFile(filepath.Join(dir, "disk.img"), 3000))
+	require.NoError(t, generateRandomFile(filepath.Join(dir, "nvram.bin"), 100))
+
+	img, err := Read(context.Background(), dir, WithChunkSize(1024), WithArtifactMode(), WithLogFunction(func(string, ...any) {}))
+	require.NoError(t, err)
+	rawManifest, err := img.RawManifest()
+	require.NoError(t, err)
+	assert.Contains(t, string(rawManifest), `"artifactType":"`+ArtifactType+`"`)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Layers, 4)
+	assert.Equal(t, "disk.img.0-1023.zst", manifest.Layers[0].Annotations[filesegment.TitleAnnotationKey])
+	assert.Equal(t, filesegment.MediaType, manifest.Layers[0].MediaType)
+	assert.Equal(t, "nvram.bin", manifest.Layers[3].Annotations[filesegment.TitleAnnotationKey])
+	assert.Equal(t, filesegment.RawMediaType, manifest.Layers[3].MediaType)
+
+	di, err := Convert(img)
+	require.NoError(t, err)
+	out := t.TempDir()
+	require.NoError(t, di.Write(context.Background(), out, WithLogFunction(func(string, ...any) {})))
+	for _, f := range []string{"disk.img", "nvram.bin"} {
+		expected, err := os.ReadFile(filepath.Join(dir, f))
+		require.NoError(t, err)
+		actual, err := os.ReadFile(filepath.Join(out, f))
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+}
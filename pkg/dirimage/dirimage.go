@@ -11,6 +11,9 @@ import (
 var ManifestMediaType = types.OCIManifestSchema1
 var ConfigMediaType = types.OCIConfigJSON
 
+// ArtifactType is set in manifests of images pushed in artifact mode.
+const ArtifactType = "application/online.jarosik.tomasz.geranos.image"
+
 const LocalManifestFilename = ".oci.manifest.json"
 const LocalConfigFilename = ".oci.config.json"
 
@@ -0,0 +1,65 @@
+package dirimage
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/filesegment"
+)
+
+// DockerManifestMediaType and DockerConfigMediaType are used for registries rejecting OCI manifests.
+var DockerManifestMediaType = types.DockerManifestSchema2
+var DockerConfigMediaType = types.DockerConfigJSON
+
+// dockerLayerMediaTypes maps media types of segments to Docker layer media types accepted by such registries.
+var dockerLayerMediaTypes = map[types.MediaType]types.MediaType{
+	filesegment.MediaType:    types.DockerLayer,
+	filesegment.RawMediaType: types.DockerUncompressedLayer,
+}
+
+// IsDocker reports whether the manifest, or index, uses Docker v2 schema 2 media types.
+func IsDocker(mediaType types.MediaType) bool {
+	return mediaType == DockerManifestMediaType || mediaType == types.DockerManifestList
+}
+
+// ToDocker returns the image with Docker v2 schema 2 manifest. Layers get Docker layer media types, and media type
+// of the segment is kept in filesegment.MediaTypeAnnotationKey annotation, so Convert reads the image as a native one.
+// Docker manifests have no ArtifactType, so it is dropped.
+func ToDocker(img v1.Image) (v1.Image, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get config file: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get layers: %w", err)
+	}
+	if len(layers) != len(manifest.Layers) {
+		return nil, fmt.Errorf("mismatch between layers (%d) and manifest layers (%d)", len(layers), len(manifest.Layers))
+	}
+	addendums := make([]mutate.Addendum, 0, len(layers))
+	for i, l := range layers {
+		d := manifest.Layers[i]
+		dockerMediaType, ok := dockerLayerMediaTypes[d.MediaType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported layer type '%v'", d.MediaType)
+		}
+		annotations := make(map[string]string, len(d.Annotations)+1)
+		for k, v := range d.Annotations {
+			annotations[k] = v
+		}
+		annotations[filesegment.MediaTypeAnnotationKey] = string(d.MediaType)
+		addendums = append(addendums, mutate.Addendum{
+			Layer:       l,
+			History:     v1.History{},
+			Annotations: annotations,
+			MediaType:   dockerMediaType,
+		})
+	}
+	return prepareImageAs(DockerManifestMediaType, DockerConfigMediaType, cfg, addendums)
+}
@@ -1,6 +1,9 @@
 package dirimage
 
 import (
+	"context"
+	"github.com/macvmio/geranos/pkg/filesegment"
+	"github.com/macvmio/geranos/pkg/throttle"
 	"log"
 	"runtime"
 )
@@ -12,6 +15,8 @@ type options struct {
 	networkFailureRetryCount int
 	progress                 chan<- ProgressUpdate
 	omitLayersContent        bool
+	diskLimiter              *throttle.Limiter
+	artifactMode             bool
 }
 
 type Option func(opts *options)
@@ -60,3 +65,31 @@ func WithOmitLayersContent() Option {
 		o.omitLayersContent = true
 	}
 }
+
+// WithDiskLimiter throttles reading and writing of files, the limiter is shared by all workers.
+func WithDiskLimiter(limiter *throttle.Limiter) Option {
+	return func(o *options) {
+		o.diskLimiter = limiter
+	}
+}
+
+// WithArtifactMode makes Read prepare image readable by artifact tools like oras: segments get title annotations,
+// files of a single segment are stored uncompressed and the manifest has ArtifactType set.
+func WithArtifactMode() Option {
+	return func(o *options) {
+		o.artifactMode = true
+	}
+}
+
+func (o *options) layerOptions(ctx context.Context) []filesegment.LayerOpt {
+	res := []filesegment.LayerOpt{filesegment.WithLogFunction(o.printf)}
+	if o.diskLimiter != nil {
+		res = append(res, filesegment.WithLimiter(ctx, o.diskLimiter))
+	}
+	return res
+}
+
+// LayerOptions returns options of file segment layers matching given options, e.g. to check local segments the same way.
+func LayerOptions(ctx context.Context, opt ...Option) []filesegment.LayerOpt {
+	return makeOptions(opt...).layerOptions(ctx)
+}
@@ -1,6 +1,10 @@
 package dirimage
 
+// StageHashing is set in progress updates sent while files are hashed by Read, updates sent by Write have no stage.
+const StageHashing = "hashing"
+
 type ProgressUpdate struct {
 	BytesProcessed int64
 	BytesTotal     int64
+	Stage          string
 }
@@ -7,6 +7,7 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/macvmio/geranos/pkg/filesegment"
 	"golang.org/x/sync/errgroup"
 	"os"
@@ -24,11 +25,17 @@ type hasLength interface {
 	Length() int64
 }
 
-func precomputeHashes(ctx context.Context, layers []v1.Layer, workersCount int) (bytesReadCount int64, err error) {
+func precomputeHashes(ctx context.Context, layers []v1.Layer, workersCount int, progress chan<- ProgressUpdate) (bytesReadCount int64, err error) {
 	jobs := make(chan v1.Layer, workersCount)
 	g, ctx := errgroup.WithContext(ctx)
 
-	var aBytesReadCount atomic.Int64
+	var bytesTotal int64
+	for _, l := range layers {
+		if hl, ok := l.(hasLength); ok {
+			bytesTotal += hl.Length()
+		}
+	}
+	var aBytesReadCount, aBytesHashedCount atomic.Int64
 	for w := 0; w < workersCount; w++ {
 		g.Go(func() error {
 			for l := range jobs {
@@ -39,6 +46,7 @@ func precomputeHashes(ctx context.Context, layers []v1.Layer, workersCount int)
 					return fmt.Errorf("layer does not implement Length() method")
 				}
 				aBytesReadCount.Add(2 * hl.Length())
+				sendStageProgressUpdate(progress, StageHashing, aBytesHashedCount.Add(hl.Length()), bytesTotal)
 			}
 			return nil
 		})
@@ -59,7 +67,7 @@ func precomputeHashes(ctx context.Context, layers []v1.Layer, workersCount int)
 	return aBytesReadCount.Load(), err
 }
 
-func prepareLayers(dir string, cfgFile *v1.ConfigFile, opts *options) ([]v1.Layer, error) {
+func prepareLayers(ctx context.Context, dir string, cfgFile *v1.ConfigFile, opts *options) ([]v1.Layer, error) {
 	dirEntries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("unable to read directory: '%v': %w", dir, err)
@@ -85,10 +93,21 @@ func prepareLayers(dir string, cfgFile *v1.ConfigFile, opts *options) ([]v1.Laye
 			continue
 		}
 
-		fileLayers, err := filesegment.Split(filepath.Join(dir, entry.Name()), opts.chunkSize, filesegment.WithLogFunction(opts.printf))
+		layerOpts := opts.layerOptions(ctx)
+		if opts.artifactMode {
+			layerOpts = append(layerOpts, filesegment.WithTitleAnnotation())
+		}
+		fileLayers, err := filesegment.Split(filepath.Join(dir, entry.Name()), opts.chunkSize, layerOpts...)
 		if err != nil {
 			return nil, err
 		}
+		if opts.artifactMode && len(fileLayers) == 1 {
+			raw, err := filesegment.NewLayer(filepath.Join(dir, entry.Name()), append(layerOpts, filesegment.WithMediaType(filesegment.RawMediaType))...)
+			if err != nil {
+				return nil, err
+			}
+			fileLayers[0] = raw
+		}
 		// Append each *filesegment.Layer to the []v1.Layer slice
 		for _, fl := range fileLayers {
 			layers = append(layers, fl)
@@ -131,9 +150,13 @@ func prepareDiffIDs(layers []v1.Layer) ([]v1.Hash, error) {
 }
 
 func prepareImage(cfg *v1.ConfigFile, addendums []mutate.Addendum) (v1.Image, error) {
+	return prepareImageAs(ManifestMediaType, ConfigMediaType, cfg, addendums)
+}
+
+func prepareImageAs(mediaType, configMediaType types.MediaType, cfg *v1.ConfigFile, addendums []mutate.Addendum) (v1.Image, error) {
 	img := empty.Image
-	img = mutate.MediaType(img, ManifestMediaType)
-	img = mutate.ConfigMediaType(img, ConfigMediaType)
+	img = mutate.MediaType(img, mediaType)
+	img = mutate.ConfigMediaType(img, configMediaType)
 	img, err := mutate.Append(img, addendums...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to append layers to image: %w", err)
@@ -185,7 +208,7 @@ func prepareConfigFile(dir string, requireFile bool) (*v1.ConfigFile, error) {
 }
 
 func computeRootFS(ctx context.Context, layers []v1.Layer, opts *options) (v1.RootFS, int64, error) {
-	bytesReadCount, err := precomputeHashes(ctx, layers, opts.workersCount)
+	bytesReadCount, err := precomputeHashes(ctx, layers, opts.workersCount, opts.progress)
 	if err != nil {
 		return v1.RootFS{}, bytesReadCount, fmt.Errorf("error occurrent while precomputing hashes: %w", err)
 	}
@@ -207,7 +230,7 @@ func Read(ctx context.Context, dir string, opt ...Option) (*DirImage, error) {
 		return nil, fmt.Errorf("failed to prepare config file: %w", err)
 	}
 
-	layers, err := prepareLayers(dir, cfgFile, opts)
+	layers, err := prepareLayers(ctx, dir, cfgFile, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare layers: %w", err)
 	}
@@ -225,6 +248,9 @@ func Read(ctx context.Context, dir string, opt ...Option) (*DirImage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare image: %w", err)
 	}
+	if opts.artifactMode {
+		img = withArtifactType(img, ArtifactType)
+	}
 	res := &DirImage{
 		Image:          img,
 		BytesReadCount: atomic.Int64{},
@@ -99,7 +99,7 @@ func TestPrepareLayers(t *testing.T) {
 		// Create a dummy config file (not required in this case but included for completeness)
 		cfgFile := &v1.ConfigFile{}
 
-		layers, err := prepareLayers(dir, cfgFile, opts)
+		layers, err := prepareLayers(context.Background(), dir, cfgFile, opts)
 		require.NoError(t, err, "prepareLayers returned error")
 
 		// Since files are split into chunks, we need to calculate the expected number of layers
@@ -174,7 +174,7 @@ func TestPrepareLayers(t *testing.T) {
 		cfgFileRead, err := prepareConfigFile(dir, true)
 		require.NoError(t, err, "prepareConfigFile returned error")
 
-		layers, err := prepareLayers(dir, cfgFileRead, opts)
+		layers, err := prepareLayers(context.Background(), dir, cfgFileRead, opts)
 		require.NoError(t, err, "prepareLayers returned error")
 
 		expectedLayerCount := len(cfgFile.RootFS.DiffIDs)
@@ -0,0 +1,187 @@
+package dirimage
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"strconv"
+)
+
+// DefaultMaxManifestSize is the manifest size limit enforced by many registries.
+const DefaultMaxManifestSize = 4 * 1024 * 1024
+
+// SplitAnnotationKey marks an index whose manifests are parts of a single image, too large for one manifest.
+const SplitAnnotationKey = "online.jarosik.tomasz.geranos.split"
+
+// PartAnnotationKey holds position of a part in the split index, starting from 0.
+const PartAnnotationKey = "online.jarosik.tomasz.geranos.part"
+
+// Split returns index of images each holding a consecutive part of segments, if manifest of the image
+// exceeds maxManifestSize. Otherwise, it returns nil. Every part has a copy of the config with DiffIDs of its segments
+// and the same media types and ArtifactType.
+func Split(img v1.Image, maxManifestSize int64) (v1.ImageIndex, error) {
+	rawManifest, err := img.RawManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	if maxManifestSize <= 0 || int64(len(rawManifest)) <= maxManifestSize {
+		return nil, nil
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get config file: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get layers: %w", err)
+	}
+	if len(layers) != len(manifest.Layers) || len(layers) != len(cfg.RootFS.DiffIDs) {
+		return nil, fmt.Errorf("mismatch between layers (%d), manifest layers (%d) and diffIDs (%d)", len(layers), len(manifest.Layers), len(cfg.RootFS.DiffIDs))
+	}
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get media type: %w", err)
+	}
+	artifactType, err := artifactTypeOf(img)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse manifest: %w", err)
+	}
+	for partsCount := (int64(len(rawManifest)) + maxManifestSize - 1) / maxManifestSize; partsCount <= int64(len(layers)); partsCount++ {
+		parts, fits, err := splitInto(int(partsCount), layers, manifest, cfg, mediaType, artifactType, maxManifestSize)
+		if err != nil {
+			return nil, err
+		}
+		if fits {
+			return assembleIndex(parts, mediaType)
+		}
+	}
+	return nil, fmt.Errorf("unable to split manifest into parts smaller than %d bytes", maxManifestSize)
+}
+
+func splitInto(partsCount int, layers []v1.Layer, manifest *v1.Manifest, cfg *v1.ConfigFile, mediaType types.MediaType, artifactType string, maxManifestSize int64) ([]v1.Image, bool, error) {
+	perPart := (len(layers) + partsCount - 1) / partsCount
+	parts := make([]v1.Image, 0, partsCount)
+	for start := 0; start < len(layers); start += perPart {
+		stop := min(start+perPart, len(layers))
+		addendums := make([]mutate.Addendum, 0, stop-start)
+		for i := start; i < stop; i++ {
+			addendums = append(addendums, mutate.Addendum{
+				Layer:       layers[i],
+				History:     v1.History{},
+				Annotations: manifest.Layers[i].Annotations,
+				MediaType:   manifest.Layers[i].MediaType,
+			})
+		}
+		partCfg := cfg.DeepCopy()
+		partCfg.RootFS.DiffIDs = cfg.RootFS.DiffIDs[start:stop]
+		part, err := prepareImageAs(mediaType, manifest.Config.MediaType, partCfg, addendums)
+		if err != nil {
+			return nil, false, err
+		}
+		part = withArtifactType(part, artifactType)
+		rawManifest, err := part.RawManifest()
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to get manifest of part: %w", err)
+		}
+		if int64(len(rawManifest)) > maxManifestSize {
+			return nil, false, nil
+		}
+		parts = append(parts, part)
+	}
+	return parts, true, nil
+}
+
+func assembleIndex(parts []v1.Image, mediaType types.MediaType) (v1.ImageIndex, error) {
+	addendums := make([]mutate.IndexAddendum, 0, len(parts))
+	for i, part := range parts {
+		addendums = append(addendums, mutate.IndexAddendum{
+			Add: part,
+			Descriptor: v1.Descriptor{
+				MediaType:   mediaType,
+				Annotations: map[string]string{PartAnnotationKey: strconv.Itoa(i)},
+			},
+		})
+	}
+	idx := mutate.AppendManifests(empty.Index, addendums...)
+	if IsDocker(mediaType) {
+		idx = mutate.IndexMediaType(idx, types.DockerManifestList)
+	}
+	return mutate.Annotations(idx, map[string]string{SplitAnnotationKey: strconv.Itoa(len(parts))}).(v1.ImageIndex), nil
+}
+
+// IsSplit reports whether the index holds parts of a single image.
+func IsSplit(index *v1.IndexManifest) bool {
+	_, ok := index.Annotations[SplitAnnotationKey]
+	return ok
+}
+
+// Assemble joins parts of the split index back into the image, which has the same manifest and config as the image
+// before splitting. Only manifests and configs of parts are downloaded.
+func Assemble(idx v1.ImageIndex) (v1.Image, error) {
+	index, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get index manifest: %w", err)
+	}
+	if !IsSplit(index) {
+		return nil, fmt.Errorf("index is not split image")
+	}
+	var cfg *v1.ConfigFile
+	var mediaType, configMediaType types.MediaType
+	var artifactType string
+	addendums := make([]mutate.Addendum, 0)
+	for i, d := range index.Manifests {
+		if d.Annotations[PartAnnotationKey] != strconv.Itoa(i) {
+			return nil, fmt.Errorf("part %d of split image is missing or out of order", i)
+		}
+		part, err := idx.Image(d.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch part %d: %w", i, err)
+		}
+		partCfg, err := part.ConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get config file of part %d: %w", i, err)
+		}
+		manifest, err := part.Manifest()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get manifest of part %d: %w", i, err)
+		}
+		if cfg == nil {
+			cfg = partCfg.DeepCopy()
+			cfg.RootFS.DiffIDs = nil
+			mediaType, configMediaType = manifest.MediaType, manifest.Config.MediaType
+			if artifactType, err = artifactTypeOf(part); err != nil {
+				return nil, fmt.Errorf("unable to parse manifest of part %d: %w", i, err)
+			}
+		}
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, partCfg.RootFS.DiffIDs...)
+		layers, err := part.Layers()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get layers of part %d: %w", i, err)
+		}
+		if len(layers) != len(manifest.Layers) {
+			return nil, fmt.Errorf("mismatch between layers (%d) and manifest layers (%d) of part %d", len(layers), len(manifest.Layers), i)
+		}
+		for j, l := range layers {
+			addendums = append(addendums, mutate.Addendum{
+				Layer:       l,
+				History:     v1.History{},
+				Annotations: manifest.Layers[j].Annotations,
+				MediaType:   manifest.Layers[j].MediaType,
+			})
+		}
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("split image has no parts")
+	}
+	img, err := prepareImageAs(mediaType, configMediaType, cfg, addendums)
+	if err != nil {
+		return nil, err
+	}
+	return withArtifactType(img, artifactType), nil
+}
@@ -0,0 +1,69 @@
+package dirimage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func readSegmentedImage(t *testing.T, segments int) *DirImage {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, generateRandomFile(filepath.Join(dir, "disk.img"), int64(segments)*1024))
+	di, err := Read(context.Background(), dir, WithChunkSize(1024), WithLogFunction(func(string, ...any) {}))
+	require.NoError(t, err)
+	return di
+}
+
+func TestSplit_SmallManifestIsNotSplit(t *testing.T) {
+	di := readSegmentedImage(t, 4)
+
+	idx, err := Split(di, DefaultMaxManifestSize)
+	require.NoError(t, err)
+	assert.Nil(t, idx)
+}
+
+func TestSplit_AssembleRestoresImage(t *testing.T) {
+	di := readSegmentedImage(t, 64)
+	rawManifest, err := di.RawManifest()
+	require.NoError(t, err)
+	limit := int64(len(rawManifest) / 3)
+
+	idx, err := Split(di, limit)
+	require.NoError(t, err)
+	require.NotNil(t, idx)
+
+	index, err := idx.IndexManifest()
+	require.NoError(t, err)
+	assert.True(t, IsSplit(index))
+	assert.GreaterOrEqual(t, len(index.Manifests), 4)
+	segments := 0
+	for _, d := range index.Manifests {
+		part, err := idx.Image(d.Digest)
+		require.NoError(t, err)
+		raw, err := part.RawManifest()
+		require.NoError(t, err)
+		assert.LessOrEqual(t, int64(len(raw)), limit)
+		layers, err := part.Layers()
+		require.NoError(t, err)
+		segments += len(layers)
+	}
+	assert.Equal(t, 64, segments)
+
+	assembled, err := Assemble(idx)
+	require.NoError(t, err)
+	expected, err := di.Digest()
+	require.NoError(t, err)
+	actual, err := assembled.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestSplit_LimitTooSmall(t *testing.T) {
+	di := readSegmentedImage(t, 4)
+
+	_, err := Split(di, 100)
+	assert.ErrorContains(t, err, "unable to split manifest")
+}
@@ -7,6 +7,7 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/macvmio/geranos/pkg/filesegment"
 	"github.com/macvmio/geranos/pkg/sparsefile"
+	"github.com/macvmio/geranos/pkg/throttle"
 	"golang.org/x/sync/errgroup"
 	"io"
 	"log"
@@ -15,7 +16,7 @@ import (
 	"syscall"
 )
 
-func writeToSegment(destinationDir string, segment *filesegment.Descriptor, src io.ReadCloser) (written int64, skipped int64, err error) {
+func writeToSegment(ctx context.Context, destinationDir string, segment *filesegment.Descriptor, src io.ReadCloser, limiter *throttle.Limiter) (written int64, skipped int64, err error) {
 	// Here: we have io.ReadCloser dumping to a file at given location
 	f, err := filesegment.NewWriter(destinationDir, segment)
 	if err != nil {
@@ -29,24 +30,34 @@ func writeToSegment(destinationDir string, segment *filesegment.Descriptor, src
 		}
 	}(f)
 
-	written, skipped, err = sparsefile.Overwrite(f, src)
+	var dst io.ReadWriteSeeker = f
+	if limiter != nil {
+		dst = throttle.NewReadWriteSeeker(ctx, f, limiter)
+	}
+	written, skipped, err = sparsefile.Overwrite(dst, src)
 	if written+skipped != segment.Length() {
 		return written, skipped, fmt.Errorf("invalid numer of bytes written+skipped: segment length: %d, written+skipped: %d", segment.Length(), written+skipped)
 	}
 	return written, skipped, err
 }
 
-func writeLayer(destinationDir string, segment *filesegment.Descriptor, layer v1.Layer) (written int64, skipped int64, err error) {
+func writeLayer(ctx context.Context, destinationDir string, segment *filesegment.Descriptor, layer v1.Layer, limiter *throttle.Limiter) (written int64, skipped int64, err error) {
 	if layer == nil {
 		return 0, 0, errors.New("nil layer provided")
 	}
 
-	rc, err := layer.Uncompressed()
+	var rc io.ReadCloser
+	if segment.MediaType() == filesegment.RawMediaType {
+		// content of raw segments must not be decompressed, even if it looks compressed
+		rc, err = layer.Compressed()
+	} else {
+		rc, err = layer.Uncompressed()
+	}
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to access uncompressed layer: %w", err)
 	}
 	defer rc.Close()
-	return writeToSegment(destinationDir, segment, rc)
+	return writeToSegment(ctx, destinationDir, segment, rc, limiter)
 }
 
 func truncateFiles(destinationDir string, segmentDescriptors []*filesegment.Descriptor) error {
@@ -76,10 +87,15 @@ func truncateFiles(destinationDir string, segmentDescriptors []*filesegment.Desc
 }
 
 func sendProgressUpdate(progressChan chan<- ProgressUpdate, current, total int64) {
+	sendStageProgressUpdate(progressChan, "", current, total)
+}
+
+func sendStageProgressUpdate(progressChan chan<- ProgressUpdate, stage string, current, total int64) {
 	select {
 	case progressChan <- ProgressUpdate{
 		BytesProcessed: current,
 		BytesTotal:     total,
+		Stage:          stage,
 	}:
 	default:
 	}
@@ -109,7 +125,7 @@ func (di *DirImage) Write(ctx context.Context, destinationDir string, opt ...Opt
 
 	jobs := make(chan Job, opts.workersCount)
 	g, groupCtx := errgroup.WithContext(ctx)
-	layerOpts := []filesegment.LayerOpt{filesegment.WithLogFunction(opts.printf)}
+	layerOpts := opts.layerOptions(groupCtx)
 	for w := 0; w < opts.workersCount; w++ {
 		g.Go(func() error {
 			for job := range jobs {
@@ -121,7 +137,7 @@ func (di *DirImage) Write(ctx context.Context, destinationDir string, opt ...Opt
 				}
 
 				for i := 0; i < opts.networkFailureRetryCount; i++ {
-					written, skipped, err := writeLayer(destinationDir, &job.Descriptor, job.Layer)
+					written, skipped, err := writeLayer(groupCtx, destinationDir, &job.Descriptor, job.Layer, opts.diskLimiter)
 					opts.printf("downloaded layer: %v, written=%d, skipped=%d\n", &job.Descriptor, written, skipped)
 
 					di.BytesWrittenCount.Add(written)
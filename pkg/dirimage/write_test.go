@@ -6,10 +6,14 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/macvmio/geranos/pkg/filesegment"
+	"github.com/macvmio/geranos/pkg/throttle"
+	"github.com/macvmio/geranos/pkg/throttle/throttletest"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestWrite_ContextCancelledDuringWork(t *testing.T) {
@@ -91,3 +95,32 @@ func TestDirImage_deleteManifest(t *testing.T) {
 		}
 	})
 }
+
+func TestReadWrite_DiskLimiterIsSharedByWorkers(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	const size = 128 * 1024
+	require.NoError(t, generateRandomFile(filepath.Join(srcDir, "disk.img"), size))
+
+	clock := throttletest.NewClock(time.Unix(0, 0))
+	limiter := throttle.NewLimiterWithClock(throttle.Constant(size), clock)
+	opts := []Option{WithChunkSize(16 * 1024), WithWorkersCount(8), WithDiskLimiter(limiter), WithLogFunction(func(string, ...any) {})}
+
+	di, err := Read(context.Background(), srcDir, opts...)
+	require.NoError(t, err)
+	// file is read twice, for the uncompressed and compressed hash, the first second is the burst
+	assert.GreaterOrEqual(t, clock.Slept(), time.Second-time.Millisecond)
+
+	di, err = Convert(di)
+	require.NoError(t, err)
+	clock.Reset(clock.Now())
+	require.NoError(t, di.Write(context.Background(), dstDir, opts...))
+	// existing file is checked, segments are read from the source and written
+	assert.GreaterOrEqual(t, clock.Slept(), time.Second-time.Millisecond)
+
+	expected, err := os.ReadFile(filepath.Join(srcDir, "disk.img"))
+	require.NoError(t, err)
+	actual, err := os.ReadFile(filepath.Join(dstDir, "disk.img"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
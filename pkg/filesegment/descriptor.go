@@ -12,12 +12,20 @@ import (
 const FilenameAnnotationKey = "filename"
 const RangeAnnotationKey = "range"
 
+// TitleAnnotationKey is the standard annotation used by artifact tools like oras to name files.
+const TitleAnnotationKey = "org.opencontainers.image.title"
+
+// MediaTypeAnnotationKey holds media type of the segment, when the layer is pushed with a Docker layer media type.
+const MediaTypeAnnotationKey = "online.jarosik.tomasz.geranos.mediatype"
+
 type Descriptor struct {
-	filename string
-	start    int64
-	stop     int64
-	digest   v1.Hash
-	diffID   v1.Hash
+	filename  string
+	start     int64
+	stop      int64
+	digest    v1.Hash
+	diffID    v1.Hash
+	mediaType types.MediaType
+	title     string
 }
 
 func (d *Descriptor) Filename() string {
@@ -43,14 +51,18 @@ func (d *Descriptor) Length() int64 {
 }
 
 func (d *Descriptor) Annotations() map[string]string {
-	return map[string]string{
+	res := map[string]string{
 		FilenameAnnotationKey: d.filename,
 		RangeAnnotationKey:    fmt.Sprintf("%d-%d", d.start, d.stop),
 	}
+	if d.title != "" {
+		res[TitleAnnotationKey] = d.title
+	}
+	return res
 }
 
 func (d *Descriptor) MediaType() types.MediaType {
-	return MediaType
+	return d.mediaType
 }
 
 func (d *Descriptor) String() string {
@@ -79,15 +91,20 @@ func parseIntPair(s string) (int64, int64, error) {
 
 func NewDescriptor(filename string, start, stop int64, digest v1.Hash) *Descriptor {
 	return &Descriptor{
-		filename: filename,
-		start:    start,
-		stop:     stop,
-		digest:   digest,
+		filename:  filename,
+		start:     start,
+		stop:      stop,
+		digest:    digest,
+		mediaType: MediaType,
 	}
 }
 
 func ParseDescriptor(d v1.Descriptor, diffID v1.Hash) (*Descriptor, error) {
-	if d.MediaType != MediaType {
+	mediaType := d.MediaType
+	if mt, present := d.Annotations[MediaTypeAnnotationKey]; present {
+		mediaType = types.MediaType(mt)
+	}
+	if mediaType != MediaType && mediaType != RawMediaType {
 		return nil, errors.New("unsupported layer type")
 	}
 	filename, present := d.Annotations[FilenameAnnotationKey]
@@ -103,10 +120,12 @@ func ParseDescriptor(d v1.Descriptor, diffID v1.Hash) (*Descriptor, error) {
 		return nil, fmt.Errorf("invalid range: %w", err)
 	}
 	return &Descriptor{
-		filename: filename,
-		start:    start,
-		stop:     stop,
-		digest:   d.Digest,
-		diffID:   diffID,
+		filename:  filename,
+		start:     start,
+		stop:      stop,
+		digest:    d.Digest,
+		diffID:    diffID,
+		mediaType: mediaType,
+		title:     d.Annotations[TitleAnnotationKey],
 	}, nil
 }
@@ -1,10 +1,12 @@
 package filesegment
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/throttle"
 	"github.com/macvmio/geranos/pkg/zstd"
 	"io"
 	"log"
@@ -15,6 +17,9 @@ import (
 
 const MediaType = types.MediaType("application/online.jarosik.tomasz.geranos.segment")
 
+// RawMediaType is used for segments stored without compression, which artifact tools write out as they are.
+const RawMediaType = types.MediaType("application/online.jarosik.tomasz.geranos.segment.raw")
+
 type Layer struct {
 	filePath  string
 	start     int64
@@ -28,7 +33,10 @@ type Layer struct {
 	compressedOnce   sync.Once
 	uncompressedOnce sync.Once
 
-	log func(fmt string, args ...any)
+	log     func(fmt string, args ...any)
+	ctx     context.Context
+	limiter *throttle.Limiter
+	titled  bool
 }
 
 var _ v1.Layer = (*Layer)(nil)
@@ -52,14 +60,18 @@ func (pfl *Layer) DiffID() (v1.Hash, error) {
 
 // Uncompressed implements v1.Layer
 func (pfl *Layer) Uncompressed() (io.ReadCloser, error) {
-	return newPartialFileReader(pfl.filePath, pfl.start, pfl.stop)
+	r, err := newPartialFileReader(pfl.filePath, pfl.start, pfl.stop)
+	if err != nil || pfl.limiter == nil {
+		return r, err
+	}
+	return throttle.NewReadCloser(pfl.ctx, r, pfl.limiter), nil
 }
 
-// Compressed implements v1.Layer
+// Compressed implements v1.Layer, raw segments are returned uncompressed.
 func (pfl *Layer) Compressed() (io.ReadCloser, error) {
 	u, err := pfl.Uncompressed()
-	if err != nil {
-		return nil, err
+	if err != nil || pfl.mediaType == RawMediaType {
+		return u, err
 	}
 	return zstd.ReadCloser(u), nil
 }
@@ -105,10 +117,23 @@ func (pfl *Layer) Stop() int64 {
 }
 
 func (pfl *Layer) Annotations() map[string]string {
-	return map[string]string{
+	res := map[string]string{
 		FilenameAnnotationKey: filepath.Base(pfl.filePath),
 		RangeAnnotationKey:    fmt.Sprintf("%d-%d", pfl.start, pfl.stop),
 	}
+	if pfl.titled {
+		res[TitleAnnotationKey] = pfl.title()
+	}
+	return res
+}
+
+// title names the file artifact tools write the segment to, the file itself for a raw segment
+// and a compressed part of it otherwise.
+func (pfl *Layer) title() string {
+	if pfl.mediaType == RawMediaType {
+		return filepath.Base(pfl.filePath)
+	}
+	return fmt.Sprintf("%s.%d-%d.zst", filepath.Base(pfl.filePath), pfl.start, pfl.stop)
 }
 
 func (pfl *Layer) Length() int64 {
@@ -1,12 +1,15 @@
 package filesegment
 
 import (
+	"context"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/throttle"
 	"github.com/stretchr/testify/require"
+	"io"
 	"runtime"
 	"testing"
 )
@@ -130,3 +133,40 @@ func TestLayer_Functionality(t *testing.T) {
 		t.Errorf("unable to append layer: %v", err)
 	}
 }
+
+func TestLayer_RawWithTitle(t *testing.T) {
+	layerFile := "testdata/disk.img"
+	raw, err := NewLayer(layerFile, WithMediaType(RawMediaType), WithTitleAnnotation())
+	require.NoError(t, err)
+
+	digest, err := raw.Digest()
+	require.NoError(t, err)
+	diffID, err := raw.DiffID()
+	require.NoError(t, err)
+	size, err := raw.Size()
+	require.NoError(t, err)
+	require.Equal(t, diffID, digest)
+	require.Equal(t, raw.Stop()+1, size)
+	require.Equal(t, "disk.img", raw.Annotations()[TitleAnnotationKey])
+
+	segment, err := NewLayer(layerFile, WithRange(0, 9), WithTitleAnnotation())
+	require.NoError(t, err)
+	require.Equal(t, "disk.img.0-9.zst", segment.Annotations()[TitleAnnotationKey])
+
+	d, err := ParseDescriptor(v1.Descriptor{MediaType: RawMediaType, Digest: digest, Annotations: raw.Annotations()}, diffID)
+	require.NoError(t, err)
+	require.Equal(t, RawMediaType, d.MediaType())
+	require.Equal(t, raw.Annotations(), d.Annotations())
+}
+
+func TestLayer_LimiterWaitIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	layer, err := NewLayer("testdata/disk.img", WithLimiter(ctx, throttle.NewLimiter(throttle.Constant(1))))
+	require.NoError(t, err)
+	rc, err := layer.Uncompressed()
+	require.NoError(t, err)
+	defer rc.Close()
+	_, err = io.ReadAll(rc)
+	require.ErrorIs(t, err, context.Canceled)
+}
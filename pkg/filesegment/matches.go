@@ -5,8 +5,13 @@ import (
 )
 
 func Matches(d *Descriptor, dir string, opt ...LayerOpt) bool {
-	fname := filepath.Join(dir, d.filename)
-	l, err := NewLayer(fname, append(opt, WithRange(d.start, d.stop))...)
+	return MatchesFile(d, filepath.Join(dir, d.filename), opt...)
+}
+
+// MatchesFile checks whether range of the segment in file at given path has the expected content,
+// the file does not have to be named after the segment.
+func MatchesFile(d *Descriptor, path string, opt ...LayerOpt) bool {
+	l, err := NewLayer(path, append(opt, WithRange(d.start, d.stop))...)
 	if err != nil {
 		return false
 	}
@@ -1,5 +1,11 @@
 package filesegment
 
+import (
+	"context"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/throttle"
+)
+
 type LayerOpt func(*Layer)
 
 func WithRange(start, stop int64) LayerOpt {
@@ -14,3 +20,24 @@ func WithLogFunction(log func(fmt string, args ...any)) LayerOpt {
 		l.log = log
 	}
 }
+
+// WithLimiter throttles reading of the file, e.g. while hashing. Waiting for the limiter ends when ctx is done.
+func WithLimiter(ctx context.Context, limiter *throttle.Limiter) LayerOpt {
+	return func(l *Layer) {
+		l.ctx = ctx
+		l.limiter = limiter
+	}
+}
+
+func WithMediaType(mediaType types.MediaType) LayerOpt {
+	return func(l *Layer) {
+		l.mediaType = mediaType
+	}
+}
+
+// WithTitleAnnotation adds the title annotation used by artifact tools to name files.
+func WithTitleAnnotation() LayerOpt {
+	return func(l *Layer) {
+		l.titled = true
+	}
+}
@@ -0,0 +1,152 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Context is the registry and user of the active context. Password is only set by configs written
+// before secrets were kept in a Store.
+type Context struct {
+	Name     string
+	Registry string
+	User     string
+	Password string
+}
+
+// Host returns the registry host of the context, whose registry may be followed by a namespace.
+func (c Context) Host() string {
+	host, _, _ := strings.Cut(c.Registry, "/")
+	if reg, err := name.NewRegistry(host); err == nil {
+		return reg.Name()
+	}
+	return host
+}
+
+// ServerURL is the key of the context secret in the Store. It differs from the registry host, so the secret
+// does not replace credentials of 'docker login' for the same registry.
+func (c Context) ServerURL() string {
+	return "geranos://" + c.Host() + "/" + c.Name
+}
+
+type Option func(*Keychain)
+
+// WithStore sets where the secret of the context is kept.
+func WithStore(store Store) Option {
+	return func(k *Keychain) {
+		k.store = store
+	}
+}
+
+// WithFallback sets keychain used for other registries, or if the context has no credentials.
+func WithFallback(fallback authn.Keychain) Option {
+	return func(k *Keychain) {
+		k.fallback = fallback
+	}
+}
+
+// WithVerbose logs which credential source was used, once per registry.
+func WithVerbose(verbose bool) Option {
+	return func(k *Keychain) {
+		k.verbose = verbose
+	}
+}
+
+// Keychain resolves credentials of the context registry from the context, other registries
+// are resolved by the fallback, the Docker config by default.
+type Keychain struct {
+	context  Context
+	store    Store
+	fallback authn.Keychain
+	verbose  bool
+
+	mu      sync.Mutex
+	auths   map[string]authn.Authenticator
+	sources map[string]string
+}
+
+func New(context Context, opt ...Option) *Keychain {
+	k := &Keychain{
+		context:  context,
+		fallback: authn.DefaultKeychain,
+		auths:    make(map[string]authn.Authenticator),
+		sources:  make(map[string]string),
+	}
+	for _, o := range opt {
+		o(k)
+	}
+	return k
+}
+
+// Resolve returns credentials of the registry, they are resolved once and cached, so credential helpers
+// are not run for every request.
+func (k *Keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if auth, ok := k.auths[registry]; ok {
+		return auth, nil
+	}
+	auth, source, err := k.resolve(target)
+	if err != nil {
+		return nil, err
+	}
+	k.auths[registry] = auth
+	k.sources[registry] = source
+	if k.verbose {
+		log.Printf("using credentials for %v from %v", registry, source)
+	}
+	return auth, nil
+}
+
+func (k *Keychain) resolve(target authn.Resource) (authn.Authenticator, string, error) {
+	registry := target.RegistryStr()
+	if k.context.Registry != "" && k.context.User != "" && k.context.Host() == registry {
+		if auth, source := k.resolveContext(); auth != nil {
+			return auth, source, nil
+		}
+	}
+	auth, err := k.fallback.Resolve(target)
+	if err != nil {
+		return nil, "", err
+	}
+	if auth == authn.Anonymous {
+		return auth, "anonymous access", nil
+	}
+	return auth, "Docker config", nil
+}
+
+// resolveContext returns nil authenticator if the secret is not found, so the fallback is used.
+func (k *Keychain) resolveContext() (authn.Authenticator, string) {
+	if k.context.Password != "" {
+		auth := authn.FromConfig(authn.AuthConfig{Username: k.context.User, Password: k.context.Password})
+		return auth, fmt.Sprintf("context '%v' (password in config file)", k.context.Name)
+	}
+	if k.store == nil {
+		return nil, ""
+	}
+	username, secret, err := k.store.Get(k.context.ServerURL())
+	if errors.Is(err, ErrNotFound) || (err == nil && username != k.context.User) {
+		return nil, ""
+	}
+	if err != nil {
+		if k.verbose {
+			log.Printf("%v, falling back to Docker config", err)
+		}
+		return nil, ""
+	}
+	auth := authn.FromConfig(authn.AuthConfig{Username: username, Password: secret})
+	return auth, fmt.Sprintf("context '%v' (%v)", k.context.Name, k.store.Name())
+}
+
+// Source returns where credentials of the registry were resolved from, or empty string if they were not resolved.
+func (k *Keychain) Source(registry string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.sources[registry]
+}
@@ -0,0 +1,156 @@
+package keychain
+
+import (
+	"bytes"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+type memoryStore map[string][2]string
+
+func (m memoryStore) Name() string {
+	return "memory"
+}
+
+func (m memoryStore) Get(registry string) (string, string, error) {
+	creds, ok := m[registry]
+	if !ok {
+		return "", "", ErrNotFound
+	}
+	return creds[0], creds[1], nil
+}
+
+func (m memoryStore) Store(registry, username, secret string) error {
+	m[registry] = [2]string{username, secret}
+	return nil
+}
+
+func (m memoryStore) Erase(registry string) error {
+	delete(m, registry)
+	return nil
+}
+
+type staticKeychain struct {
+	auth authn.Authenticator
+}
+
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return k.auth, nil
+}
+
+func resolve(t *testing.T, kc authn.Keychain, registry string) *authn.AuthConfig {
+	reg, err := name.NewRegistry(registry)
+	require.NoError(t, err)
+	auth, err := kc.Resolve(reg)
+	require.NoError(t, err)
+	cfg, err := auth.Authorization()
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestKeychainResolvesContextCredentials(t *testing.T) {
+	ctx := Context{Name: "work", Registry: "oci.example.com/team", User: "ci"}
+	assert.Equal(t, "geranos://oci.example.com/work", ctx.ServerURL())
+	store := memoryStore{}
+	require.NoError(t, store.Store(ctx.ServerURL(), "ci", "secret"))
+	// entry of 'docker login' for the same registry
+	require.NoError(t, store.Store("oci.example.com", "ci", "docker-login"))
+	fallback := staticKeychain{auth: authn.FromConfig(authn.AuthConfig{Username: "docker", Password: "docker-secret"})}
+	kc := New(ctx, WithStore(store), WithFallback(fallback))
+
+	cfg := resolve(t, kc, "oci.example.com")
+	assert.Equal(t, "ci", cfg.Username)
+	assert.Equal(t, "secret", cfg.Password)
+	assert.Equal(t, "context 'work' (memory)", kc.Source("oci.example.com"))
+
+	cfg = resolve(t, kc, "ghcr.io")
+	assert.Equal(t, "docker", cfg.Username)
+	assert.Equal(t, "Docker config", kc.Source("ghcr.io"))
+
+	// secret of another user is not used
+	require.NoError(t, store.Store(ctx.ServerURL(), "someone-else", "other"))
+	cfg = resolve(t, New(ctx, WithStore(store), WithFallback(fallback)), "oci.example.com")
+	assert.Equal(t, "docker", cfg.Username)
+
+	// without the context secret the entry of 'docker login' is left to the fallback
+	require.NoError(t, store.Erase(ctx.ServerURL()))
+	kc = New(ctx, WithStore(store), WithFallback(staticKeychain{auth: authn.Anonymous}))
+	resolve(t, kc, "oci.example.com")
+	assert.Equal(t, "anonymous access", kc.Source("oci.example.com"))
+	assert.Contains(t, store, "oci.example.com")
+}
+
+type countingStore struct {
+	memoryStore
+	gets int
+}
+
+func (s *countingStore) Get(registry string) (string, string, error) {
+	s.gets++
+	return s.memoryStore.Get(registry)
+}
+
+func TestKeychainCachesCredentials(t *testing.T) {
+	ctx := Context{Name: "work", Registry: "oci.example.com", User: "ci"}
+	store := &countingStore{memoryStore: memoryStore{}}
+	require.NoError(t, store.Store(ctx.ServerURL(), "ci", "secret"))
+	kc := New(ctx, WithStore(store), WithFallback(staticKeychain{auth: authn.Anonymous}))
+
+	for i := 0; i < 3; i++ {
+		cfg := resolve(t, kc, "oci.example.com")
+		assert.Equal(t, "secret", cfg.Password)
+	}
+	assert.Equal(t, 1, store.gets)
+}
+
+func TestKeychainUsesPasswordFromConfig(t *testing.T) {
+	ctx := Context{Name: "legacy", Registry: "docker.io", User: "me", Password: "plain"}
+	kc := New(ctx, WithStore(memoryStore{}), WithFallback(staticKeychain{auth: authn.Anonymous}))
+	cfg := resolve(t, kc, "index.docker.io")
+	assert.Equal(t, "plain", cfg.Password)
+	assert.Equal(t, "context 'legacy' (password in config file)", kc.Source("index.docker.io"))
+}
+
+type fakeProgram struct {
+	args   []string
+	input  *bytes.Buffer
+	output string
+}
+
+func (p *fakeProgram) Output() ([]byte, error) {
+	return []byte(p.output), nil
+}
+
+func (p *fakeProgram) Input(in io.Reader) {
+	p.input = &bytes.Buffer{}
+	p.input.ReadFrom(in)
+}
+
+func TestHelperStore(t *testing.T) {
+	var calls []*fakeProgram
+	store := NewHelperStoreWithProgram("test", func(args ...string) client.Program {
+		p := &fakeProgram{args: args}
+		if args[0] == "get" {
+			p.output = `{"ServerURL":"oci.example.com","Username":"ci","Secret":"secret"}`
+		}
+		calls = append(calls, p)
+		return p
+	})
+	assert.Equal(t, "docker-credential-test", store.Name())
+
+	require.NoError(t, store.Store("oci.example.com", "ci", "secret"))
+	require.Len(t, calls, 1)
+	assert.Equal(t, []string{"store"}, calls[0].args)
+	assert.Contains(t, calls[0].input.String(), `"Secret":"secret"`)
+
+	username, secret, err := store.Get("oci.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "ci", username)
+	assert.Equal(t, "secret", secret)
+	assert.Equal(t, "oci.example.com", calls[1].input.String())
+}
@@ -0,0 +1,103 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// ErrNotFound is returned by Store when it has no secret for the registry.
+var ErrNotFound = errors.New("credentials not found")
+
+// Store keeps secrets of contexts outside of the config file.
+type Store interface {
+	Name() string
+	Get(registry string) (username, secret string, err error)
+	Store(registry, username, secret string) error
+	Erase(registry string) error
+}
+
+// HelperStore keeps secrets with a Docker credential helper, e.g. docker-credential-osxkeychain.
+type HelperStore struct {
+	helper  string
+	program client.ProgramFunc
+}
+
+// NewHelperStore uses the docker-credential-<helper> program found in PATH.
+func NewHelperStore(helper string) *HelperStore {
+	return NewHelperStoreWithProgram(helper, client.NewShellProgramFunc(helperProgram(helper)))
+}
+
+// NewHelperStoreWithProgram uses given program to talk to the helper.
+func NewHelperStoreWithProgram(helper string, program client.ProgramFunc) *HelperStore {
+	return &HelperStore{helper: helper, program: program}
+}
+
+func helperProgram(helper string) string {
+	return "docker-credential-" + helper
+}
+
+func (s *HelperStore) Name() string {
+	return helperProgram(s.helper)
+}
+
+func (s *HelperStore) Get(registry string) (string, string, error) {
+	creds, err := client.Get(s.program, registry)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return "", "", ErrNotFound
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get credentials of '%v' from %v: %w", registry, s.Name(), err)
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+func (s *HelperStore) Store(registry, username, secret string) error {
+	err := client.Store(s.program, &credentials.Credentials{ServerURL: registry, Username: username, Secret: secret})
+	if err != nil {
+		return fmt.Errorf("unable to store credentials of '%v' in %v: %w", registry, s.Name(), err)
+	}
+	return nil
+}
+
+func (s *HelperStore) Erase(registry string) error {
+	err := client.Erase(s.program, registry)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("unable to erase credentials of '%v' from %v: %w", registry, s.Name(), err)
+	}
+	return nil
+}
+
+// platformHelpers lists helpers backed by the OS keychain, in order of preference.
+func platformHelpers() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"osxkeychain"}
+	case "windows":
+		return []string{"wincred"}
+	default:
+		return []string{"secretservice", "pass"}
+	}
+}
+
+// DefaultHelper returns credential helper configured as 'credsStore' in the Docker config, or the helper
+// of the OS keychain if its program is installed. It returns empty string if no helper is available.
+func DefaultHelper() string {
+	if cf, err := config.Load(os.Getenv("DOCKER_CONFIG")); err == nil && cf.CredentialsStore != "" {
+		return cf.CredentialsStore
+	}
+	for _, helper := range platformHelpers() {
+		if _, err := exec.LookPath(helperProgram(helper)); err == nil {
+			return helper
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package layout
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/macvmio/geranos/pkg/artifact"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"os"
+	"path/filepath"
+)
+
+// prepareForeignWrite creates directory for an image which is not made of geranos segments, and deletes
+// its local manifest, which would not describe its files anymore.
+func (lm *Mapper) prepareForeignWrite(ref name.Reference) (string, error) {
+	destinationDir := lm.refToDir(ref)
+	if err := os.MkdirAll(destinationDir, 0o777); err != nil {
+		return "", fmt.Errorf("unable to create directory for writing: %w", err)
+	}
+	for _, filename := range []string{dirimage.LocalManifestFilename, dirimage.LocalConfigFilename} {
+		if err := os.Remove(filepath.Join(destinationDir, filename)); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("unable to delete '%v': %w", filename, err)
+		}
+	}
+	return destinationDir, nil
+}
+
+// WriteArtifact writes files of a generic artifact to the directory of the reference, see artifact.Extract.
+func (lm *Mapper) WriteArtifact(ctx context.Context, img v1.Image, ref name.Reference, opt ...artifact.Option) error {
+	destinationDir, err := lm.prepareForeignWrite(ref)
+	if err != nil {
+		return err
+	}
+	reserved := artifact.WithReservedNames(dirimage.LocalManifestFilename, dirimage.LocalConfigFilename, OriginFilename)
+	written, err := artifact.Extract(ctx, img, destinationDir, append([]artifact.Option{reserved}, opt...)...)
+	if err != nil {
+		return fmt.Errorf("unable to extract artifact to '%v': %w", destinationDir, err)
+	}
+	st := Statistics{}
+	st.BytesWrittenCount.Store(written)
+	lm.stats.Add(&st)
+	return nil
+}
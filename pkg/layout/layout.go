@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 const OSWindows = "windows"
@@ -193,7 +194,14 @@ func (lm *Mapper) Adopt(src string, ref name.Reference, failIfContainsSubdirecto
 	if failIfContainsSubdirectories {
 		fmt.Printf("warning: subdirectories will be ignored")
 	}
-	return duplicator.CloneDirectory(src, lm.refToDir(ref), false)
+	if err := duplicator.CloneDirectory(src, lm.refToDir(ref), false); err != nil {
+		return err
+	}
+	source, err := filepath.Abs(src)
+	if err != nil {
+		source = src
+	}
+	return lm.WriteOrigin(ref, &Origin{Operation: OperationAdopt, Source: source})
 }
 
 type Properties struct {
@@ -254,7 +262,18 @@ func (lm *Mapper) List() ([]Properties, error) {
 }
 
 func (lm *Mapper) Clone(src name.Reference, dst name.Reference) error {
-	return duplicator.CloneDirectory(lm.refToDir(src), lm.refToDir(dst), true)
+	if err := duplicator.CloneDirectory(lm.refToDir(src), lm.refToDir(dst), true); err != nil {
+		return err
+	}
+	origin, err := lm.Origin(src)
+	if err != nil || origin == nil {
+		origin = &Origin{}
+	}
+	origin.Operation = OperationClone
+	origin.Parent = src.String()
+	origin.Timestamp = time.Time{}
+	origin.GeranosVersion = ""
+	return lm.WriteOrigin(dst, origin)
 }
 
 func (lm *Mapper) Remove(src name.Reference) error {
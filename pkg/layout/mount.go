@@ -14,10 +14,41 @@ func NewMountableImage(image v1.Image, ref name.Reference) *MountableImage {
 	}
 }
 
+// NewMountableImageWithSources makes each blob mounted from its own source, blobs without a source are mounted
+// from ref, or uploaded if ref is nil.
+func NewMountableImageWithSources(image v1.Image, ref name.Reference, sources map[v1.Hash]name.Reference) *MountableImage {
+	return &MountableImage{
+		Image:     image,
+		Reference: ref,
+		Sources:   sources,
+	}
+}
+
 type MountableImage struct {
 	v1.Image
 
 	Reference name.Reference
+	Sources   map[v1.Hash]name.Reference
+}
+
+func (mi *MountableImage) wrap(l v1.Layer) (v1.Layer, error) {
+	ref := mi.Reference
+	if len(mi.Sources) > 0 {
+		d, err := l.Digest()
+		if err != nil {
+			return nil, err
+		}
+		if source, ok := mi.Sources[d]; ok {
+			ref = source
+		}
+	}
+	if ref == nil {
+		return l, nil
+	}
+	return &remote.MountableLayer{
+		Layer:     l,
+		Reference: ref,
+	}, nil
 }
 
 func (mi *MountableImage) Layers() ([]v1.Layer, error) {
@@ -27,10 +58,11 @@ func (mi *MountableImage) Layers() ([]v1.Layer, error) {
 	}
 	mls := make([]v1.Layer, 0, len(ls))
 	for _, l := range ls {
-		mls = append(mls, &remote.MountableLayer{
-			Layer:     l,
-			Reference: mi.Reference,
-		})
+		ml, err := mi.wrap(l)
+		if err != nil {
+			return nil, err
+		}
+		mls = append(mls, ml)
 	}
 	return mls, nil
 }
@@ -40,10 +72,7 @@ func (mi *MountableImage) LayerByDigest(d v1.Hash) (v1.Layer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &remote.MountableLayer{
-		Layer:     l,
-		Reference: mi.Reference,
-	}, nil
+	return mi.wrap(l)
 }
 
 func (mi *MountableImage) LayerByDiffID(d v1.Hash) (v1.Layer, error) {
@@ -51,10 +80,7 @@ func (mi *MountableImage) LayerByDiffID(d v1.Hash) (v1.Layer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &remote.MountableLayer{
-		Layer:     l,
-		Reference: mi.Reference,
-	}, nil
+	return mi.wrap(l)
 }
 
 func (mi *MountableImage) ConfigLayer() (v1.Layer, error) {
@@ -62,8 +88,5 @@ func (mi *MountableImage) ConfigLayer() (v1.Layer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &remote.MountableLayer{
-		Layer:     l,
-		Reference: mi.Reference,
-	}, nil
+	return mi.wrap(l)
 }
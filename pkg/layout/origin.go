@@ -0,0 +1,139 @@
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// OriginFilename is the file in the image directory recording where the image came from. It starts with a dot,
+// so it is not part of the image.
+const OriginFilename = ".geranos.origin.json"
+
+// Operations which create local images, recorded in their origin.
+const (
+	OperationPull  = "pull"
+	OperationPush  = "push"
+	OperationClone = "clone"
+	OperationAdopt = "adopt"
+	OperationLoad  = "load"
+)
+
+const modulePath = "github.com/macvmio/geranos"
+
+// Version of geranos recorded in origins. It is read from build info, the CLI sets the version it was built with.
+var Version = buildVersion()
+
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// Origin describes where a local image came from.
+type Origin struct {
+	Operation string `json:"operation"`
+	// Reference is the remote image the local image was pulled from or pushed to.
+	Reference string `json:"reference,omitempty"`
+	// Digest is the manifest digest at the time of the operation, of an index if the image was selected from one.
+	Digest string `json:"digest,omitempty"`
+	// Parent is the local image a clone was made from, its origin is kept in other fields.
+	Parent string `json:"parent,omitempty"`
+	// Source is the directory of an adopted image or the layout a loaded image was read from.
+	Source         string    `json:"source,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+	GeranosVersion string    `json:"geranos_version,omitempty"`
+}
+
+// WriteOrigin records the origin of the local image, timestamp and version are set if missing.
+func (lm *Mapper) WriteOrigin(ref name.Reference, origin *Origin) error {
+	if origin.Timestamp.IsZero() {
+		origin.Timestamp = time.Now().UTC()
+	}
+	if origin.GeranosVersion == "" {
+		origin.GeranosVersion = Version
+	}
+	data, err := json.MarshalIndent(origin, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal origin: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(lm.refToDir(ref), OriginFilename), data, 0o644); err != nil {
+		return fmt.Errorf("unable to write origin of '%v': %w", ref, err)
+	}
+	return nil
+}
+
+// Origin returns the origin of the local image, or nil if it is not known.
+func (lm *Mapper) Origin(ref name.Reference) (*Origin, error) {
+	data, err := os.ReadFile(filepath.Join(lm.refToDir(ref), OriginFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read origin of '%v': %w", ref, err)
+	}
+	var origin Origin
+	if err := json.Unmarshal(data, &origin); err != nil {
+		return nil, fmt.Errorf("unable to parse origin of '%v': %w", ref, err)
+	}
+	return &origin, nil
+}
+
+// MountSources finds local images sharing segments with the image, whose origin is another repository
+// of the same registry as the destination. It returns the origin likely having each shared blob.
+func (lm *Mapper) MountSources(img v1.Image, dst name.Reference) (map[v1.Hash]name.Reference, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	wanted := make(map[v1.Hash]bool, len(manifest.Layers))
+	for _, l := range manifest.Layers {
+		wanted[l.Digest] = true
+	}
+	refs, err := lm.Images()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list local images: %w", err)
+	}
+	res := make(map[v1.Hash]name.Reference)
+	for _, ref := range refs {
+		origin, err := lm.Origin(ref)
+		if err != nil || origin == nil {
+			continue
+		}
+		originRef, err := name.ParseReference(origin.Reference, name.StrictValidation)
+		if err != nil || originRef.Context().RegistryStr() != dst.Context().RegistryStr() ||
+			originRef.Context().RepositoryStr() == dst.Context().RepositoryStr() {
+			continue
+		}
+		raw, err := lm.RawManifest(ref)
+		if err != nil {
+			continue
+		}
+		local, err := v1.ParseManifest(bytes.NewReader(raw))
+		if err != nil {
+			continue
+		}
+		for _, l := range local.Layers {
+			if _, found := res[l.Digest]; wanted[l.Digest] && !found {
+				res[l.Digest] = originRef
+			}
+		}
+	}
+	return res, nil
+}
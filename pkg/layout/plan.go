@@ -0,0 +1,91 @@
+package layout
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/filesegment"
+	"github.com/macvmio/geranos/pkg/sketch"
+	"path/filepath"
+)
+
+// FileWritePlan extends sketch.FilePlan with segments which already have expected content locally.
+type FileWritePlan struct {
+	sketch.FilePlan
+	MatchingSegments          int
+	BytesToDownload           int64
+	CompressedBytesToDownload int64
+	// SegmentsToDownload are segments without expected content locally.
+	SegmentsToDownload []*filesegment.Descriptor
+}
+
+// WritePlan describes what Write would do for the image, without modifying anything.
+type WritePlan struct {
+	Reference name.Reference
+	Directory string
+	// UpToDate is set when local image has the same digest, so nothing would be written.
+	UpToDate                  bool
+	Files                     []*FileWritePlan
+	BytesToDownload           int64
+	CompressedBytesToDownload int64
+}
+
+// PlanWrite runs the same clone candidate selection and segment checks as Write, but only reports them.
+func (lm *Mapper) PlanWrite(ctx context.Context, img v1.Image, ref name.Reference) (*WritePlan, error) {
+	destinationDir := lm.refToDir(ref)
+	res := &WritePlan{Reference: ref, Directory: destinationDir}
+
+	originalDigest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read origin manifest: %w", err)
+	}
+	localImg, err := dirimage.Read(ctx, destinationDir, dirimage.WithOmitLayersContent())
+	if err == nil {
+		localDigest, err := localImg.Digest()
+		if err == nil && localDigest == originalDigest {
+			res.UpToDate = true
+			return res, nil
+		}
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config file: %w", err)
+	}
+	plan, err := lm.sketcher.Plan(destinationDir, *manifest, configFile.RootFS.DiffIDs)
+	if err != nil {
+		return nil, err
+	}
+	compressedSizes := make(map[v1.Hash]int64, len(manifest.Layers))
+	for _, l := range manifest.Layers {
+		compressedSizes[l.Digest] = l.Size
+	}
+
+	layerOpts := append(dirimage.LayerOptions(ctx, lm.opts...), filesegment.WithLogFunction(func(fmt string, args ...any) {}))
+	for _, fp := range plan.Files {
+		fwp := &FileWritePlan{FilePlan: *fp}
+		localPath := fp.CloneSource
+		if fp.Exists {
+			localPath = filepath.Join(destinationDir, fp.Filename)
+		}
+		for _, d := range fp.Segments {
+			if localPath != "" && filesegment.MatchesFile(d, localPath, layerOpts...) {
+				fwp.MatchingSegments++
+				continue
+			}
+			fwp.SegmentsToDownload = append(fwp.SegmentsToDownload, d)
+			fwp.BytesToDownload += d.Length()
+			fwp.CompressedBytesToDownload += compressedSizes[d.Digest()]
+		}
+		res.Files = append(res.Files, fwp)
+		res.BytesToDownload += fwp.BytesToDownload
+		res.CompressedBytesToDownload += fwp.CompressedBytesToDownload
+	}
+	return res, nil
+}
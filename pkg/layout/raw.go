@@ -0,0 +1,59 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/filesegment"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Images returns references of all local images that have a manifest.
+func (lm *Mapper) Images() ([]name.Reference, error) {
+	res := make([]name.Reference, 0)
+	err := filepath.WalkDir(lm.rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != dirimage.LocalManifestFilename {
+			return nil
+		}
+		ref, err := lm.dirToRef(filepath.Dir(path))
+		if err != nil {
+			return nil
+		}
+		res = append(res, ref)
+		return nil
+	})
+	return res, err
+}
+
+// RawManifest returns the manifest stored alongside the local image, byte for byte as it was written.
+func (lm *Mapper) RawManifest(ref name.Reference) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(lm.refToDir(ref), dirimage.LocalManifestFilename))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest of '%v': %w", ref, err)
+	}
+	return data, nil
+}
+
+// RawConfigFile returns the config stored alongside the local image, byte for byte as it was written.
+func (lm *Mapper) RawConfigFile(ref name.Reference) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(lm.refToDir(ref), dirimage.LocalConfigFilename))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config of '%v': %w", ref, err)
+	}
+	return data, nil
+}
+
+// SegmentLayer returns a layer backed by the range of a local file described by d.
+func (lm *Mapper) SegmentLayer(ref name.Reference, d *filesegment.Descriptor, opt ...filesegment.LayerOpt) (*filesegment.Layer, error) {
+	fpath := filepath.Join(lm.refToDir(ref), d.Filename())
+	return filesegment.NewLayer(fpath, append(opt, filesegment.WithRange(d.Start(), d.Stop()), filesegment.WithMediaType(d.MediaType()))...)
+}
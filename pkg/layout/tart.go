@@ -0,0 +1,27 @@
+package layout
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/macvmio/geranos/pkg/tart"
+)
+
+// WriteTart writes files of the image pushed by Tart to the directory of the reference. The directory has no manifest
+// afterward, until it is rehashed into geranos segments.
+func (lm *Mapper) WriteTart(ctx context.Context, img v1.Image, ref name.Reference, opt ...tart.Option) error {
+	destinationDir, err := lm.prepareForeignWrite(ref)
+	if err != nil {
+		return err
+	}
+	written, skipped, err := tart.Import(ctx, img, destinationDir, opt...)
+	if err != nil {
+		return fmt.Errorf("unable to import Tart image to '%v': %w", destinationDir, err)
+	}
+	st := Statistics{}
+	st.BytesWrittenCount.Store(written)
+	st.BytesSkippedCount.Store(skipped)
+	lm.stats.Add(&st)
+	return nil
+}
@@ -0,0 +1,294 @@
+package lz4
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FrameMagic is the start of LZ4 frames, as written by the lz4 command line tool.
+const FrameMagic = 0x184D2204
+
+// Apple compression library writes LZ4 as blocks with these headers, e.g. NSData.compressed(using: .lz4) in Tart.
+var (
+	appleCompressedBlock   = []byte("bv41")
+	appleUncompressedBlock = []byte("bv4-")
+	appleEndOfStream       = []byte("bv4$")
+)
+
+// windowSize is the maximal distance of a match, blocks may refer to data of previous blocks within it.
+const windowSize = 64 * 1024
+
+// maxBlockSize protects against corrupted headers, LZ4 frames and Apple streams use much smaller blocks.
+const maxBlockSize = 64 * 1024 * 1024
+
+var ErrCorrupted = errors.New("corrupted lz4 data")
+
+type reader struct {
+	src     *bufio.Reader
+	history []byte
+	pending []byte
+
+	// state of the current LZ4 frame
+	inFrame          bool
+	blockChecksum    bool
+	contentChecksum  bool
+	independentBlock bool
+	endOfStream      bool
+}
+
+// NewReader returns reader decompressing LZ4 frames or Apple LZ4 streams, which may be concatenated.
+func NewReader(r io.Reader) io.Reader {
+	return &reader{src: bufio.NewReaderSize(r, 1<<20)}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.endOfStream {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// next decodes the next block into pending, it may be empty after frame headers and end marks.
+func (r *reader) next() error {
+	if r.inFrame {
+		return r.nextFrameBlock()
+	}
+	header, err := r.src.Peek(4)
+	if err == io.EOF && len(header) == 0 {
+		r.endOfStream = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read block header: %w", unexpected(err))
+	}
+	switch {
+	case bytes.Equal(header, appleCompressedBlock):
+		return r.nextAppleBlock(true)
+	case bytes.Equal(header, appleUncompressedBlock):
+		return r.nextAppleBlock(false)
+	case bytes.Equal(header, appleEndOfStream):
+		_, err := r.src.Discard(4)
+		r.history = r.history[:0]
+		return err
+	}
+	magic := binary.LittleEndian.Uint32(header)
+	switch {
+	case magic == FrameMagic:
+		return r.frameHeader()
+	case magic&0xFFFFFFF0 == 0x184D2A50:
+		return r.skippableFrame()
+	}
+	return fmt.Errorf("%w: unknown header %x", ErrCorrupted, header)
+}
+
+func (r *reader) nextAppleBlock(compressed bool) error {
+	headerSize := 8
+	if compressed {
+		headerSize = 12
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r.src, header); err != nil {
+		return fmt.Errorf("unable to read block header: %w", unexpected(err))
+	}
+	decodedSize := binary.LittleEndian.Uint32(header[4:8])
+	encodedSize := decodedSize
+	if compressed {
+		encodedSize = binary.LittleEndian.Uint32(header[8:12])
+	}
+	block, err := r.readBlock(encodedSize)
+	if err != nil {
+		return err
+	}
+	if !compressed {
+		return r.emit(block)
+	}
+	decoded, err := decodeBlock(block, r.history, int(decodedSize))
+	if err != nil {
+		return err
+	}
+	if len(decoded) != int(decodedSize) {
+		return fmt.Errorf("%w: block decoded to %d bytes instead of %d", ErrCorrupted, len(decoded), decodedSize)
+	}
+	return r.emit(decoded)
+}
+
+func (r *reader) frameHeader() error {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r.src, header); err != nil {
+		return fmt.Errorf("unable to read frame header: %w", unexpected(err))
+	}
+	flags := header[4]
+	if flags>>6 != 1 {
+		return fmt.Errorf("%w: unsupported frame version %d", ErrCorrupted, flags>>6)
+	}
+	if flags&0x01 != 0 {
+		return errors.New("lz4 frames with dictionary are not supported")
+	}
+	r.independentBlock = flags&0x20 != 0
+	r.blockChecksum = flags&0x10 != 0
+	r.contentChecksum = flags&0x04 != 0
+	// content size, if present, and header checksum are not verified
+	skip := 1
+	if flags&0x08 != 0 {
+		skip += 8
+	}
+	if _, err := r.src.Discard(skip); err != nil {
+		return fmt.Errorf("unable to read frame header: %w", unexpected(err))
+	}
+	r.inFrame = true
+	r.history = r.history[:0]
+	return nil
+}
+
+func (r *reader) nextFrameBlock() error {
+	var header [4]byte
+	if _, err := io.ReadFull(r.src, header[:]); err != nil {
+		return fmt.Errorf("unable to read block header: %w", unexpected(err))
+	}
+	size := binary.LittleEndian.Uint32(header[:])
+	if size == 0 {
+		r.inFrame = false
+		r.history = r.history[:0]
+		if r.contentChecksum {
+			if _, err := r.src.Discard(4); err != nil {
+				return fmt.Errorf("unable to read content checksum: %w", unexpected(err))
+			}
+		}
+		return nil
+	}
+	compressed := size&0x80000000 == 0
+	block, err := r.readBlock(size & 0x7FFFFFFF)
+	if err != nil {
+		return err
+	}
+	if r.blockChecksum {
+		if _, err := r.src.Discard(4); err != nil {
+			return fmt.Errorf("unable to read block checksum: %w", unexpected(err))
+		}
+	}
+	if r.independentBlock {
+		r.history = r.history[:0]
+	}
+	if !compressed {
+		return r.emit(block)
+	}
+	decoded, err := decodeBlock(block, r.history, 0)
+	if err != nil {
+		return err
+	}
+	return r.emit(decoded)
+}
+
+func (r *reader) skippableFrame() error {
+	var header [8]byte
+	if _, err := io.ReadFull(r.src, header[:]); err != nil {
+		return fmt.Errorf("unable to read skippable frame: %w", unexpected(err))
+	}
+	_, err := r.src.Discard(int(binary.LittleEndian.Uint32(header[4:])))
+	return unexpected(err)
+}
+
+func (r *reader) readBlock(size uint32) ([]byte, error) {
+	if size > maxBlockSize {
+		return nil, fmt.Errorf("%w: block of %d bytes", ErrCorrupted, size)
+	}
+	block := make([]byte, size)
+	if _, err := io.ReadFull(r.src, block); err != nil {
+		return nil, fmt.Errorf("unable to read block: %w", unexpected(err))
+	}
+	return block, nil
+}
+
+// emit makes decoded data available for reading, and keeps its end as history for following blocks.
+func (r *reader) emit(decoded []byte) error {
+	r.pending = decoded
+	if len(decoded) >= windowSize {
+		r.history = append(r.history[:0], decoded[len(decoded)-windowSize:]...)
+		return nil
+	}
+	r.history = append(r.history, decoded...)
+	if len(r.history) > windowSize {
+		r.history = append(r.history[:0], r.history[len(r.history)-windowSize:]...)
+	}
+	return nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decodeBlock decodes LZ4 block, whose matches may refer to the history preceding it.
+func decodeBlock(src, history []byte, sizeHint int) ([]byte, error) {
+	dst := make([]byte, len(history), len(history)+max(sizeHint, 2*len(src)))
+	copy(dst, history)
+	i := 0
+	for i < len(src) {
+		token := src[i]
+		i++
+		literals, n, err := readLength(src[i:], int(token>>4))
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		if literals > len(src)-i {
+			return nil, fmt.Errorf("%w: literals beyond end of block", ErrCorrupted)
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			break
+		}
+		if len(src)-i < 2 {
+			return nil, fmt.Errorf("%w: truncated match offset", ErrCorrupted)
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		matchLength, n, err := readLength(src[i:], int(token&0x0F))
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		matchLength += 4
+		if offset == 0 || offset > len(dst) {
+			return nil, fmt.Errorf("%w: invalid match offset %d", ErrCorrupted, offset)
+		}
+		start := len(dst) - offset
+		if offset >= matchLength {
+			dst = append(dst, dst[start:start+matchLength]...)
+			continue
+		}
+		// overlapping match repeats the last offset bytes
+		for k := 0; k < matchLength; k++ {
+			dst = append(dst, dst[start+k])
+		}
+	}
+	return dst[len(history):], nil
+}
+
+// readLength returns length extended by following bytes if it is 15, and number of bytes read.
+func readLength(src []byte, length int) (int, int, error) {
+	if length != 15 {
+		return length, 0, nil
+	}
+	for i, b := range src {
+		length += int(b)
+		if b != 255 {
+			return length, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: truncated length", ErrCorrupted)
+}
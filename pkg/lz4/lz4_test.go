@@ -0,0 +1,188 @@
+package lz4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// compressBlock is a greedy LZ4 block encoder, matches may refer to the history.
+func compressBlock(data, history []byte) []byte {
+	buf := append(append([]byte{}, history...), data...)
+	key := func(i int) uint32 { return binary.LittleEndian.Uint32(buf[i:]) }
+	table := make(map[uint32]int)
+	for i := 0; i+4 <= len(history); i++ {
+		table[key(i)] = i
+	}
+	out := make([]byte, 0)
+	anchor := len(history)
+	for i := len(history); i+4 <= len(buf); {
+		k := key(i)
+		candidate, ok := table[k]
+		table[k] = i
+		if !ok || i-candidate > 65535 {
+			i++
+			continue
+		}
+		length := 4
+		for i+length < len(buf) && buf[candidate+length] == buf[i+length] {
+			length++
+		}
+		out = appendSequence(out, buf[anchor:i], i-candidate, length)
+		i += length
+		anchor = i
+	}
+	return appendSequence(out, buf[anchor:], 0, 0)
+}
+
+func appendSequence(out, literals []byte, offset, matchLength int) []byte {
+	token := byte(min(len(literals), 15)) << 4
+	if offset > 0 {
+		token |= byte(min(matchLength-4, 15))
+	}
+	out = appendLength(append(out, token), len(literals))
+	out = append(out, literals...)
+	if offset == 0 {
+		return out
+	}
+	out = append(out, byte(offset), byte(offset>>8))
+	return appendLength(out, matchLength-4)
+}
+
+func appendLength(out []byte, n int) []byte {
+	if n < 15 {
+		return out
+	}
+	for n -= 15; n >= 255; n -= 255 {
+		out = append(out, 255)
+	}
+	return append(out, byte(n))
+}
+
+func testData(size int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"geranos ", "segment ", "disk ", "\x00\x00\x00\x00\x00\x00\x00\x00", "a"}
+	var b bytes.Buffer
+	for b.Len() < size {
+		if rnd.Intn(10) == 0 {
+			b.WriteByte(byte(rnd.Intn(256)))
+			continue
+		}
+		b.WriteString(words[rnd.Intn(len(words))])
+	}
+	return b.Bytes()[:size]
+}
+
+func appleStream(data []byte, blockSize int) []byte {
+	out := make([]byte, 0)
+	history := make([]byte, 0)
+	for start := 0; start < len(data); start += blockSize {
+		block := data[start:min(start+blockSize, len(data))]
+		if start/blockSize%3 == 2 {
+			out = append(out, appleUncompressedBlock...)
+			out = binary.LittleEndian.AppendUint32(out, uint32(len(block)))
+			out = append(out, block...)
+		} else {
+			compressed := compressBlock(block, history)
+			out = append(out, appleCompressedBlock...)
+			out = binary.LittleEndian.AppendUint32(out, uint32(len(block)))
+			out = binary.LittleEndian.AppendUint32(out, uint32(len(compressed)))
+			out = append(out, compressed...)
+		}
+		history = append(history, block...)
+		history = history[max(0, len(history)-windowSize):]
+	}
+	return append(out, appleEndOfStream...)
+}
+
+func frame(data []byte, blockSize int) []byte {
+	out := binary.LittleEndian.AppendUint32(nil, FrameMagic)
+	// version 1, linked blocks, block checksums and content checksum, then block size and header checksum
+	out = append(out, 0x54, 0x40, 0x00)
+	history := make([]byte, 0)
+	for start := 0; start < len(data); start += blockSize {
+		block := data[start:min(start+blockSize, len(data))]
+		compressed := compressBlock(block, history)
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(compressed)))
+		out = append(out, compressed...)
+		out = append(out, 0, 0, 0, 0)
+		history = append(history, block...)
+		history = history[max(0, len(history)-windowSize):]
+	}
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	return append(out, 0, 0, 0, 0)
+}
+
+func TestNewReader_AppleStream(t *testing.T) {
+	data := testData(300 * 1024)
+	stream := appleStream(data, 40*1024)
+	require.Less(t, len(stream), len(data))
+
+	// concatenated streams are decoded one after another
+	actual, err := io.ReadAll(NewReader(bytes.NewReader(append(stream, stream...))))
+	require.NoError(t, err)
+	assert.Equal(t, append(data, data...), actual)
+}
+
+func TestNewReader_Frame(t *testing.T) {
+	data := testData(200 * 1024)
+
+	actual, err := io.ReadAll(NewReader(bytes.NewReader(frame(data, 64*1024))))
+	require.NoError(t, err)
+	assert.Equal(t, data, actual)
+}
+
+func TestNewReader_OverlappingMatch(t *testing.T) {
+	// 'ab' followed by match of 10 bytes at offset 2
+	block := []byte{0x26, 'a', 'b', 2, 0}
+	stream := append(append(append([]byte{}, appleCompressedBlock...), 12, 0, 0, 0, byte(len(block)), 0, 0, 0), block...)
+
+	actual, err := io.ReadAll(NewReader(bytes.NewReader(stream)))
+	require.NoError(t, err)
+	assert.Equal(t, "abababababab", string(actual))
+}
+
+func TestNewReader_Corrupted(t *testing.T) {
+	stream := appleStream(testData(1024), 1024)
+
+	_, err := io.ReadAll(NewReader(bytes.NewReader(stream[:len(stream)-10])))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = io.ReadAll(NewReader(bytes.NewReader([]byte("not lz4 at all"))))
+	assert.ErrorIs(t, err, ErrCorrupted)
+
+	// match referring before the start of data
+	block := []byte{0x10, 'a', 5, 0}
+	stream = append(append(append([]byte{}, appleCompressedBlock...), 5, 0, 0, 0, byte(len(block)), 0, 0, 0), block...)
+	_, err = io.ReadAll(NewReader(bytes.NewReader(stream)))
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestNewReader_Golden(t *testing.T) {
+	for _, tc := range []struct {
+		file   string
+		size   int
+		sha256 string
+	}{
+		{"testdata/frame.lz4", 147264, "580fcc5956ff8b883770200b60e86ed4f68d9ba3c3460ccbf153466447988c32"},
+		{"testdata/apple.lz4", 163648, "68b153efee520302de2398222373b6b9aa1c537c036b447c913e2478e70ee575"},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			f, err := os.Open(tc.file)
+			require.NoError(t, err)
+			defer f.Close()
+			actual, err := io.ReadAll(NewReader(f))
+			require.NoError(t, err)
+			assert.Len(t, actual, tc.size)
+			sum := sha256.Sum256(actual)
+			assert.Equal(t, tc.sha256, hex.EncodeToString(sum[:]))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package ocilayout
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeImage(t *testing.T, contents ...string) v1.Image {
+	t.Helper()
+	var addendums []mutate.Addendum
+	for _, c := range contents {
+		addendums = append(addendums, mutate.Addendum{Layer: static.NewLayer([]byte(c), types.OCILayer)})
+	}
+	img, err := mutate.Append(empty.Image, addendums...)
+	require.NoError(t, err)
+	return img
+}
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
+
+func readLayer(t *testing.T, img v1.Image, h v1.Hash) (string, error) {
+	t.Helper()
+	l, err := img.LayerByDigest(h)
+	require.NoError(t, err)
+	rc, err := l.Compressed()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return string(data), err
+}
+
+func TestSaveAndOpen(t *testing.T) {
+	for _, filename := range []string{"image", "image.tar"} {
+		t.Run(filename, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), filename)
+			img := makeImage(t, "first", "second", "first")
+			ref, err := name.ParseReference("ghcr.io/macvmio/vm:15")
+			require.NoError(t, err)
+
+			stats, err := Save(img, ref, p)
+			require.NoError(t, err)
+			assert.Equal(t, 4, stats.Blobs)
+			assert.Equal(t, IsTar(p), !isDir(p))
+
+			l, err := Open(p)
+			require.NoError(t, err)
+			defer l.Close()
+			loaded, loadedRef, err := l.Image("")
+			require.NoError(t, err)
+			assert.Equal(t, "ghcr.io/macvmio/vm:15", loadedRef)
+			expectedDigest, err := img.Digest()
+			require.NoError(t, err)
+			actualDigest, err := loaded.Digest()
+			require.NoError(t, err)
+			assert.Equal(t, expectedDigest, actualDigest)
+			manifest, err := loaded.Manifest()
+			require.NoError(t, err)
+			content, err := readLayer(t, loaded, manifest.Layers[1].Digest)
+			require.NoError(t, err)
+			assert.Equal(t, "second", content)
+
+			_, _, err = l.Image("ghcr.io/macvmio/vm:15")
+			assert.NoError(t, err)
+			_, renamed, err := l.Image("localhost/copy:1")
+			require.NoError(t, err)
+			assert.Equal(t, "localhost/copy:1", renamed)
+		})
+	}
+}
+
+func TestSave_ExcludedBlobs(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "image.tar")
+	base := makeImage(t, "base")
+	img := makeImage(t, "base", "changes")
+	baseManifest, err := base.Manifest()
+	require.NoError(t, err)
+	ref, err := name.ParseReference("vm:15")
+	require.NoError(t, err)
+
+	stats, err := Save(img, ref, p, WithExcludedBlobs([]v1.Hash{baseManifest.Layers[0].Digest}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.ExcludedBlobs)
+	assert.Equal(t, int64(len("base")), stats.ExcludedBytes)
+	assert.Equal(t, 3, stats.Blobs)
+
+	l, err := Open(p)
+	require.NoError(t, err)
+	defer l.Close()
+	loaded, _, err := l.Image("vm:15")
+	require.NoError(t, err)
+	manifest, err := loaded.Manifest()
+	require.NoError(t, err)
+	_, err = readLayer(t, loaded, manifest.Layers[0].Digest)
+	assert.True(t, errors.Is(err, ErrBlobMissing))
+	content, err := readLayer(t, loaded, manifest.Layers[1].Digest)
+	require.NoError(t, err)
+	assert.Equal(t, "changes", content)
+}
+
+func TestOpen_NotALayout(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Open(dir)
+	assert.ErrorContains(t, err, "is not an OCI image layout")
+
+	index, err := json.Marshal(v1.IndexManifest{SchemaVersion: 2})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, indexFilename), index, 0o644))
+	l, err := Open(dir)
+	require.NoError(t, err)
+	_, _, err = l.Image("vm:15")
+	assert.ErrorContains(t, err, "none of them")
+}
+
+func TestOpen_VerifiesBlobs(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "image")
+	img := makeImage(t, "first", "second")
+	ref, err := name.ParseReference("vm:15")
+	require.NoError(t, err)
+	_, err = Save(img, ref, p)
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	corrupted := manifest.Layers[0].Digest
+	truncated := manifest.Layers[1].Digest
+	require.NoError(t, os.WriteFile(filepath.Join(p, blobName(corrupted)), []byte("fir5t"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(p, blobName(truncated)), []byte("sec"), 0o644))
+
+	l, err := Open(p)
+	require.NoError(t, err)
+	defer l.Close()
+	loaded, _, err := l.Image("")
+	require.NoError(t, err)
+	_, err = readLayer(t, loaded, corrupted)
+	assert.ErrorContains(t, err, "has digest")
+	_, err = readLayer(t, loaded, truncated)
+	assert.ErrorContains(t, err, "has 3 bytes, expected 6")
+
+	require.NoError(t, os.WriteFile(filepath.Join(p, blobName(manifest.Config.Digest)), []byte("{}"), 0o644))
+	_, err = loaded.RawConfigFile()
+	assert.ErrorContains(t, err, "expected")
+}
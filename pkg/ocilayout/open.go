@@ -0,0 +1,311 @@
+package ocilayout
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBlobMissing is returned for blobs excluded from the layout when it was saved.
+var ErrBlobMissing = errors.New("blob is not in the layout")
+
+// source opens files of the layout, named with forward slashes.
+type source interface {
+	open(name string) (io.ReadCloser, error)
+	Close() error
+}
+
+type dirSource struct {
+	root string
+}
+
+func (ds *dirSource) open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(ds.root, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %v", ErrBlobMissing, name)
+	}
+	return f, err
+}
+
+func (ds *dirSource) Close() error {
+	return nil
+}
+
+type section struct {
+	offset int64
+	size   int64
+}
+
+// tarSource reads files directly from the archive, which is indexed once when opened.
+type tarSource struct {
+	f     *os.File
+	files map[string]section
+}
+
+func newTarSource(p string) (*tarSource, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	ts := &tarSource{f: f, files: make(map[string]section)}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return ts, nil
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// tar reader does not read ahead, so the file is positioned at the content of the entry
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		ts.files[path.Clean(strings.TrimPrefix(hdr.Name, "./"))] = section{offset: offset, size: hdr.Size}
+	}
+}
+
+func (ts *tarSource) open(name string) (io.ReadCloser, error) {
+	s, ok := ts.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrBlobMissing, name)
+	}
+	return io.NopCloser(io.NewSectionReader(ts.f, s.offset, s.size)), nil
+}
+
+func (ts *tarSource) Close() error {
+	return ts.f.Close()
+}
+
+// Layout is an OCI image layout opened for reading.
+type Layout struct {
+	src   source
+	index *v1.IndexManifest
+}
+
+// Open opens OCI image layout, which is a directory or a tar archive.
+func Open(p string) (*Layout, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	var src source = &dirSource{root: p}
+	if !fi.IsDir() {
+		if src, err = newTarSource(p); err != nil {
+			return nil, fmt.Errorf("unable to open '%v': %w", p, err)
+		}
+	}
+	l := &Layout{src: src}
+	data, err := l.readAll(indexFilename)
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("'%v' is not an OCI image layout: %w", p, err)
+	}
+	if l.index, err = v1.ParseIndexManifest(bytes.NewReader(data)); err != nil {
+		src.Close()
+		return nil, fmt.Errorf("unable to parse %v: %w", indexFilename, err)
+	}
+	return l, nil
+}
+
+func (l *Layout) Close() error {
+	return l.src.Close()
+}
+
+func (l *Layout) readAll(name string) ([]byte, error) {
+	rc, err := l.src.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// readBlob reads the whole blob and checks it matches the descriptor.
+func (l *Layout) readBlob(d v1.Descriptor) ([]byte, error) {
+	rc, err := (&layoutBlob{layout: l, descriptor: d}).Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Has reports whether the blob is in the layout, it is not if it was excluded when saving.
+func (l *Layout) Has(h v1.Hash) bool {
+	rc, err := l.src.open(blobName(h))
+	if err != nil {
+		return false
+	}
+	rc.Close()
+	return true
+}
+
+// matches reports whether the descriptor in index.json was saved under the reference.
+func matches(d v1.Descriptor, ref string) bool {
+	if d.Annotations[ImageNameAnnotationKey] == ref || d.Annotations[RefNameAnnotationKey] == ref {
+		return true
+	}
+	parsed, err := name.ParseReference(ref)
+	return err == nil && d.Annotations[ImageNameAnnotationKey] == parsed.Name()
+}
+
+// Image returns the image saved under the reference, or the only image of the layout if none matches.
+// It returns the reference the image should be loaded as, which is the saved one if ref is empty.
+func (l *Layout) Image(ref string) (v1.Image, string, error) {
+	var found *v1.Descriptor
+	for i, d := range l.index.Manifests {
+		if ref != "" && matches(d, ref) {
+			found = &l.index.Manifests[i]
+			break
+		}
+	}
+	if found == nil {
+		if len(l.index.Manifests) != 1 {
+			return nil, "", fmt.Errorf("layout has %d images, none of them is '%v'", len(l.index.Manifests), ref)
+		}
+		found = &l.index.Manifests[0]
+	}
+	if ref == "" {
+		ref = found.Annotations[ImageNameAnnotationKey]
+	}
+	if ref == "" {
+		return nil, "", errors.New("layout does not record name of the image, it has to be given")
+	}
+	if found.MediaType.IsIndex() {
+		return nil, "", fmt.Errorf("'%v' is an image index, which is not supported", ref)
+	}
+	rawManifest, err := l.readBlob(*found)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read manifest: %w", err)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to parse manifest: %w", err)
+	}
+	img, err := partial.CompressedToImage(&layoutImage{layout: l, mediaType: found.MediaType, rawManifest: rawManifest, manifest: manifest})
+	if err != nil {
+		return nil, "", err
+	}
+	return img, ref, nil
+}
+
+type layoutImage struct {
+	layout      *Layout
+	mediaType   types.MediaType
+	rawManifest []byte
+	manifest    *v1.Manifest
+}
+
+var _ partial.CompressedImageCore = (*layoutImage)(nil)
+
+func (li *layoutImage) RawConfigFile() ([]byte, error) {
+	return li.layout.readBlob(li.manifest.Config)
+}
+
+func (li *layoutImage) MediaType() (types.MediaType, error) {
+	return li.mediaType, nil
+}
+
+func (li *layoutImage) RawManifest() ([]byte, error) {
+	return li.rawManifest, nil
+}
+
+// LayerByDigest does not open the blob, so it succeeds for excluded blobs, which may be never read.
+func (li *layoutImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	if h == li.manifest.Config.Digest {
+		return &layoutBlob{layout: li.layout, descriptor: li.manifest.Config}, nil
+	}
+	for _, d := range li.manifest.Layers {
+		if d.Digest == h {
+			return &layoutBlob{layout: li.layout, descriptor: d}, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %v is not in the manifest", h)
+}
+
+type layoutBlob struct {
+	layout     *Layout
+	descriptor v1.Descriptor
+}
+
+func (lb *layoutBlob) Digest() (v1.Hash, error) {
+	return lb.descriptor.Digest, nil
+}
+
+func (lb *layoutBlob) Compressed() (io.ReadCloser, error) {
+	rc, err := lb.layout.src.open(blobName(lb.descriptor.Digest))
+	if err != nil {
+		return nil, err
+	}
+	return newVerifyingReader(rc, lb.descriptor.Size, lb.descriptor.Digest)
+}
+
+func (lb *layoutBlob) Size() (int64, error) {
+	return lb.descriptor.Size, nil
+}
+
+func (lb *layoutBlob) MediaType() (types.MediaType, error) {
+	return lb.descriptor.MediaType, nil
+}
+
+// verifyingReader fails the read at EOF if the blob does not have the size and digest of its descriptor.
+type verifyingReader struct {
+	rc       io.ReadCloser
+	hasher   hash.Hash
+	size     int64
+	digest   v1.Hash
+	read     int64
+	verified bool
+}
+
+func newVerifyingReader(rc io.ReadCloser, size int64, digest v1.Hash) (io.ReadCloser, error) {
+	h, err := v1.Hasher(digest.Algorithm)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("unable to verify blob %v: %w", digest, err)
+	}
+	return &verifyingReader{rc: rc, hasher: h, size: size, digest: digest}, nil
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.rc.Read(p)
+	vr.hasher.Write(p[:n])
+	vr.read += int64(n)
+	if vr.read > vr.size {
+		return n, fmt.Errorf("blob %v has more than %d bytes", vr.digest, vr.size)
+	}
+	if err == io.EOF && !vr.verified {
+		if vr.read != vr.size {
+			return n, fmt.Errorf("blob %v has %d bytes, expected %d", vr.digest, vr.read, vr.size)
+		}
+		actual := v1.Hash{Algorithm: vr.digest.Algorithm, Hex: hex.EncodeToString(vr.hasher.Sum(nil))}
+		if actual != vr.digest {
+			return n, fmt.Errorf("blob %v has digest %v", vr.digest, actual)
+		}
+		vr.verified = true
+	}
+	return n, err
+}
+
+func (vr *verifyingReader) Close() error {
+	return vr.rc.Close()
+}
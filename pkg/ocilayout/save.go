@@ -0,0 +1,258 @@
+package ocilayout
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RefNameAnnotationKey holds the tag of the image in index.json, ImageNameAnnotationKey its full reference,
+// as written by containerd.
+const RefNameAnnotationKey = "org.opencontainers.image.ref.name"
+const ImageNameAnnotationKey = "io.containerd.image.name"
+
+const layoutFilename = "oci-layout"
+const indexFilename = "index.json"
+const layoutContent = `{"imageLayoutVersion":"1.0.0"}`
+
+// Statistics describes blobs written by Save, and blobs left out because they were excluded.
+type Statistics struct {
+	Blobs         int
+	Bytes         int64
+	ExcludedBlobs int
+	ExcludedBytes int64
+}
+
+type options struct {
+	excluded map[v1.Hash]bool
+}
+
+type Option func(o *options)
+
+// WithExcludedBlobs makes Save leave out layers with given digests, e.g. segments of a base image
+// which is already present where the layout is loaded.
+func WithExcludedBlobs(digests []v1.Hash) Option {
+	return func(o *options) {
+		for _, d := range digests {
+			o.excluded[d] = true
+		}
+	}
+}
+
+func makeOptions(opt ...Option) *options {
+	res := &options{
+		excluded: make(map[v1.Hash]bool),
+	}
+	for _, o := range opt {
+		o(res)
+	}
+	return res
+}
+
+// IsTar reports whether the layout at the path is a tar archive rather than a directory.
+func IsTar(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".tar")
+}
+
+// sink receives files of the layout, named with forward slashes.
+type sink interface {
+	writeFile(name string, size int64, r io.Reader) error
+	Close() error
+}
+
+type dirSink struct {
+	root string
+}
+
+func (ds *dirSink) writeFile(name string, size int64, r io.Reader) error {
+	p := filepath.Join(ds.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n != size {
+		err = fmt.Errorf("written %d bytes instead of %d", n, size)
+	}
+	return err
+}
+
+func (ds *dirSink) Close() error {
+	return nil
+}
+
+type tarSink struct {
+	f  *os.File
+	tw *tar.Writer
+}
+
+func (ts *tarSink) writeFile(name string, size int64, r io.Reader) error {
+	if err := ts.tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: size}); err != nil {
+		return err
+	}
+	_, err := io.Copy(ts.tw, r)
+	return err
+}
+
+func (ts *tarSink) Close() error {
+	err := ts.tw.Close()
+	if closeErr := ts.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func newSink(p string) (sink, error) {
+	if !IsTar(p) {
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			return nil, fmt.Errorf("unable to create directory '%v': %w", p, err)
+		}
+		return &dirSink{root: p}, nil
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create '%v': %w", p, err)
+	}
+	return &tarSink{f: f, tw: tar.NewWriter(f)}, nil
+}
+
+func blobName(h v1.Hash) string {
+	return path.Join("blobs", h.Algorithm, h.Hex)
+}
+
+// writeBlob writes the blob, verifying it has the expected digest.
+func writeBlob(s sink, h v1.Hash, size int64, r io.Reader) error {
+	hasher := sha256.New()
+	if err := s.writeFile(blobName(h), size, io.TeeReader(r, hasher)); err != nil {
+		return fmt.Errorf("unable to write blob %v: %w", h, err)
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); h.Algorithm != "sha256" || actual != h.Hex {
+		return fmt.Errorf("blob %v has digest sha256:%v", h, actual)
+	}
+	return nil
+}
+
+func writeBytes(s sink, name string, data []byte) error {
+	return s.writeFile(name, int64(len(data)), bytes.NewReader(data))
+}
+
+// Save writes the image with its compressed layers to an OCI image layout, a tar archive if the path ends
+// with '.tar' or a directory otherwise. The reference is recorded in index.json.
+func Save(img v1.Image, ref name.Reference, p string, opt ...Option) (*Statistics, error) {
+	opts := makeOptions(opt...)
+	s, err := newSink(p)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := save(s, img, ref, opts)
+	if closeErr := s.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("unable to finish '%v': %w", p, closeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func save(s sink, img v1.Image, ref name.Reference, opts *options) (*Statistics, error) {
+	stats := &Statistics{}
+	if err := writeBytes(s, layoutFilename, []byte(layoutContent)); err != nil {
+		return nil, fmt.Errorf("unable to write %v: %w", layoutFilename, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	written := make(map[v1.Hash]bool)
+	for _, d := range manifest.Layers {
+		if written[d.Digest] {
+			continue
+		}
+		written[d.Digest] = true
+		if opts.excluded[d.Digest] {
+			stats.ExcludedBlobs++
+			stats.ExcludedBytes += d.Size
+			continue
+		}
+		l, err := img.LayerByDigest(d.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get layer %v: %w", d.Digest, err)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read layer %v: %w", d.Digest, err)
+		}
+		err = writeBlob(s, d.Digest, d.Size, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		stats.Blobs++
+		stats.Bytes += d.Size
+	}
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get config file: %w", err)
+	}
+	rawManifest, err := img.RawManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get digest: %w", err)
+	}
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get media type: %w", err)
+	}
+	for _, b := range []struct {
+		digest v1.Hash
+		data   []byte
+	}{{manifest.Config.Digest, rawConfig}, {digest, rawManifest}} {
+		if err := writeBlob(s, b.digest, int64(len(b.data)), bytes.NewReader(b.data)); err != nil {
+			return nil, err
+		}
+		stats.Blobs++
+		stats.Bytes += int64(len(b.data))
+	}
+	annotations := map[string]string{ImageNameAnnotationKey: ref.Name()}
+	if tag, ok := ref.(name.Tag); ok {
+		annotations[RefNameAnnotationKey] = tag.TagStr()
+	}
+	index := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests: []v1.Descriptor{{
+			MediaType:   mediaType,
+			Size:        int64(len(rawManifest)),
+			Digest:      digest,
+			Annotations: annotations,
+		}},
+	}
+	rawIndex, err := json.Marshal(index)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal index: %w", err)
+	}
+	if err := writeBytes(s, indexFilename, rawIndex); err != nil {
+		return nil, fmt.Errorf("unable to write %v: %w", indexFilename, err)
+	}
+	return stats, nil
+}
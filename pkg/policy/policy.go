@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"path"
+	"strings"
+	"time"
+)
+
+// Rule identifies a single check of the Policy.
+type Rule string
+
+const (
+	RuleAllowedRegistries   Rule = "allowed_registries"
+	RuleAllowedRepositories Rule = "allowed_repositories"
+	RuleRequiredLabels      Rule = "required_labels"
+	RuleMaxAge              Rule = "max_age"
+	RuleDeniedDigests       Rule = "denied_digests"
+	RuleRequireDigest       Rule = "require_digest"
+)
+
+// ViolationError is returned when a reference or an image does not satisfy the Policy.
+type ViolationError struct {
+	Rule      Rule
+	Reference string
+	Reason    string
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("policy violation (%s) for '%s': %s", e.Rule, e.Reference, e.Reason)
+}
+
+// IsViolation reports whether err was caused by a policy violation and returns it.
+func IsViolation(err error) (*ViolationError, bool) {
+	var v *ViolationError
+	if errors.As(err, &v) {
+		return v, true
+	}
+	return nil, false
+}
+
+// Policy describes which images are allowed to be pulled.
+// Zero value allows everything.
+type Policy struct {
+	// AllowedRegistries lists registry hosts (e.g. "ghcr.io") images can be pulled from.
+	AllowedRegistries []string `mapstructure:"allowed_registries"`
+	// AllowedRepositories lists repositories (e.g. "ghcr.io/macvmio/*"), glob patterns are supported.
+	AllowedRepositories []string `mapstructure:"allowed_repositories"`
+	// RequiredLabels lists labels that must be present in image config, as "key" or "key=value".
+	RequiredLabels []string `mapstructure:"required_labels"`
+	// MaxAge rejects images whose config 'created' field is older than given duration.
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// DeniedDigests lists manifest digests that must never be pulled.
+	DeniedDigests []string `mapstructure:"denied_digests"`
+	// RequireDigest rejects references which are not pinned by digest.
+	RequireDigest bool `mapstructure:"require_digest"`
+}
+
+func violation(rule Rule, ref name.Reference, format string, args ...any) error {
+	return &ViolationError{
+		Rule:      rule,
+		Reference: ref.String(),
+		Reason:    fmt.Sprintf(format, args...),
+	}
+}
+
+// CheckReference verifies rules that can be evaluated before contacting the registry.
+func (p *Policy) CheckReference(ref name.Reference) error {
+	if p == nil {
+		return nil
+	}
+	if len(p.AllowedRegistries) > 0 && !matchesAny(p.AllowedRegistries, ref.Context().RegistryStr()) {
+		return violation(RuleAllowedRegistries, ref, "registry '%s' is not allowed", ref.Context().RegistryStr())
+	}
+	if len(p.AllowedRepositories) > 0 && !matchesAny(p.AllowedRepositories, ref.Context().Name()) {
+		return violation(RuleAllowedRepositories, ref, "repository '%s' is not allowed", ref.Context().Name())
+	}
+	digest, pinned := ref.(name.Digest)
+	if p.RequireDigest && !pinned {
+		return violation(RuleRequireDigest, ref, "reference must be pinned by digest")
+	}
+	if pinned {
+		return p.CheckDigest(ref, digest.DigestStr())
+	}
+	return nil
+}
+
+// CheckDigest verifies the digest is not denied, e.g. digest of the index a tag resolved to.
+func (p *Policy) CheckDigest(ref name.Reference, digest string) error {
+	if p == nil {
+		return nil
+	}
+	if p.isDenied(digest) {
+		return violation(RuleDeniedDigests, ref, "digest '%s' is denied", digest)
+	}
+	return nil
+}
+
+// CheckImage verifies rules that require manifest and config of the image.
+func (p *Policy) CheckImage(ref name.Reference, img v1.Image) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.CheckReference(ref); err != nil {
+		return err
+	}
+	if len(p.DeniedDigests) > 0 {
+		digest, err := img.Digest()
+		if err != nil {
+			return fmt.Errorf("unable to compute image digest: %w", err)
+		}
+		if err := p.CheckDigest(ref, digest.String()); err != nil {
+			return err
+		}
+	}
+	if len(p.RequiredLabels) == 0 && p.MaxAge == 0 {
+		return nil
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return fmt.Errorf("unable to get config file: %w", err)
+	}
+	for _, label := range p.RequiredLabels {
+		key, expected, hasValue := strings.Cut(label, "=")
+		actual, present := cfg.Config.Labels[key]
+		if !present {
+			return violation(RuleRequiredLabels, ref, "missing label '%s'", key)
+		}
+		if hasValue && actual != expected {
+			return violation(RuleRequiredLabels, ref, "label '%s' has value '%s', expected '%s'", key, actual, expected)
+		}
+	}
+	if p.MaxAge > 0 {
+		if cfg.Created.IsZero() {
+			return violation(RuleMaxAge, ref, "image has no creation time")
+		}
+		age := time.Since(cfg.Created.Time)
+		if age > p.MaxAge {
+			return violation(RuleMaxAge, ref, "image was created %v ago, maximum allowed age is %v", age.Round(time.Second), p.MaxAge)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) isDenied(digest string) bool {
+	for _, d := range p.DeniedDigests {
+		if strings.TrimSpace(d) == digest {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == value {
+			return true
+		}
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func makeImage(t *testing.T, created time.Time, labels map[string]string) v1.Image {
+	t.Helper()
+	img, err := random.Image(10, 1)
+	require.NoError(t, err)
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	cfg = cfg.DeepCopy()
+	cfg.Created = v1.Time{Time: created}
+	cfg.Config.Labels = labels
+	img, err = mutate.ConfigFile(img, cfg)
+	require.NoError(t, err)
+	return img
+}
+
+func TestPolicy_CheckReference(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       *Policy
+		ref          string
+		expectedRule Rule
+	}{
+		{
+			name:   "nil policy allows everything",
+			policy: nil,
+			ref:    "ghcr.io/macvmio/vm:1.0",
+		},
+		{
+			name:   "allowed registry",
+			policy: &Policy{AllowedRegistries: []string{"ghcr.io"}},
+			ref:    "ghcr.io/macvmio/vm:1.0",
+		},
+		{
+			name:         "registry not allowed",
+			policy:       &Policy{AllowedRegistries: []string{"ghcr.io"}},
+			ref:          "docker.io/macvmio/vm:1.0",
+			expectedRule: RuleAllowedRegistries,
+		},
+		{
+			name:   "repository matches glob",
+			policy: &Policy{AllowedRepositories: []string{"ghcr.io/macvmio/*"}},
+			ref:    "ghcr.io/macvmio/vm:1.0",
+		},
+		{
+			name:         "repository does not match glob",
+			policy:       &Policy{AllowedRepositories: []string{"ghcr.io/macvmio/*"}},
+			ref:          "ghcr.io/other/vm:1.0",
+			expectedRule: RuleAllowedRepositories,
+		},
+		{
+			name:         "tag when digest is required",
+			policy:       &Policy{RequireDigest: true},
+			ref:          "ghcr.io/macvmio/vm:1.0",
+			expectedRule: RuleRequireDigest,
+		},
+		{
+			name:   "digest when digest is required",
+			policy: &Policy{RequireDigest: true},
+			ref:    "ghcr.io/macvmio/vm@sha256:9ba7f1cbd9c6e1bb3ef4e2e2a6b3b4a3e7c5b1b8e1c7c3b4a1f2e3d4c5b6a7f8",
+		},
+		{
+			name:         "denied digest",
+			policy:       &Policy{DeniedDigests: []string{"sha256:9ba7f1cbd9c6e1bb3ef4e2e2a6b3b4a3e7c5b1b8e1c7c3b4a1f2e3d4c5b6a7f8"}},
+			ref:          "ghcr.io/macvmio/vm@sha256:9ba7f1cbd9c6e1bb3ef4e2e2a6b3b4a3e7c5b1b8e1c7c3b4a1f2e3d4c5b6a7f8",
+			expectedRule: RuleDeniedDigests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := name.ParseReference(tt.ref, name.StrictValidation)
+			require.NoError(t, err)
+			err = tt.policy.CheckReference(ref)
+			if tt.expectedRule == "" {
+				assert.NoError(t, err)
+				return
+			}
+			v, ok := IsViolation(err)
+			require.True(t, ok, "expected violation, got %v", err)
+			assert.Equal(t, tt.expectedRule, v.Rule)
+		})
+	}
+}
+
+func TestPolicy_CheckImage(t *testing.T) {
+	ref, err := name.ParseReference("ghcr.io/macvmio/vm:1.0", name.StrictValidation)
+	require.NoError(t, err)
+
+	fresh := makeImage(t, time.Now().Add(-time.Hour), map[string]string{"org.opencontainers.image.vendor": "macvmio"})
+	old := makeImage(t, time.Now().Add(-48*time.Hour), nil)
+	freshDigest, err := fresh.Digest()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		policy       *Policy
+		img          v1.Image
+		expectedRule Rule
+	}{
+		{
+			name:   "label present with any value",
+			policy: &Policy{RequiredLabels: []string{"org.opencontainers.image.vendor"}},
+			img:    fresh,
+		},
+		{
+			name:   "label with expected value",
+			policy: &Policy{RequiredLabels: []string{"org.opencontainers.image.vendor=macvmio"}},
+			img:    fresh,
+		},
+		{
+			name:         "label with unexpected value",
+			policy:       &Policy{RequiredLabels: []string{"org.opencontainers.image.vendor=other"}},
+			img:          fresh,
+			expectedRule: RuleRequiredLabels,
+		},
+		{
+			name:         "missing label",
+			policy:       &Policy{RequiredLabels: []string{"org.opencontainers.image.vendor"}},
+			img:          old,
+			expectedRule: RuleRequiredLabels,
+		},
+		{
+			name:   "image younger than max age",
+			policy: &Policy{MaxAge: 24 * time.Hour},
+			img:    fresh,
+		},
+		{
+			name:         "image older than max age",
+			policy:       &Policy{MaxAge: 24 * time.Hour},
+			img:          old,
+			expectedRule: RuleMaxAge,
+		},
+		{
+			name:         "manifest digest is denied",
+			policy:       &Policy{DeniedDigests: []string{freshDigest.String()}},
+			img:          fresh,
+			expectedRule: RuleDeniedDigests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.CheckImage(ref, tt.img)
+			if tt.expectedRule == "" {
+				assert.NoError(t, err)
+				return
+			}
+			v, ok := IsViolation(err)
+			require.True(t, ok, "expected violation, got %v", err)
+			assert.Equal(t, tt.expectedRule, v.Rule)
+		})
+	}
+}
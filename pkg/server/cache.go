@@ -0,0 +1,228 @@
+package server
+
+import (
+	"container/list"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStatistics describes usage of the blob cache.
+type CacheStatistics struct {
+	Entries      int64 `json:"entries"`
+	SizeBytes    int64 `json:"size_bytes"`
+	MaxSizeBytes int64 `json:"max_size_bytes"`
+	Hits         int64 `json:"hits"`
+	Misses       int64 `json:"misses"`
+	Evictions    int64 `json:"evictions"`
+	EvictedBytes int64 `json:"evicted_bytes"`
+}
+
+type cacheEntry struct {
+	digest v1.Hash
+	size   int64
+}
+
+// blobCache keeps compressed blobs on disk, addressed by their digest.
+// When maxSize is positive, least recently used blobs are evicted to keep the cache under that size.
+type blobCache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[v1.Hash]*list.Element
+	size    int64
+
+	hits         atomic.Int64
+	misses       atomic.Int64
+	evictions    atomic.Int64
+	evictedBytes atomic.Int64
+}
+
+func newBlobCache(dir string, maxSize int64) (*blobCache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create cache directory '%v': %w", dir, err)
+	}
+	c := &blobCache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[v1.Hash]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("unable to load cache directory '%v': %w", dir, err)
+	}
+	return c, nil
+}
+
+// load restores entries left by previous runs, modification time is used as the last access time.
+func (c *blobCache) load() error {
+	type found struct {
+		entry   cacheEntry
+		modTime time.Time
+	}
+	all := make([]found, 0)
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "tmp" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		h, err := v1.NewHash(filepath.Base(filepath.Dir(path)) + ":" + d.Name())
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		all = append(all, found{entry: cacheEntry{digest: h, size: info.Size()}, modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].modTime.After(all[j].modTime)
+	})
+	for i := range all {
+		c.entries[all[i].entry.digest] = c.lru.PushBack(&all[i].entry)
+		c.size += all[i].entry.size
+	}
+	c.evict(v1.Hash{})
+	return nil
+}
+
+func (c *blobCache) path(digest v1.Hash) string {
+	return filepath.Join(c.dir, digest.Algorithm, digest.Hex)
+}
+
+// Open returns cached blob, or os.ErrNotExist if it is not present.
+func (c *blobCache) Open(digest v1.Hash) (*os.File, error) {
+	c.mu.Lock()
+	e, present := c.entries[digest]
+	if present {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	if !present {
+		c.misses.Add(1)
+		return nil, os.ErrNotExist
+	}
+	f, err := os.Open(c.path(digest))
+	if err != nil {
+		c.misses.Add(1)
+		c.remove(digest)
+		return nil, err
+	}
+	c.hits.Add(1)
+	now := time.Now()
+	_ = os.Chtimes(c.path(digest), now, now)
+	return f, nil
+}
+
+// Fill stores content of the blob in cache and returns it opened for reading.
+// Content is verified against the digest before it becomes visible.
+func (c *blobCache) Fill(digest v1.Hash, src io.Reader) (*os.File, error) {
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, "tmp"), digest.Hex+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	h, size, err := v1.SHA256(io.TeeReader(src, tmp))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to write blob '%v' to cache: %w", digest, err)
+	}
+	if h != digest {
+		return nil, fmt.Errorf("blob content has digest '%v', expected '%v'", h, digest)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path(digest)), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), c.path(digest)); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(c.path(digest))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, present := c.entries[digest]; present {
+		c.lru.MoveToFront(e)
+		return f, nil
+	}
+	c.entries[digest] = c.lru.PushFront(&cacheEntry{digest: digest, size: size})
+	c.size += size
+	c.evict(digest)
+	return f, nil
+}
+
+func (c *blobCache) remove(digest v1.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, present := c.entries[digest]; present {
+		c.size -= e.Value.(*cacheEntry).size
+		c.lru.Remove(e)
+		delete(c.entries, digest)
+	}
+}
+
+// evict removes least recently used blobs until cache fits in maxSize, keep is never evicted.
+// Must be called with mu held.
+func (c *blobCache) evict(keep v1.Hash) {
+	if c.maxSize <= 0 {
+		return
+	}
+	for e := c.lru.Back(); e != nil && c.size > c.maxSize; {
+		prev := e.Prev()
+		entry := e.Value.(*cacheEntry)
+		if entry.digest != keep {
+			_ = os.Remove(c.path(entry.digest))
+			c.size -= entry.size
+			c.lru.Remove(e)
+			delete(c.entries, entry.digest)
+			c.evictions.Add(1)
+			c.evictedBytes.Add(entry.size)
+		}
+		e = prev
+	}
+}
+
+func (c *blobCache) Stats() CacheStatistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStatistics{
+		Entries:      int64(len(c.entries)),
+		SizeBytes:    c.size,
+		MaxSizeBytes: c.maxSize,
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		Evictions:    c.evictions.Load(),
+		EvictedBytes: c.evictedBytes.Load(),
+	}
+}
+
+func fileModTime(f *os.File) time.Time {
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
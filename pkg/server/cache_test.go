@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func fillBlob(t *testing.T, c *blobCache, content string) v1.Hash {
+	t.Helper()
+	h, _, err := v1.SHA256(bytes.NewReader([]byte(content)))
+	require.NoError(t, err)
+	f, err := c.Fill(h, bytes.NewReader([]byte(content)))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return h
+}
+
+func TestBlobCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := newBlobCache(t.TempDir(), 25)
+	require.NoError(t, err)
+
+	first := fillBlob(t, c, "0123456789")
+	second := fillBlob(t, c, "abcdefghij")
+
+	// using first blob makes the second one least recently used
+	f, err := c.Open(first)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	third := fillBlob(t, c, "ABCDEFGHIJ")
+
+	_, err = c.Open(second)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	for _, h := range []v1.Hash{first, third} {
+		f, err := c.Open(h)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	assert.NoFileExists(t, c.path(second))
+
+	st := c.Stats()
+	assert.Equal(t, int64(2), st.Entries)
+	assert.Equal(t, int64(20), st.SizeBytes)
+	assert.Equal(t, int64(1), st.Evictions)
+	assert.Equal(t, int64(10), st.EvictedBytes)
+	assert.Equal(t, int64(3), st.Hits)
+	assert.Equal(t, int64(1), st.Misses)
+}
+
+func TestBlobCache_RejectsContentNotMatchingDigest(t *testing.T) {
+	c, err := newBlobCache(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	h, _, err := v1.SHA256(bytes.NewReader([]byte("expected")))
+	require.NoError(t, err)
+	_, err = c.Fill(h, bytes.NewReader([]byte("different")))
+	assert.ErrorContains(t, err, "expected")
+	assert.NoFileExists(t, c.path(h))
+}
+
+func TestBlobCache_RestoresEntriesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newBlobCache(dir, 0)
+	require.NoError(t, err)
+	h := fillBlob(t, c, "0123456789")
+
+	reopened, err := newBlobCache(dir, 0)
+	require.NoError(t, err)
+	f, err := reopened.Open(h)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, int64(10), reopened.Stats().SizeBytes)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// registryError follows the error format of the OCI distribution specification.
+type registryError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var (
+	errBlobUnknown     = &registryError{Status: http.StatusNotFound, Code: "BLOB_UNKNOWN", Message: "blob unknown to registry"}
+	errManifestUnknown = &registryError{Status: http.StatusNotFound, Code: "MANIFEST_UNKNOWN", Message: "manifest unknown"}
+	errNameUnknown     = &registryError{Status: http.StatusNotFound, Code: "NAME_UNKNOWN", Message: "repository name not known to registry"}
+	errDigestInvalid   = &registryError{Status: http.StatusBadRequest, Code: "DIGEST_INVALID", Message: "provided digest did not match uploaded content"}
+	errUnsupported     = &registryError{Status: http.StatusMethodNotAllowed, Code: "UNSUPPORTED", Message: "the operation is unsupported by read-only registry"}
+	errNotFound        = &registryError{Status: http.StatusNotFound, Code: "NOT_FOUND", Message: "not found"}
+)
+
+func (e *registryError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func (e *registryError) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Errors []*registryError `json:"errors"`
+	}{Errors: []*registryError{e}})
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	(&registryError{Status: http.StatusInternalServerError, Code: "UNKNOWN", Message: err.Error()}).write(w)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/filesegment"
+	"github.com/macvmio/geranos/pkg/layout"
+	"io"
+	"sort"
+	"sync"
+)
+
+type blobLocation struct {
+	ref        name.Reference
+	descriptor v1.Descriptor
+	isConfig   bool
+}
+
+type repositoryIndex struct {
+	tags      map[string]name.Reference
+	manifests map[v1.Hash]name.Reference
+	blobs     map[v1.Hash]blobLocation
+}
+
+// localStore exposes images stored by layout.Mapper, repository names are fully qualified
+// names of local images, e.g. 'ghcr.io/macvmio/macos-sonoma'.
+type localStore struct {
+	lm *layout.Mapper
+
+	mu      sync.Mutex
+	indexes map[string]*repositoryIndex
+}
+
+func newLocalStore(lm *layout.Mapper) *localStore {
+	return &localStore{
+		lm:      lm,
+		indexes: make(map[string]*repositoryIndex),
+	}
+}
+
+func (ls *localStore) refresh(repo string) (*repositoryIndex, error) {
+	refs, err := ls.lm.Images()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list local images: %w", err)
+	}
+	idx := &repositoryIndex{
+		tags:      make(map[string]name.Reference),
+		manifests: make(map[v1.Hash]name.Reference),
+		blobs:     make(map[v1.Hash]blobLocation),
+	}
+	for _, ref := range refs {
+		if ref.Context().Name() != repo {
+			continue
+		}
+		rawManifest, err := ls.lm.RawManifest(ref)
+		if err != nil {
+			continue
+		}
+		manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+		if err != nil {
+			continue
+		}
+		digest, _, err := v1.SHA256(bytes.NewReader(rawManifest))
+		if err != nil {
+			return nil, err
+		}
+		if tag, ok := ref.(name.Tag); ok {
+			idx.tags[tag.TagStr()] = ref
+		}
+		idx.manifests[digest] = ref
+		idx.blobs[manifest.Config.Digest] = blobLocation{ref: ref, descriptor: manifest.Config, isConfig: true}
+		for _, l := range manifest.Layers {
+			idx.blobs[l.Digest] = blobLocation{ref: ref, descriptor: l}
+		}
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if len(idx.manifests) == 0 {
+		delete(ls.indexes, repo)
+		return nil, errNameUnknown
+	}
+	ls.indexes[repo] = idx
+	return idx, nil
+}
+
+func (ls *localStore) Catalog() ([]string, error) {
+	refs, err := ls.lm.Images()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+	for _, ref := range refs {
+		repo := ref.Context().Name()
+		if !seen[repo] {
+			seen[repo] = true
+			res = append(res, repo)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (ls *localStore) Tags(repo string) ([]string, error) {
+	idx, err := ls.refresh(repo)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(idx.tags))
+	for tag := range idx.tags {
+		res = append(res, tag)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (ls *localStore) Manifest(repo string, reference string) ([]byte, types.MediaType, error) {
+	idx, err := ls.refresh(repo)
+	if err != nil {
+		return nil, "", err
+	}
+	ref, present := idx.tags[reference]
+	if h, err := v1.NewHash(reference); err == nil {
+		ref, present = idx.manifests[h]
+	}
+	if !present {
+		return nil, "", errManifestUnknown
+	}
+	raw, err := ls.lm.RawManifest(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, manifestMediaType(raw), nil
+}
+
+func (ls *localStore) Blob(repo string, digest v1.Hash) (*blob, error) {
+	ls.mu.Lock()
+	idx, present := ls.indexes[repo]
+	ls.mu.Unlock()
+	var loc blobLocation
+	if present {
+		loc, present = idx.blobs[digest]
+	}
+	if !present {
+		idx, err := ls.refresh(repo)
+		if err != nil {
+			return nil, err
+		}
+		if loc, present = idx.blobs[digest]; !present {
+			return nil, errBlobUnknown
+		}
+	}
+	return &blob{
+		digest: digest,
+		size:   loc.descriptor.Size,
+		open: func() (io.ReadCloser, error) {
+			if loc.isConfig {
+				raw, err := ls.lm.RawConfigFile(loc.ref)
+				if err != nil {
+					return nil, err
+				}
+				return io.NopCloser(bytes.NewReader(raw)), nil
+			}
+			d, err := filesegment.ParseDescriptor(loc.descriptor, v1.Hash{})
+			if err != nil {
+				return nil, err
+			}
+			l, err := ls.lm.SegmentLayer(loc.ref, d, filesegment.WithLogFunction(func(fmt string, args ...any) {}))
+			if err != nil {
+				return nil, err
+			}
+			return l.Compressed()
+		},
+	}, nil
+}
+
+func manifestMediaType(raw []byte) types.MediaType {
+	var m struct {
+		MediaType types.MediaType `json:"mediaType"`
+	}
+	if err := json.Unmarshal(raw, &m); err != nil || m.MediaType == "" {
+		return types.OCIManifestSchema1
+	}
+	return m.MediaType
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"log"
+)
+
+type options struct {
+	cacheDir      string
+	cacheMaxSize  int64
+	remoteOptions []remote.Option
+	printf        func(fmt string, argv ...any)
+}
+
+type Option func(opts *options)
+
+func makeOptions(opts ...Option) *options {
+	res := &options{
+		printf: log.Printf,
+	}
+	for _, o := range opts {
+		o(res)
+	}
+	return res
+}
+
+// WithCacheDir makes the server keep compressed segments in dir, so they are compressed only once
+// and verified against their digest before being served.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithCacheMaxSize limits size of the cache directory, least recently used blobs are evicted first.
+func WithCacheMaxSize(maxSize int64) Option {
+	return func(o *options) {
+		o.cacheMaxSize = maxSize
+	}
+}
+
+// WithRemoteOptions sets options used to access the upstream registry in proxy mode.
+func WithRemoteOptions(opts ...remote.Option) Option {
+	return func(o *options) {
+		o.remoteOptions = append(o.remoteOptions, opts...)
+	}
+}
+
+func WithLogFunction(log func(fmt string, args ...any)) Option {
+	return func(o *options) {
+		o.printf = log
+	}
+}
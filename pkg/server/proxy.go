@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// proxyStore forwards requests to the upstream registry, manifests and blobs are kept in the cache by digest,
+// so segments shared between tags and repositories are downloaded from the upstream only once.
+type proxyStore struct {
+	upstream      name.Registry
+	cache         *blobCache
+	remoteOptions []remote.Option
+	printf        func(fmt string, argv ...any)
+
+	mu   sync.Mutex
+	tags map[string]v1.Hash
+}
+
+func newProxyStore(upstream name.Registry, cache *blobCache, opts *options) *proxyStore {
+	return &proxyStore{
+		upstream:      upstream,
+		cache:         cache,
+		remoteOptions: opts.remoteOptions,
+		printf:        opts.printf,
+		tags:          make(map[string]v1.Hash),
+	}
+}
+
+// upstreamError translates 'not found' responses of the upstream into registry errors of the proxy.
+func upstreamError(err error, notFound *registryError) error {
+	var te *transport.Error
+	if errors.As(err, &te) && te.StatusCode == http.StatusNotFound {
+		return notFound
+	}
+	return err
+}
+
+func (ps *proxyStore) repository(repo string) (name.Repository, error) {
+	return name.NewRepository(ps.upstream.Name()+"/"+repo, name.StrictValidation)
+}
+
+func (ps *proxyStore) Catalog() ([]string, error) {
+	return remote.Catalog(context.Background(), ps.upstream, ps.remoteOptions...)
+}
+
+func (ps *proxyStore) Tags(repo string) ([]string, error) {
+	r, err := ps.repository(repo)
+	if err != nil {
+		return nil, errNameUnknown
+	}
+	tags, err := remote.List(r, ps.remoteOptions...)
+	if err != nil {
+		return nil, upstreamError(err, errNameUnknown)
+	}
+	return tags, nil
+}
+
+func (ps *proxyStore) cachedManifest(digest v1.Hash) ([]byte, types.MediaType, bool) {
+	f, err := ps.cache.Open(digest)
+	if err != nil {
+		return nil, "", false
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(f)
+	if err != nil {
+		return nil, "", false
+	}
+	return raw, manifestMediaType(raw), true
+}
+
+func (ps *proxyStore) Manifest(repo string, reference string) ([]byte, types.MediaType, error) {
+	r, err := ps.repository(repo)
+	if err != nil {
+		return nil, "", errNameUnknown
+	}
+	if h, err := v1.NewHash(reference); err == nil {
+		if raw, mt, ok := ps.cachedManifest(h); ok {
+			return raw, mt, nil
+		}
+		return ps.fetchManifest(r.Digest(reference), h)
+	}
+	tagKey := r.Tag(reference).String()
+	desc, err := remote.Head(r.Tag(reference), ps.remoteOptions...)
+	if err != nil {
+		ps.mu.Lock()
+		h, known := ps.tags[tagKey]
+		ps.mu.Unlock()
+		if !known {
+			return nil, "", upstreamError(err, errManifestUnknown)
+		}
+		ps.printf("upstream unavailable for '%v', using last known digest %v: %v", tagKey, h, err)
+		if raw, mt, ok := ps.cachedManifest(h); ok {
+			return raw, mt, nil
+		}
+		return nil, "", err
+	}
+	ps.mu.Lock()
+	ps.tags[tagKey] = desc.Digest
+	ps.mu.Unlock()
+	if raw, mt, ok := ps.cachedManifest(desc.Digest); ok {
+		return raw, mt, nil
+	}
+	return ps.fetchManifest(r.Tag(reference), desc.Digest)
+}
+
+func (ps *proxyStore) fetchManifest(ref name.Reference, digest v1.Hash) ([]byte, types.MediaType, error) {
+	desc, err := remote.Get(ref, ps.remoteOptions...)
+	if err != nil {
+		return nil, "", upstreamError(err, errManifestUnknown)
+	}
+	if desc.Digest != digest {
+		return nil, "", fmt.Errorf("upstream returned manifest '%v' for '%v', expected '%v'", desc.Digest, ref, digest)
+	}
+	f, err := ps.cache.Fill(desc.Digest, bytes.NewReader(desc.Manifest))
+	if err != nil {
+		ps.printf("unable to cache manifest '%v': %v", desc.Digest, err)
+	} else {
+		_ = f.Close()
+	}
+	return desc.Manifest, desc.MediaType, nil
+}
+
+func (ps *proxyStore) Blob(repo string, digest v1.Hash) (*blob, error) {
+	r, err := ps.repository(repo)
+	if err != nil {
+		return nil, errNameUnknown
+	}
+	return &blob{
+		digest: digest,
+		size:   -1,
+		open: func() (io.ReadCloser, error) {
+			l, err := remote.Layer(r.Digest(digest.String()), ps.remoteOptions...)
+			if err != nil {
+				return nil, err
+			}
+			rc, err := l.Compressed()
+			if err != nil {
+				return nil, upstreamError(err, errBlobUnknown)
+			}
+			return rc, nil
+		},
+	}, nil
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/layout"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type blob struct {
+	digest v1.Hash
+	size   int64
+	open   func() (io.ReadCloser, error)
+}
+
+type store interface {
+	Catalog() ([]string, error)
+	Tags(repo string) ([]string, error)
+	Manifest(repo string, reference string) ([]byte, types.MediaType, error)
+	Blob(repo string, digest v1.Hash) (*blob, error)
+}
+
+// StatusPath is the path of the endpoint reporting Status of the server.
+const StatusPath = "/_geranos/status"
+
+// Status is reported by the status endpoint.
+type Status struct {
+	Mode     string           `json:"mode"`
+	Upstream string           `json:"upstream,omitempty"`
+	Cache    *CacheStatistics `json:"cache,omitempty"`
+}
+
+// Server implements read part of the OCI distribution API.
+type Server struct {
+	store    store
+	cache    *blobCache
+	upstream string
+	opts     *options
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// New returns a read-only registry which serves images from the local images directory.
+// Segments are compressed on the fly, or once into the cache directory if one is configured.
+func New(lm *layout.Mapper, opt ...Option) (*Server, error) {
+	opts := makeOptions(opt...)
+	s := &Server{
+		store: newLocalStore(lm),
+		opts:  opts,
+	}
+	if opts.cacheDir != "" {
+		c, err := newBlobCache(opts.cacheDir, opts.cacheMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		s.cache = c
+	}
+	return s, nil
+}
+
+// NewProxy returns a pull-through caching registry which forwards requests to the upstream registry.
+// Manifests and blobs are kept in the cache directory, which is required in this mode.
+func NewProxy(upstream name.Registry, opt ...Option) (*Server, error) {
+	opts := makeOptions(opt...)
+	if opts.cacheDir == "" {
+		return nil, errors.New("cache directory is required in proxy mode")
+	}
+	c, err := newBlobCache(opts.cacheDir, opts.cacheMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		store:    newProxyStore(upstream, c, opts),
+		cache:    c,
+		upstream: upstream.Name(),
+		opts:     opts,
+	}, nil
+}
+
+// Status returns current mode and cache statistics of the server.
+func (s *Server) Status() Status {
+	st := Status{Mode: "local"}
+	if s.upstream != "" {
+		st.Mode = "proxy"
+		st.Upstream = s.upstream
+	}
+	if s.cache != nil {
+		cs := s.cache.Stats()
+		st.Cache = &cs
+	}
+	return st
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.opts.printf("%s %s", r.Method, r.URL.Path)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		errUnsupported.write(w)
+		return
+	}
+	p := r.URL.Path
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	switch {
+	case p == "/v2/" || p == "/v2":
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	case p == StatusPath:
+		writeJSON(w, s.Status())
+	case p == "/v2/_catalog":
+		s.handleCatalog(w, r)
+	case strings.HasSuffix(p, "/tags/list"):
+		s.handleTags(w, r, strings.TrimSuffix(strings.TrimPrefix(p, "/v2/"), "/tags/list"))
+	case strings.Contains(p, "/manifests/"):
+		repo, reference := splitPath(p, "/manifests/")
+		s.handleManifest(w, r, repo, reference)
+	case strings.Contains(p, "/blobs/"):
+		repo, reference := splitPath(p, "/blobs/")
+		s.handleBlob(w, r, repo, reference)
+	default:
+		errNotFound.write(w)
+	}
+}
+
+func splitPath(p string, sep string) (repo string, reference string) {
+	idx := strings.LastIndex(p, sep)
+	return strings.TrimPrefix(p[:idx], "/v2/"), p[idx+len(sep):]
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var re *registryError
+	if errors.As(err, &re) {
+		re.write(w)
+		return
+	}
+	writeInternalError(w, err)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	repos, err := s.store.Catalog()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct {
+		Repositories []string `json:"repositories"`
+	}{Repositories: repos})
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request, repo string) {
+	tags, err := s.store.Tags(repo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}{Name: repo, Tags: tags})
+}
+
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request, repo string, reference string) {
+	raw, mediaType, err := s.store.Manifest(repo, reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	digest, _, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", string(mediaType))
+	w.Header().Set("Content-Length", strconv.Itoa(len(raw)))
+	w.Header().Set("Docker-Content-Digest", digest.String())
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(raw)
+}
+
+func (s *Server) handleBlob(w http.ResponseWriter, r *http.Request, repo string, reference string) {
+	digest, err := v1.NewHash(reference)
+	if err != nil {
+		errDigestInvalid.write(w)
+		return
+	}
+	b, err := s.store.Blob(repo, digest)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", digest.String())
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if s.cache != nil {
+		s.serveFromCache(w, r, b)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(b.size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	rc, err := b.open()
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	defer rc.Close()
+	if _, err := io.Copy(w, rc); err != nil {
+		s.opts.printf("failed to send blob '%v': %v", digest, err)
+	}
+}
+
+func (s *Server) serveFromCache(w http.ResponseWriter, r *http.Request, b *blob) {
+	f, err := s.cache.Open(b.digest)
+	if err != nil {
+		if f, err = s.fillCache(b); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	defer f.Close()
+	http.ServeContent(w, r, "", fileModTime(f), f)
+}
+
+func (s *Server) fillCache(b *blob) (*os.File, error) {
+	rc, err := b.open()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open blob '%v': %w", b.digest, err)
+	}
+	defer rc.Close()
+	return s.cache.Fill(b.digest, rc)
+}
@@ -5,21 +5,23 @@ import (
 	"fmt"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/macvmio/geranos/pkg/filesegment"
+	"sort"
 )
 
-type fileBlueprint struct {
+// FileBlueprint describes a file of an image as a sequence of segments covering it without gaps.
+type FileBlueprint struct {
 	Filename string
 	Segments []*filesegment.Descriptor
 }
 
-func (fr *fileBlueprint) Size() int64 {
+func (fr *FileBlueprint) Size() int64 {
 	if len(fr.Segments) == 0 {
 		return 0
 	}
 	return fr.Segments[len(fr.Segments)-1].Stop() + 1
 }
 
-func (fr *fileBlueprint) Validate() error {
+func (fr *FileBlueprint) Validate() error {
 	if len(fr.Segments) == 0 {
 		return errors.New("0 segments")
 	}
@@ -40,8 +42,8 @@ func (fr *fileBlueprint) Validate() error {
 	return nil
 }
 
-func createBlueprintsFromManifest(manifest v1.Manifest, diffIDs []v1.Hash) ([]*fileBlueprint, error) {
-	fileBlueprintsMap := make(map[string]*fileBlueprint)
+func createBlueprintsFromManifest(manifest v1.Manifest, diffIDs []v1.Hash) ([]*FileBlueprint, error) {
+	fileBlueprintsMap := make(map[string]*FileBlueprint)
 
 	// Ensure the number of diffIDs matches the number of layers
 	if len(diffIDs) != len(manifest.Layers) {
@@ -54,7 +56,7 @@ func createBlueprintsFromManifest(manifest v1.Manifest, diffIDs []v1.Hash) ([]*f
 		}
 		fr, present := fileBlueprintsMap[segmentDescriptor.Filename()]
 		if !present {
-			fr = &fileBlueprint{
+			fr = &FileBlueprint{
 				Filename: segmentDescriptor.Filename(),
 				Segments: make([]*filesegment.Descriptor, 0),
 			}
@@ -62,7 +64,7 @@ func createBlueprintsFromManifest(manifest v1.Manifest, diffIDs []v1.Hash) ([]*f
 		fr.Segments = append(fr.Segments, segmentDescriptor)
 		fileBlueprintsMap[segmentDescriptor.Filename()] = fr
 	}
-	res := make([]*fileBlueprint, 0)
+	res := make([]*FileBlueprint, 0)
 	for _, v := range fileBlueprintsMap {
 		err := v.Validate()
 		if err != nil {
@@ -72,3 +74,15 @@ func createBlueprintsFromManifest(manifest v1.Manifest, diffIDs []v1.Hash) ([]*f
 	}
 	return res, nil
 }
+
+// Blueprints returns blueprints of files described by the manifest, sorted by filename.
+func Blueprints(manifest v1.Manifest, diffIDs []v1.Hash) ([]*FileBlueprint, error) {
+	res, err := createBlueprintsFromManifest(manifest, diffIDs)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Filename < res[j].Filename
+	})
+	return res, nil
+}
@@ -55,23 +55,47 @@ func fileExists(filePath string) bool {
 	return !info.IsDir()
 }
 
-func (sc *Sketcher) Sketch(dir string, manifest v1.Manifest, diffIDs []v1.Hash) (bytesClonedCount int64, matchedSegmentsCount int64, err error) {
-	fileBlueprints, err := createBlueprintsFromManifest(manifest, diffIDs)
+// FilePlan describes how a single file of an image is prepared before its segments are written.
+type FilePlan struct {
+	Filename string
+	Size     int64
+	Segments []*filesegment.Descriptor
+	// Exists is set when the file is already present in the destination directory, it is left untouched.
+	Exists bool
+	// CloneSource is path of the local file which is cloned as a starting point, empty if there is none.
+	CloneSource string
+	// MatchedSegments is number of segments of CloneSource with the same digest.
+	MatchedSegments int
+}
+
+// Plan describes files of an image and local files they are going to be cloned from.
+type Plan struct {
+	Dir   string
+	Files []*FilePlan
+}
+
+// Plan selects the best local clone source for each file of the manifest, nothing is modified.
+func (sc *Sketcher) Plan(dir string, manifest v1.Manifest, diffIDs []v1.Hash) (*Plan, error) {
+	fileBlueprints, err := Blueprints(manifest, diffIDs)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 
 	cloneCandidates, err := sc.findCloneCandidates()
 	if err != nil {
-		return 0, 0, fmt.Errorf("encountered error while looking for manifests: %w", err)
-	}
-	err = os.MkdirAll(dir, os.ModePerm)
-	if err != nil {
-		return 0, 0, fmt.Errorf("unable to create directory '%v': %w", dir, err)
+		return nil, fmt.Errorf("encountered error while looking for manifests: %w", err)
 	}
 
+	plan := &Plan{Dir: dir, Files: make([]*FilePlan, 0, len(fileBlueprints))}
 	for _, fr := range fileBlueprints {
+		fp := &FilePlan{
+			Filename: fr.Filename,
+			Size:     fr.Size(),
+			Segments: fr.Segments,
+		}
+		plan.Files = append(plan.Files, fp)
 		if fileExists(filepath.Join(dir, fr.Filename)) {
+			fp.Exists = true
 			continue
 		}
 		// we will process each FR exactly once
@@ -94,10 +118,27 @@ func (sc *Sketcher) Sketch(dir string, manifest v1.Manifest, diffIDs []v1.Hash)
 		if bestCloneCandidate == nil {
 			continue
 		}
-		bytesClonedCount += fr.Size()
-		matchedSegmentsCount += int64(bestScore)
-		src := bestCloneCandidate.FilePath()
-		dest := filepath.Join(dir, fr.Filename)
+		fp.CloneSource = bestCloneCandidate.FilePath()
+		fp.MatchedSegments = bestScore
+	}
+	return plan, nil
+}
+
+// Execute clones and resizes files according to the plan.
+func (sc *Sketcher) Execute(plan *Plan) (bytesClonedCount int64, matchedSegmentsCount int64, err error) {
+	err = os.MkdirAll(plan.Dir, os.ModePerm)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to create directory '%v': %w", plan.Dir, err)
+	}
+
+	for _, fp := range plan.Files {
+		if fp.Exists || fp.CloneSource == "" {
+			continue
+		}
+		bytesClonedCount += fp.Size
+		matchedSegmentsCount += int64(fp.MatchedSegments)
+		src := fp.CloneSource
+		dest := filepath.Join(plan.Dir, fp.Filename)
 		if src == dest {
 			continue
 		}
@@ -106,14 +147,22 @@ func (sc *Sketcher) Sketch(dir string, manifest v1.Manifest, diffIDs []v1.Hash)
 		if err != nil {
 			return bytesClonedCount, matchedSegmentsCount, fmt.Errorf("unable to clone source file '%v' to destination '%v': %w", src, dest, err)
 		}
-		err = resizeFile(dest, fr.Size())
+		err = resizeFile(dest, fp.Size)
 		if err != nil {
-			return bytesClonedCount, matchedSegmentsCount, fmt.Errorf("error occured while resizing file '%v' to its new size '%v': %w", dest, fr.Size(), err)
+			return bytesClonedCount, matchedSegmentsCount, fmt.Errorf("error occured while resizing file '%v' to its new size '%v': %w", dest, fp.Size, err)
 		}
 	}
 	return bytesClonedCount, matchedSegmentsCount, nil
 }
 
+func (sc *Sketcher) Sketch(dir string, manifest v1.Manifest, diffIDs []v1.Hash) (bytesClonedCount int64, matchedSegmentsCount int64, err error) {
+	plan, err := sc.Plan(dir, manifest, diffIDs)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sc.Execute(plan)
+}
+
 // parseManifestFile represents a placeholder for your actual parsing logic.
 func (sc *Sketcher) findCloneCandidates() ([]*cloneCandidate, error) {
 	type Job struct {
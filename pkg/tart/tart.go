@@ -0,0 +1,305 @@
+package tart
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/lz4"
+	"github.com/macvmio/geranos/pkg/sparsefile"
+	"github.com/macvmio/geranos/pkg/throttle"
+	"golang.org/x/sync/errgroup"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+)
+
+// Media types of layers in images pushed by Tart.
+const (
+	ConfigMediaType = types.MediaType("application/vnd.cirruslabs.tart.config.v1")
+	DiskV1MediaType = types.MediaType("application/vnd.cirruslabs.tart.disk.v1")
+	DiskV2MediaType = types.MediaType("application/vnd.cirruslabs.tart.disk.v2")
+	NVRAMMediaType  = types.MediaType("application/vnd.cirruslabs.tart.nvram.v1")
+)
+
+// UncompressedSizeAnnotationKey and UncompressedDigestAnnotationKey describe disk chunks of v2 disks.
+const UncompressedSizeAnnotationKey = "org.cirruslabs.tart.uncompressed-size"
+const UncompressedDigestAnnotationKey = "org.cirruslabs.tart.uncompressed-content-digest"
+
+// UncompressedDiskSizeAnnotationKey is set in the manifest to the size of the whole disk.
+const UncompressedDiskSizeAnnotationKey = "org.cirruslabs.tart.uncompressed-disk-size"
+
+// Names of files of a VM, the same in Tart and geranos images.
+const (
+	ConfigFilename = "config.json"
+	DiskFilename   = "disk.img"
+	NVRAMFilename  = "nvram.bin"
+)
+
+type options struct {
+	workersCount int
+	diskLimiter  *throttle.Limiter
+	printf       func(fmt string, args ...any)
+}
+
+type Option func(o *options)
+
+// WithWorkersCount sets number of disk chunks written concurrently, it applies only to v2 disks.
+func WithWorkersCount(workersCount int) Option {
+	return func(o *options) {
+		o.workersCount = workersCount
+	}
+}
+
+// WithDiskLimiter throttles writing of the disk, the limiter is shared by all concurrent workers.
+func WithDiskLimiter(limiter *throttle.Limiter) Option {
+	return func(o *options) {
+		o.diskLimiter = limiter
+	}
+}
+
+func WithLogFunction(log func(fmt string, args ...any)) Option {
+	return func(o *options) {
+		o.printf = log
+	}
+}
+
+func makeOptions(opt ...Option) *options {
+	res := &options{
+		workersCount: 4,
+		printf:       func(string, ...any) {},
+	}
+	for _, o := range opt {
+		o(res)
+	}
+	res.workersCount = max(1, res.workersCount)
+	return res
+}
+
+// IsImage reports whether the manifest describes a VM pushed by Tart.
+func IsImage(manifest *v1.Manifest) bool {
+	for _, l := range manifest.Layers {
+		switch l.MediaType {
+		case ConfigMediaType, DiskV1MediaType, DiskV2MediaType, NVRAMMediaType:
+			return true
+		}
+	}
+	return false
+}
+
+// chunk is a compressed part of the disk, placed at offset of the disk file if its uncompressed size is known.
+type chunk struct {
+	descriptor v1.Descriptor
+	offset     int64
+	size       int64
+}
+
+// Import writes files of the Tart image to the directory. Disk chunks are decompressed into disk.img,
+// parts of the existing disk which are already the same are not written.
+func Import(ctx context.Context, img v1.Image, dir string, opt ...Option) (written int64, skipped int64, err error) {
+	opts := makeOptions(opt...)
+	manifest, err := img.Manifest()
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	chunks := make([]chunk, 0)
+	sizesKnown := true
+	var offset int64
+	for _, l := range manifest.Layers {
+		var filename string
+		switch l.MediaType {
+		case ConfigMediaType:
+			filename = ConfigFilename
+		case NVRAMMediaType:
+			filename = NVRAMFilename
+		case DiskV1MediaType, DiskV2MediaType:
+			size, err := strconv.ParseInt(l.Annotations[UncompressedSizeAnnotationKey], 10, 64)
+			if err != nil {
+				sizesKnown = false
+			}
+			chunks = append(chunks, chunk{descriptor: l, offset: offset, size: size})
+			offset += size
+			continue
+		default:
+			return 0, 0, fmt.Errorf("unsupported layer type '%v'", l.MediaType)
+		}
+		n, err := writeFile(img, l, filepath.Join(dir, filename))
+		if err != nil {
+			return 0, 0, err
+		}
+		written += n
+	}
+	if len(chunks) == 0 {
+		return 0, 0, errors.New("image has no disk layers")
+	}
+	var diskWritten, diskSkipped int64
+	if sizesKnown {
+		diskWritten, diskSkipped, err = writeDiskConcurrently(ctx, img, chunks, offset, filepath.Join(dir, DiskFilename), opts)
+	} else {
+		diskSize, parseErr := strconv.ParseInt(manifest.Annotations[UncompressedDiskSizeAnnotationKey], 10, 64)
+		if parseErr != nil {
+			diskSize = -1
+		}
+		diskWritten, diskSkipped, err = writeDisk(ctx, img, chunks, diskSize, filepath.Join(dir, DiskFilename), opts)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if expected, ok := manifest.Annotations[UncompressedDiskSizeAnnotationKey]; ok && expected != strconv.FormatInt(diskWritten+diskSkipped, 10) {
+		return 0, 0, fmt.Errorf("disk has %d bytes, manifest says %v", diskWritten+diskSkipped, expected)
+	}
+	return written + diskWritten, diskSkipped, nil
+}
+
+func writeFile(img v1.Image, d v1.Descriptor, path string) (int64, error) {
+	rc, err := compressedLayer(img, d)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return 0, fmt.Errorf("unable to download '%v': %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return 0, fmt.Errorf("unable to write '%v': %w", filepath.Base(path), err)
+	}
+	return int64(len(data)), nil
+}
+
+func compressedLayer(img v1.Image, d v1.Descriptor) (io.ReadCloser, error) {
+	l, err := img.LayerByDigest(d.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get layer %v: %w", d.Digest, err)
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("unable to download layer %v: %w", d.Digest, err)
+	}
+	return rc, nil
+}
+
+// writeDiskConcurrently writes chunks with known offsets, the file is truncated to the disk size first,
+// so unchanged and empty parts stay sparse.
+func writeDiskConcurrently(ctx context.Context, img v1.Image, chunks []chunk, diskSize int64, path string, opts *options) (written int64, skipped int64, err error) {
+	if err := truncate(path, diskSize); err != nil {
+		return 0, 0, err
+	}
+	var writtenCount, skippedCount atomic.Int64
+	g, groupCtx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.workersCount)
+	for _, c := range chunks {
+		g.Go(func() error {
+			if err := groupCtx.Err(); err != nil {
+				return err
+			}
+			w, s, err := writeChunk(groupCtx, img, c, path, opts)
+			if err != nil {
+				return err
+			}
+			opts.printf("written disk chunk at offset %d: written=%d, skipped=%d\n", c.offset, w, s)
+			writtenCount.Add(w)
+			skippedCount.Add(s)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, 0, err
+	}
+	return writtenCount.Load(), skippedCount.Load(), nil
+}
+
+// writeDisk writes chunks one after another, as offsets are known only after decompressing previous chunks.
+// The file is truncated to the disk size first if it is known.
+func writeDisk(ctx context.Context, img v1.Image, chunks []chunk, diskSize int64, path string, opts *options) (written int64, skipped int64, err error) {
+	if diskSize >= 0 {
+		if err := truncate(path, diskSize); err != nil {
+			return 0, 0, err
+		}
+	}
+	var offset int64
+	for _, c := range chunks {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		c.offset, c.size = offset, -1
+		w, s, err := writeChunk(ctx, img, c, path, opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		opts.printf("written disk chunk at offset %d: written=%d, skipped=%d\n", offset, w, s)
+		written += w
+		skipped += s
+		offset += w + s
+	}
+	return written, skipped, truncate(path, offset)
+}
+
+func truncate(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open '%v': %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return fmt.Errorf("unable to truncate '%v': %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// writeChunk decompresses the chunk into the disk at its offset, verifying its size and digest if they are known.
+func writeChunk(ctx context.Context, img v1.Image, c chunk, path string, opts *options) (written int64, skipped int64, err error) {
+	rc, err := compressedLayer(img, c.descriptor)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rc.Close()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to open '%v': %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+	var dst io.ReadWriteSeeker = f
+	if opts.diskLimiter != nil {
+		dst = throttle.NewReadWriteSeeker(ctx, f, opts.diskLimiter)
+	}
+	if _, err := dst.Seek(c.offset, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("unable to seek to %d: %w", c.offset, err)
+	}
+	var h hash.Hash
+	var src io.Reader = lz4.NewReader(&contextReader{ctx: ctx, r: rc})
+	expectedDigest, verify := c.descriptor.Annotations[UncompressedDigestAnnotationKey]
+	if verify {
+		h = sha256.New()
+		src = io.TeeReader(src, h)
+	}
+	written, skipped, err = sparsefile.Overwrite(dst, src)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to write disk chunk %v: %w", c.descriptor.Digest, err)
+	}
+	if c.size >= 0 && written+skipped != c.size {
+		return 0, 0, fmt.Errorf("disk chunk %v has %d bytes, expected %d", c.descriptor.Digest, written+skipped, c.size)
+	}
+	if verify && "sha256:"+hex.EncodeToString(h.Sum(nil)) != expectedDigest {
+		return 0, 0, fmt.Errorf("disk chunk %v does not match digest %v", c.descriptor.Digest, expectedDigest)
+	}
+	return written, skipped, nil
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
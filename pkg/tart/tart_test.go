@@ -0,0 +1,138 @@
+package tart
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/throttle"
+	"github.com/macvmio/geranos/pkg/throttle/throttletest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// appleLZ4 encodes data as Apple LZ4 stream with a single block of literals only.
+func appleLZ4(data []byte) []byte {
+	block := []byte{0xF0}
+	for n := len(data) - 15; ; n -= 255 {
+		if n < 255 {
+			block = append(block, byte(n))
+			break
+		}
+		block = append(block, 255)
+	}
+	block = append(block, data...)
+	out := []byte("bv41")
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(block)))
+	out = append(out, block...)
+	return append(out, "bv4$"...)
+}
+
+// tartImage returns image laid out as Tart does, with disk split into chunks.
+func tartImage(t *testing.T, disk []byte, chunkSize int, mediaType types.MediaType) v1.Image {
+	t.Helper()
+	addendums := []mutate.Addendum{
+		{Layer: static.NewLayer([]byte(`{"version":1,"os":"darwin"}`), ConfigMediaType), MediaType: ConfigMediaType},
+	}
+	for start := 0; start < len(disk); start += chunkSize {
+		data := disk[start:min(start+chunkSize, len(disk))]
+		annotations := map[string]string{}
+		if mediaType == DiskV2MediaType {
+			sum := sha256.Sum256(data)
+			annotations[UncompressedSizeAnnotationKey] = strconv.Itoa(len(data))
+			annotations[UncompressedDigestAnnotationKey] = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		addendums = append(addendums, mutate.Addendum{Layer: static.NewLayer(appleLZ4(data), mediaType), MediaType: mediaType, Annotations: annotations})
+	}
+	addendums = append(addendums, mutate.Addendum{Layer: static.NewLayer([]byte("nvram"), NVRAMMediaType), MediaType: NVRAMMediaType})
+	img, err := mutate.Append(empty.Image, addendums...)
+	require.NoError(t, err)
+	return mutate.Annotations(img, map[string]string{UncompressedDiskSizeAnnotationKey: strconv.Itoa(len(disk))}).(v1.Image)
+}
+
+func testDisk() []byte {
+	disk := make([]byte, 100*1024)
+	copy(disk, "boot sector")
+	copy(disk[70*1024:], bytes.Repeat([]byte("data"), 1000))
+	return disk
+}
+
+func TestImport(t *testing.T) {
+	for _, mediaType := range []types.MediaType{DiskV1MediaType, DiskV2MediaType} {
+		t.Run(string(mediaType), func(t *testing.T) {
+			disk := testDisk()
+			img := tartImage(t, disk, 30*1024, mediaType)
+			manifest, err := img.Manifest()
+			require.NoError(t, err)
+			assert.True(t, IsImage(manifest))
+			dir := t.TempDir()
+
+			written, skipped, err := Import(context.Background(), img, dir, WithWorkersCount(2))
+			require.NoError(t, err)
+			assert.Greater(t, skipped, int64(0))
+			assert.Greater(t, written, int64(0))
+
+			actual, err := os.ReadFile(filepath.Join(dir, DiskFilename))
+			require.NoError(t, err)
+			assert.Equal(t, disk, actual)
+			nvram, err := os.ReadFile(filepath.Join(dir, NVRAMFilename))
+			require.NoError(t, err)
+			assert.Equal(t, "nvram", string(nvram))
+			cfg, err := os.ReadFile(filepath.Join(dir, ConfigFilename))
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"version":1,"os":"darwin"}`, string(cfg))
+
+			// importing again writes only small files
+			written, _, err = Import(context.Background(), img, dir)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(cfg)+len(nvram)), written)
+		})
+	}
+}
+
+func TestImport_ChunkDigestMismatch(t *testing.T) {
+	img := tartImage(t, testDisk(), 30*1024, DiskV2MediaType)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	d := manifest.Layers[1]
+	l, err := img.LayerByDigest(d.Digest)
+	require.NoError(t, err)
+	d.Annotations[UncompressedDigestAnnotationKey] = "sha256:00"
+	img, err = mutate.Append(empty.Image, mutate.Addendum{Layer: l, MediaType: d.MediaType, Annotations: d.Annotations})
+	require.NoError(t, err)
+
+	_, _, err = Import(context.Background(), img, t.TempDir())
+	assert.ErrorContains(t, err, "does not match digest")
+}
+
+func TestIsImage(t *testing.T) {
+	assert.False(t, IsImage(&v1.Manifest{Layers: []v1.Descriptor{{MediaType: "application/online.jarosik.tomasz.geranos.segment"}}}))
+}
+
+func TestImport_DiskLimiter(t *testing.T) {
+	disk := testDisk()
+	img := tartImage(t, disk, 30*1024, DiskV2MediaType)
+	clock := throttletest.NewClock(time.Unix(0, 0))
+	limiter := throttle.NewLimiterWithClock(throttle.Constant(10*1024), clock)
+
+	dir := t.TempDir()
+	_, _, err := Import(context.Background(), img, dir, WithWorkersCount(2), WithDiskLimiter(limiter))
+	require.NoError(t, err)
+	actual, err := os.ReadFile(filepath.Join(dir, DiskFilename))
+	require.NoError(t, err)
+	assert.Equal(t, disk, actual)
+	// the existing disk is read before it is overwritten, the first 10K are the burst
+	assert.GreaterOrEqual(t, clock.Slept(), 9*time.Second-time.Millisecond)
+}
@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"context"
+	"io"
+)
+
+// chunkSize limits how much is transferred at once, so waiting is spread evenly instead of in bursts.
+const chunkSize = 32 * 1024
+
+type readCloser struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *Limiter
+}
+
+// NewReadCloser returns reader which waits for the limiter after every read.
+func NewReadCloser(ctx context.Context, rc io.ReadCloser, limiter *Limiter) io.ReadCloser {
+	return &readCloser{ReadCloser: rc, ctx: ctx, limiter: limiter}
+}
+
+func (r *readCloser) Read(p []byte) (int, error) {
+	if len(p) > chunkSize {
+		p = p[:chunkSize]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+type readWriteSeeker struct {
+	io.ReadWriteSeeker
+	ctx     context.Context
+	limiter *Limiter
+}
+
+// NewReadWriteSeeker returns file-like object whose reads and writes share the limiter.
+func NewReadWriteSeeker(ctx context.Context, rws io.ReadWriteSeeker, limiter *Limiter) io.ReadWriteSeeker {
+	return &readWriteSeeker{ReadWriteSeeker: rws, ctx: ctx, limiter: limiter}
+}
+
+func (rw *readWriteSeeker) Read(p []byte) (int, error) {
+	if len(p) > chunkSize {
+		p = p[:chunkSize]
+	}
+	n, err := rw.ReadWriteSeeker.Read(p)
+	if waitErr := rw.limiter.WaitN(rw.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+func (rw *readWriteSeeker) Write(p []byte) (written int, err error) {
+	for len(p) > 0 {
+		chunk := p[:min(len(p), chunkSize)]
+		if err := rw.limiter.WaitN(rw.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := rw.ReadWriteSeeker.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
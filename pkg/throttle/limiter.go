@@ -0,0 +1,97 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket shared by everything it throttles, so concurrent workers split the rate between them.
+// The bucket holds at most one second worth of tokens. Transfers larger than that go into debt which
+// following callers wait off in order.
+type Limiter struct {
+	schedule *Schedule
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	clock Clock
+}
+
+// Clock is the time source of Limiter, tests replace it to check waits without sleeping.
+type Clock interface {
+	Now() time.Time
+	// Sleep blocks for d, or until the context is done.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	return sleepContext(ctx, d)
+}
+
+// NewLimiter returns limiter following the schedule.
+func NewLimiter(schedule *Schedule) *Limiter {
+	return NewLimiterWithClock(schedule, realClock{})
+}
+
+// NewLimiterWithClock returns limiter following the schedule, which measures time and waits with the clock.
+func NewLimiterWithClock(schedule *Schedule, clock Clock) *Limiter {
+	return &Limiter{
+		schedule: schedule,
+		clock:    clock,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes n tokens and returns how long the caller has to wait.
+func (l *Limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	rate := float64(l.schedule.RateAt(now))
+	if rate <= 0 {
+		l.tokens = 0
+		l.last = now
+		return 0
+	}
+	if l.last.IsZero() {
+		l.tokens = rate
+	} else {
+		l.tokens = min(rate, l.tokens+now.Sub(l.last).Seconds()*rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / rate * float64(time.Second))
+}
+
+// WaitN blocks until n bytes may be transferred, or the context is done.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	d := l.reserve(n)
+	if d <= 0 {
+		return nil
+	}
+	return l.clock.Sleep(ctx, d)
+}
@@ -0,0 +1,20 @@
+package throttle
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+)
+
+// from sys/resource.h
+const (
+	prioDarwinProcess = 4
+	prioDarwinBG      = 0x1000
+)
+
+// LowerProcessPriority puts the process into background state, in which the system throttles its disk I/O and CPU.
+func LowerProcessPriority() error {
+	if err := unix.Setpriority(prioDarwinProcess, 0, prioDarwinBG); err != nil {
+		return fmt.Errorf("unable to switch to background priority: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package throttle
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"strconv"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+	lowCPUPriority   = 10
+)
+
+// LowerProcessPriority moves all threads of the process into the idle I/O scheduling class and lowers their CPU priority.
+// Linux keeps priorities per thread, threads created later inherit them.
+func LowerProcessPriority() error {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return fmt.Errorf("unable to list threads: %w", err)
+	}
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioClassIdle<<ioprioClassShift)
+		if errno != 0 {
+			return fmt.Errorf("unable to set I/O priority: %w", errno)
+		}
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, lowCPUPriority); err != nil {
+			return fmt.Errorf("unable to set CPU priority: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package throttle
+
+import (
+	"fmt"
+	"golang.org/x/sys/windows"
+)
+
+// LowerProcessPriority switches the process to background processing mode, which lowers its I/O and memory priority.
+func LowerProcessPriority() error {
+	if err := windows.SetPriorityClass(windows.CurrentProcess(), windows.PROCESS_MODE_BACKGROUND_BEGIN); err != nil {
+		return fmt.Errorf("unable to switch to background processing mode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package throttle
+
+import (
+	"fmt"
+	"time"
+)
+
+// Window overrides the rate during part of the day. From and To are offsets from midnight,
+// To earlier than From makes the window wrap around midnight.
+type Window struct {
+	From time.Duration
+	To   time.Duration
+	// Rate in bytes per second, 0 means unlimited.
+	Rate int64
+}
+
+// Schedule describes how the rate changes during the day.
+type Schedule struct {
+	// Rate in bytes per second used outside of windows, 0 means unlimited.
+	Rate    int64
+	Windows []Window
+}
+
+// Constant returns schedule with the same rate all day.
+func Constant(rate int64) *Schedule {
+	return &Schedule{Rate: rate}
+}
+
+func (w Window) contains(offset time.Duration) bool {
+	if w.From <= w.To {
+		return offset >= w.From && offset < w.To
+	}
+	return offset >= w.From || offset < w.To
+}
+
+// RateAt returns rate in bytes per second at given local time, the first matching window wins.
+func (s *Schedule) RateAt(t time.Time) int64 {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range s.Windows {
+		if w.contains(offset) {
+			return w.Rate
+		}
+	}
+	return s.Rate
+}
+
+// IsUnlimited reports whether the schedule never limits the rate.
+func (s *Schedule) IsUnlimited() bool {
+	if s.Rate > 0 {
+		return false
+	}
+	for _, w := range s.Windows {
+		if w.Rate > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseClock parses time of day like '08:30' into offset from midnight.
+func ParseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%v', expected value like '08:30'", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,123 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"github.com/macvmio/geranos/pkg/throttle/throttletest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ Clock = (*throttletest.Clock)(nil)
+
+func at(clock string) time.Time {
+	t, _ := time.Parse("15:04", clock)
+	return t
+}
+
+func TestSchedule_RateAt(t *testing.T) {
+	s := &Schedule{
+		Rate: 100,
+		Windows: []Window{
+			{From: 8 * time.Hour, To: 18 * time.Hour, Rate: 10},
+			{From: 22 * time.Hour, To: 6 * time.Hour, Rate: 0},
+		},
+	}
+	assert.Equal(t, int64(10), s.RateAt(at("08:00")))
+	assert.Equal(t, int64(10), s.RateAt(at("17:59")))
+	assert.Equal(t, int64(100), s.RateAt(at("18:00")))
+	assert.Equal(t, int64(0), s.RateAt(at("23:30")))
+	assert.Equal(t, int64(0), s.RateAt(at("02:00")))
+	assert.Equal(t, int64(100), s.RateAt(at("06:00")))
+	assert.False(t, s.IsUnlimited())
+	assert.True(t, Constant(0).IsUnlimited())
+}
+
+func TestParseClock(t *testing.T) {
+	d, err := ParseClock("08:30")
+	require.NoError(t, err)
+	assert.Equal(t, 8*time.Hour+30*time.Minute, d)
+	_, err = ParseClock("8am")
+	assert.Error(t, err)
+}
+
+func TestLimiter_WaitN(t *testing.T) {
+	clock := throttletest.NewClock(at("12:00"))
+	l := NewLimiterWithClock(Constant(1000), clock)
+
+	t.Run("burst of one second is free", func(t *testing.T) {
+		require.NoError(t, l.WaitN(context.Background(), 1000))
+		assert.Equal(t, time.Duration(0), clock.Slept())
+	})
+
+	t.Run("debt is waited off", func(t *testing.T) {
+		require.NoError(t, l.WaitN(context.Background(), 500))
+		assert.Equal(t, 500*time.Millisecond, clock.Slept())
+		require.NoError(t, l.WaitN(context.Background(), 2000))
+		assert.Equal(t, 2500*time.Millisecond, clock.Slept())
+	})
+
+	t.Run("tokens are refilled over time", func(t *testing.T) {
+		clock.Reset(clock.Now().Add(10 * time.Second))
+		require.NoError(t, l.WaitN(context.Background(), 1000))
+		assert.Equal(t, time.Duration(0), clock.Slept())
+	})
+}
+
+func TestLimiter_FollowsSchedule(t *testing.T) {
+	clock := throttletest.NewClock(at("12:00"))
+	l := NewLimiterWithClock(&Schedule{Windows: []Window{{From: 9 * time.Hour, To: 17 * time.Hour, Rate: 1000}}}, clock)
+
+	require.NoError(t, l.WaitN(context.Background(), 3000))
+	assert.Equal(t, 2*time.Second, clock.Slept())
+
+	clock.Reset(at("20:00"))
+	require.NoError(t, l.WaitN(context.Background(), 1<<30))
+	assert.Equal(t, time.Duration(0), clock.Slept())
+}
+
+func TestLimiter_ContextCancelled(t *testing.T) {
+	l := NewLimiter(Constant(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, l.WaitN(ctx, 1000), context.Canceled)
+}
+
+func TestTransport_SharesLimitBetweenRequests(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 64*1024)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write(payload)
+	}))
+	defer s.Close()
+
+	const rate = 128 * 1024
+	clock := throttletest.NewClock(at("12:00"))
+	l := NewLimiterWithClock(Constant(rate), clock)
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, l)}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Post(s.URL, "application/octet-stream", bytes.NewReader(payload))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			n, err := io.Copy(io.Discard, resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(payload)), n)
+		}()
+	}
+	wg.Wait()
+
+	// 512K transferred in total, the first 128K are the burst and the rest is waited off
+	assert.GreaterOrEqual(t, clock.Slept(), 3*time.Second-time.Millisecond)
+}
@@ -0,0 +1,48 @@
+// Package throttletest provides a clock for tests of code throttled by throttle.Limiter.
+package throttletest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock implements throttle.Clock. It advances only when the limiter waits, so waits are summed instead of slept.
+type Clock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slept += d
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// Slept returns total time the limiter waited since the clock was created or reset.
+func (c *Clock) Slept() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slept
+}
+
+// Reset sets the clock to the time and forgets waits made so far.
+func (c *Clock) Reset(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	c.slept = 0
+}
@@ -0,0 +1,41 @@
+package throttle
+
+import (
+	"io"
+	"net/http"
+)
+
+// Transport throttles request and response bodies of all requests going through it with a single limiter.
+type Transport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+func NewTransport(base http.RoundTripper, limiter *Limiter) *Transport {
+	return &Transport{base: base, limiter: limiter}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(ctx)
+		req.Body = NewReadCloser(ctx, req.Body, t.limiter)
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				rc, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return NewReadCloser(ctx, rc, t.limiter), nil
+			}
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = NewReadCloser(ctx, resp.Body, t.limiter)
+	return resp, nil
+}
@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Mux routes requests to transport of the profile matching host of the request.
+// Requests to other hosts, e.g. token servers, go through the fallback transport.
+type Mux struct {
+	transports map[string]http.RoundTripper
+	fallback   http.RoundTripper
+}
+
+var _ http.RoundTripper = (*Mux)(nil)
+
+// NewMux builds transports of all hosts, the first host wins if a registry is listed more than once.
+func NewMux(hosts []Host, fallback http.RoundTripper) (*Mux, error) {
+	m := &Mux{
+		transports: make(map[string]http.RoundTripper, len(hosts)),
+		fallback:   fallback,
+	}
+	for _, h := range hosts {
+		key := registryKey(h.Registry)
+		if _, present := m.transports[key]; present {
+			continue
+		}
+		rt, err := h.Profile.RoundTripper()
+		if err != nil {
+			return nil, fmt.Errorf("invalid transport profile of '%v': %w", h.Registry, err)
+		}
+		m.transports[key] = rt
+	}
+	return m, nil
+}
+
+func (m *Mux) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, present := m.transports[req.URL.Host]; present {
+		return rt.RoundTrip(req)
+	}
+	return m.fallback.RoundTrip(req)
+}
@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Profile describes how connections to a registry are made.
+// Zero value uses the same transport as go-containerregistry does by default.
+type Profile struct {
+	// PlainHTTP makes registry to be accessed over http instead of https.
+	PlainHTTP bool `mapstructure:"plain_http" yaml:"plain_http,omitempty"`
+	// CAFile is a PEM bundle of certificates trusted in addition to system ones.
+	CAFile string `mapstructure:"ca_file" yaml:"ca_file,omitempty"`
+	// ClientCertFile and ClientKeyFile are PEM files used for mutual TLS.
+	ClientCertFile string `mapstructure:"client_cert_file" yaml:"client_cert_file,omitempty"`
+	ClientKeyFile  string `mapstructure:"client_key_file" yaml:"client_key_file,omitempty"`
+	// InsecureSkipVerify disables verification of server certificates, use only for development.
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify,omitempty"`
+	// Proxy is URL of HTTP proxy, e.g. "http://proxy.local:3128".
+	Proxy string `mapstructure:"proxy" yaml:"proxy,omitempty"`
+	// DialTimeout limits time of establishing a connection.
+	DialTimeout time.Duration `mapstructure:"dial_timeout" yaml:"dial_timeout,omitempty"`
+	// ResponseTimeout limits time of waiting for response headers after request is sent.
+	ResponseTimeout time.Duration `mapstructure:"response_timeout" yaml:"response_timeout,omitempty"`
+	// MaxIdleConns limits number of idle connections kept open to the registry.
+	MaxIdleConns int `mapstructure:"max_idle_conns" yaml:"max_idle_conns,omitempty"`
+}
+
+// Host binds Profile and mirrors to a registry.
+type Host struct {
+	Registry string  `mapstructure:"registry" yaml:"registry"`
+	Profile  Profile `mapstructure:",squash" yaml:",inline"`
+	// Mirrors are tried in order before the registry itself when pulling.
+	// A mirror is a registry optionally followed by a path prefix, e.g. "mirror.local:5000" or "mac-01.local:5000/ghcr.io".
+	Mirrors []string `mapstructure:"mirrors" yaml:"mirrors,omitempty"`
+	// MirrorOnly makes pulls never contact the registry itself, e.g. on air-gapped sites.
+	MirrorOnly bool `mapstructure:"mirror_only" yaml:"mirror_only,omitempty"`
+	// ManifestFormat is "oci" or "docker", empty detects format accepted by the registry on push.
+	ManifestFormat string `mapstructure:"manifest_format" yaml:"manifest_format,omitempty"`
+}
+
+// IsZero reports whether the profile does not change anything.
+func (p *Profile) IsZero() bool {
+	return *p == Profile{}
+}
+
+func (p *Profile) tlsConfig() (*tls.Config, error) {
+	if p.CAFile == "" && p.ClientCertFile == "" && p.ClientKeyFile == "" && !p.InsecureSkipVerify {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: p.InsecureSkipVerify,
+	}
+	if p.CAFile != "" {
+		pem, err := os.ReadFile(p.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle '%v': %w", p.CAFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle '%v'", p.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if p.ClientCertFile != "" || p.ClientKeyFile != "" {
+		if p.ClientCertFile == "" || p.ClientKeyFile == "" {
+			return nil, errors.New("both client certificate and client key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(p.ClientCertFile, p.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// RoundTripper returns transport configured according to the profile.
+func (p *Profile) RoundTripper() (http.RoundTripper, error) {
+	t := remote.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := p.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	if p.Proxy != "" {
+		u, err := url.Parse(p.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL '%v': %w", p.Proxy, err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if p.DialTimeout > 0 {
+		t.DialContext = (&net.Dialer{
+			Timeout:   p.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		t.TLSHandshakeTimeout = p.DialTimeout
+	}
+	if p.ResponseTimeout > 0 {
+		t.ResponseHeaderTimeout = p.ResponseTimeout
+	}
+	if p.MaxIdleConns > 0 {
+		t.MaxIdleConns = p.MaxIdleConns
+		t.MaxIdleConnsPerHost = p.MaxIdleConns
+	}
+	return t, nil
+}
+
+// registryKey normalizes registry name, so e.g. 'docker.io' and 'index.docker.io' are the same.
+func registryKey(registry string) string {
+	reg, err := name.NewRegistry(registry, name.WeakValidation)
+	if err != nil {
+		return registry
+	}
+	return reg.RegistryStr()
+}
+
+// LookupHost returns the first host matching the registry, or nil if there is none.
+func LookupHost(hosts []Host, registry string) *Host {
+	key := registryKey(registry)
+	for i := range hosts {
+		if registryKey(hosts[i].Registry) == key {
+			return &hosts[i]
+		}
+	}
+	return nil
+}
+
+// Lookup returns profile of the first host matching the registry, or nil if there is none.
+func Lookup(hosts []Host, registry string) *Profile {
+	h := LookupHost(hosts, registry)
+	if h == nil {
+		return nil
+	}
+	return &h.Profile
+}
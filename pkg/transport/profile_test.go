@@ -0,0 +1,115 @@
+package transport
+
+import (
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeServerCA(t *testing.T, s *httptest.Server) string {
+	t.Helper()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, data, 0o644))
+	return caFile
+}
+
+func get(t *testing.T, rt http.RoundTripper, u string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	require.NoError(t, err)
+	return rt.RoundTrip(req)
+}
+
+func TestProfile_CustomCA(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	defaultRT, err := (&Profile{}).RoundTripper()
+	require.NoError(t, err)
+	_, err = get(t, defaultRT, s.URL)
+	assert.Error(t, err, "self-signed certificate must not be trusted by default")
+
+	rt, err := (&Profile{CAFile: writeServerCA(t, s)}).RoundTripper()
+	require.NoError(t, err)
+	resp, err := get(t, rt, s.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	rt, err = (&Profile{InsecureSkipVerify: true}).RoundTripper()
+	require.NoError(t, err)
+	resp, err = get(t, rt, s.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestProfile_Proxy(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+	}))
+	defer proxy.Close()
+
+	rt, err := (&Profile{Proxy: proxy.URL}).RoundTripper()
+	require.NoError(t, err)
+	_, err = get(t, rt, "http://registry.example/v2/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://registry.example/v2/", <-proxied)
+}
+
+func TestProfile_ResponseTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	rt, err := (&Profile{ResponseTimeout: 20 * time.Millisecond}).RoundTripper()
+	require.NoError(t, err)
+	_, err = get(t, rt, s.URL)
+	assert.Error(t, err)
+}
+
+func TestProfile_InvalidFiles(t *testing.T) {
+	_, err := (&Profile{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).RoundTripper()
+	assert.Error(t, err)
+	_, err = (&Profile{ClientCertFile: "cert.pem"}).RoundTripper()
+	assert.Error(t, err)
+}
+
+func TestMux_RoutesByHost(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+
+	fallback, err := (&Profile{}).RoundTripper()
+	require.NoError(t, err)
+	m, err := NewMux([]Host{{Registry: u.Host, Profile: Profile{CAFile: writeServerCA(t, s)}}}, fallback)
+	require.NoError(t, err)
+	resp, err := get(t, m, s.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	_, err = get(t, m, other.URL)
+	assert.Error(t, err, "other hosts must use the fallback transport")
+}
+
+func TestLookup(t *testing.T) {
+	hosts := []Host{
+		{Registry: "docker.io", Profile: Profile{Proxy: "http://a"}},
+		{Registry: "lab.local:5000", Profile: Profile{PlainHTTP: true}},
+		{Registry: "lab.local:5000", Profile: Profile{Proxy: "http://ignored"}},
+	}
+	assert.Equal(t, "http://a", Lookup(hosts, "index.docker.io").Proxy)
+	assert.True(t, Lookup(hosts, "lab.local:5000").PlainHTTP)
+	assert.Nil(t, Lookup(hosts, "ghcr.io"))
+}
@@ -0,0 +1,19 @@
+package transporter
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/macvmio/geranos/pkg/artifact"
+	"github.com/macvmio/geranos/pkg/layout"
+)
+
+// isGenericArtifact reports whether the image has layers which are not geranos segments.
+func isGenericArtifact(img v1.Image) bool {
+	manifest, err := img.Manifest()
+	return err == nil && artifact.IsGeneric(manifest)
+}
+
+func pullArtifact(ref name.Reference, img v1.Image, opts *options) error {
+	lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
+	return lm.WriteArtifact(opts.ctx, img, ref)
+}
@@ -0,0 +1,139 @@
+package transporter
+
+import (
+	"archive/tar"
+	"bytes"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/filesegment"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPush_ArtifactModeServesFilesAsTheyAre(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "test-vm:1.0")
+	sha := makeTestVMAt(t, tempDir, ref)
+
+	require.NoError(t, Push(ref, append(opts, WithArtifactMode())...))
+
+	// what an artifact tool does: write out content of every layer with a title
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	img, err := remote.Image(parsed)
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	titles := make([]string, 0)
+	for _, l := range manifest.Layers {
+		title := l.Annotations[filesegment.TitleAnnotationKey]
+		titles = append(titles, title)
+		blob, err := remote.Layer(parsed.Context().Digest(l.Digest.String()))
+		require.NoError(t, err)
+		rc, err := blob.Compressed()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		expected, err := os.ReadFile(filepath.Join(tempDir, "images", portableRef(ref), title))
+		require.NoError(t, err)
+		assert.Equal(t, expected, content)
+	}
+	assert.ElementsMatch(t, []string{"config.json", "disk.img"}, titles)
+
+	deleteTestVMAt(t, tempDir, ref)
+	require.NoError(t, Pull(ref, opts...))
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+}
+
+func TestPull_GenericArtifact(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "provisioning:1.0")
+
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "hooks/post-pull.sh", Typeflag: tar.TypeReg, Mode: 0o755, Size: 5}))
+	_, err := tw.Write([]byte("hook\n"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	scriptType := types.MediaType("application/vnd.example.script")
+	img, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: static.NewLayer([]byte("echo provisioning\n"), scriptType), MediaType: scriptType,
+			Annotations: map[string]string{filesegment.TitleAnnotationKey: "provision.sh"}},
+		mutate.Addendum{Layer: static.NewLayer(tarball.Bytes(), types.OCIUncompressedLayer), MediaType: types.OCIUncompressedLayer},
+		mutate.Addendum{Layer: static.NewLayer([]byte("{}"), "application/vnd.dev.cosign.simplesigning.v1+json"), MediaType: "application/vnd.dev.cosign.simplesigning.v1+json"},
+	)
+	require.NoError(t, err)
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(parsed, img))
+
+	require.NoError(t, Pull(ref, opts...))
+
+	dir := filepath.Join(tempDir, "images", portableRef(ref))
+	content, err := os.ReadFile(filepath.Join(dir, "provision.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo provisioning\n", string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "hooks", "post-pull.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "hook\n", string(content))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{layout.OriginFilename, "hooks", "provision.sh"}, names)
+
+	_, err = PlanPull(ref, opts...)
+	assert.ErrorContains(t, err, "not a geranos image")
+}
+
+func TestPull_GenericArtifactCanNotReplaceReservedFiles(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./.oci.manifest.json", Typeflag: tar.TypeReg, Mode: 0o644, Size: 2}))
+	_, err := tw.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	scriptType := types.MediaType("application/vnd.example.script")
+	layers := map[string]mutate.Addendum{
+		"title": {Layer: static.NewLayer([]byte("{}"), scriptType), MediaType: scriptType,
+			Annotations: map[string]string{filesegment.TitleAnnotationKey: ".GERANOS.origin.json"}},
+		"tar entry": {Layer: static.NewLayer(tarball.Bytes(), types.OCIUncompressedLayer), MediaType: types.OCIUncompressedLayer},
+	}
+	for kind, layer := range layers {
+		t.Run(kind, func(t *testing.T) {
+			ref := refOnServer(s.URL, "reserved:1.0")
+			img, err := mutate.Append(empty.Image, layer)
+			require.NoError(t, err)
+			parsed, err := name.ParseReference(ref)
+			require.NoError(t, err)
+			require.NoError(t, remote.Write(parsed, img))
+
+			err = Pull(ref, opts...)
+			assert.ErrorContains(t, err, "is reserved by geranos")
+		})
+	}
+}
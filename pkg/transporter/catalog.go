@@ -0,0 +1,57 @@
+package transporter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"net/http"
+)
+
+// CatalogRemotely lists repositories of the registry.
+func CatalogRemotely(registry string, opt ...Option) ([]string, error) {
+	opts := makeOptions(opt...)
+	reg, err := opts.parseRegistry(registry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry '%v': %w", registry, err)
+	}
+	return remote.Catalog(opts.ctx, reg, opts.remoteOptions...)
+}
+
+// ListTagsRemotely lists tags of the remote repository.
+func ListTagsRemotely(repository string, opt ...Option) ([]string, error) {
+	opts := makeOptions(opt...)
+	repo, err := opts.parseRepository(repository)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse repository '%v': %w", repository, err)
+	}
+	return remote.List(repo, opts.remoteOptions...)
+}
+
+// CheckCredentials verifies that registry accepts given credentials, using the same transport as other operations.
+func CheckCredentials(registry, username, password string, opt ...Option) error {
+	opts := makeOptions(opt...)
+	reg, err := opts.parseRegistry(registry)
+	if err != nil {
+		return fmt.Errorf("unable to parse registry '%v': %w", registry, err)
+	}
+	auth := authn.FromConfig(authn.AuthConfig{Username: username, Password: password})
+	rt, err := transport.NewWithContext(opts.ctx, reg, auth, opts.transport, nil)
+	if err != nil {
+		return fmt.Errorf("unable to authenticate to '%v': %w", reg, err)
+	}
+	req, err := http.NewRequestWithContext(opts.ctx, http.MethodGet, fmt.Sprintf("%s://%s/v2/", reg.Scheme(), reg.RegistryStr()), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach '%v': %w", reg, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return errors.New("invalid username or password")
+	}
+	return transport.CheckError(resp, http.StatusOK)
+}
@@ -0,0 +1,108 @@
+package transporter
+
+import (
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/logs"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/layout"
+	"log"
+	"os"
+	"time"
+)
+
+// Copy streams image from one registry to another without storing it on local disk.
+// Blobs already present in the destination are skipped, and when both references are on the same registry
+// blobs are mounted from the source repository instead of being uploaded. Images of an index are
+// copied before the index itself.
+func Copy(srcImageRef, dstImageRef string, opt ...Option) error {
+	logs.Progress = log.New(os.Stdout, "", log.LstdFlags)
+	opts := makeOptions(opt...)
+	start := time.Now()
+	defer func() {
+		opts.pushStats.setElapsed(time.Since(start))
+	}()
+
+	srcRef, err := opts.parseReference(srcImageRef)
+	if err != nil {
+		return fmt.Errorf("unable to parse source reference '%v': %w", srcImageRef, err)
+	}
+	dstRef, err := opts.parseReference(dstImageRef)
+	if err != nil {
+		return fmt.Errorf("unable to parse destination reference '%v': %w", dstImageRef, err)
+	}
+	sameRegistry := srcRef.Context().RegistryStr() == dstRef.Context().RegistryStr()
+
+	desc, err := remote.Get(srcRef, opts.remoteOptions...)
+	if err != nil {
+		return fmt.Errorf("unable to fetch manifest of '%v': %w", srcRef, err)
+	}
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return fmt.Errorf("unable to read image index: %w", err)
+		}
+		if opts.workersCount > 0 {
+			if err := prePushIndexConcurrently(srcRef, dstRef, idx, sameRegistry, opts); err != nil {
+				return err
+			}
+		}
+		if err := remote.WriteIndex(dstRef, idx, opts.remoteOptions...); err != nil {
+			return fmt.Errorf("unable to copy image index to '%v': %w", dstRef, err)
+		}
+		return nil
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return fmt.Errorf("unable to read image: %w", err)
+	}
+	if sameRegistry {
+		img = layout.NewMountableImage(img, srcRef)
+	}
+	if err := opts.pushStats.addSizes(img); err != nil {
+		return err
+	}
+
+	if opts.workersCount > 0 {
+		if err := prePushConcurrently(dstRef.Context(), img, opts); err != nil {
+			return err
+		}
+	}
+	if err := remote.Write(dstRef, img, opts.remoteOptions...); err != nil {
+		return fmt.Errorf("unable to push image to '%v': %w", dstRef, err)
+	}
+	return nil
+}
+
+// prePushIndexConcurrently uploads layers of all images of the index, so they are copied by the workers
+// and reported as progress of a single upload. Nested indexes are left to remote.WriteIndex.
+func prePushIndexConcurrently(srcRef, dstRef name.Reference, idx v1.ImageIndex, sameRegistry bool, opts *options) error {
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("unable to read image index: %w", err)
+	}
+	var layers []v1.Layer
+	for _, d := range manifest.Manifests {
+		if !d.MediaType.IsImage() {
+			continue
+		}
+		img, err := idx.Image(d.Digest)
+		if err != nil {
+			return fmt.Errorf("unable to read image %v of the index: %w", d.Digest, err)
+		}
+		if sameRegistry {
+			img = layout.NewMountableImage(img, srcRef)
+		}
+		if err := opts.pushStats.addSizes(img); err != nil {
+			return err
+		}
+		imgLayers, err := img.Layers()
+		if err != nil {
+			return fmt.Errorf("unable to extract layers from image: %w", err)
+		}
+		layers = append(layers, imgLayers...)
+	}
+	return prePushLayersConcurrently(dstRef.Context(), layers, opts)
+}
@@ -0,0 +1,100 @@
+package transporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopy_BetweenRegistriesSkipsExistingBlobs(t *testing.T) {
+	srcRequests := make([]http.Request, 0)
+	src := httptest.NewServer(prepareRegistryWithRecorder(&srcRequests))
+	defer src.Close()
+	dstRequests := make([]http.Request, 0)
+	dst := httptest.NewServer(prepareRegistryWithRecorder(&dstRequests))
+	defer dst.Close()
+
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	srcRef := refOnServer(src.URL, "staging/vm:1.0")
+	dstRef := refOnServer(dst.URL, "production/vm:1.0")
+	sha := makeTestVMAt(t, tempDir, srcRef)
+	require.NoError(t, Push(srcRef, opts...))
+
+	err := Copy(srcRef, dstRef, opts...)
+	require.NoError(t, err)
+	uploaded := calculateAccessed(dstRequests, "PUT", "/blobs/uploads/")
+	assert.Greater(t, uploaded, 0)
+
+	err = Copy(srcRef, dstRef, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, uploaded, calculateAccessed(dstRequests, "PUT", "/blobs/uploads/"))
+
+	deleteTestVMAt(t, tempDir, srcRef)
+	require.NoError(t, Pull(dstRef, opts...))
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(dstRef), "disk.img")))
+}
+
+func TestCopy_WithinRegistryDoesNotTransferBlobs(t *testing.T) {
+	recordedRequests := make([]http.Request, 0)
+	s := httptest.NewServer(prepareRegistryWithRecorder(&recordedRequests))
+	defer s.Close()
+
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	srcRef := refOnServer(s.URL, "staging/vm:1.0")
+	dstRef := refOnServer(s.URL, "production/vm:1.0")
+	makeTestVMAt(t, tempDir, srcRef)
+	require.NoError(t, Push(srcRef, opts...))
+	pushed := calculateAccessed(recordedRequests, "PUT", "/blobs/uploads/")
+
+	err := Copy(srcRef, dstRef, opts...)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, calculateAccessed(recordedRequests, "PUT", "/v2/production/vm/manifests/1.0"))
+	assert.Equal(t, pushed, calculateAccessed(recordedRequests, "PUT", "/blobs/uploads/"))
+	assert.Equal(t, 0, calculateAccessed(recordedRequests, "GET", "/blobs/"))
+}
+
+func TestCopy_InvalidSourceReference(t *testing.T) {
+	_, opts := optionsForTesting(t)
+	err := Copy("invalid:ref:format", "localhost:5000/vm:1.0", opts...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to parse source reference")
+}
+
+func TestCopy_IndexReportsProgress(t *testing.T) {
+	src := httptest.NewServer(prepareRegistry())
+	defer src.Close()
+	dst := httptest.NewServer(prepareRegistry())
+	defer dst.Close()
+	tempDir, opts, indexRef, _, shaHost := pushVariants(t, src)
+	defer os.RemoveAll(tempDir)
+	dstRef := refOnServer(dst.URL, "production/vm:1.0")
+
+	progress := make(chan ProgressUpdate, 1000)
+	stats := &PushStatistics{}
+	require.NoError(t, Copy(indexRef, dstRef, append(opts, WithPushStatistics(stats), WithProgressChannel(progress))...))
+	summary := stats.Summary()
+	// segments and configs of both variants
+	assert.Equal(t, 4, summary.UploadedBlobs)
+	assert.Positive(t, summary.Elapsed)
+
+	var lastUpload ProgressUpdate
+	for len(progress) > 0 {
+		if p := <-progress; p.Stage == StageUploading {
+			lastUpload = p
+		}
+	}
+	assert.Positive(t, lastUpload.BytesTotal)
+	assert.Equal(t, lastUpload.BytesTotal, lastUpload.BytesProcessed)
+
+	require.NoError(t, Pull(dstRef, opts...))
+	assert.Equal(t, shaHost, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(dstRef), "disk.img")))
+}
@@ -0,0 +1,82 @@
+package transporter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"log"
+	"net/http"
+)
+
+// Manifest formats used by Push. ManifestFormatAuto pushes OCI manifest, and falls back to Docker v2 schema 2
+// if the registry rejects it.
+const (
+	ManifestFormatAuto   = "auto"
+	ManifestFormatOCI    = "oci"
+	ManifestFormatDocker = "docker"
+)
+
+// ValidateManifestFormat returns error if the format is not known, empty format means ManifestFormatAuto.
+func ValidateManifestFormat(format string) error {
+	switch format {
+	case "", ManifestFormatAuto, ManifestFormatOCI, ManifestFormatDocker:
+		return nil
+	}
+	return fmt.Errorf("unknown manifest format '%v', expected one of: %v, %v, %v", format, ManifestFormatAuto, ManifestFormatOCI, ManifestFormatDocker)
+}
+
+// isManifestRejected reports whether the registry refused the manifest because of its format.
+func isManifestRejected(err error) bool {
+	var te *transport.Error
+	if !errors.As(err, &te) {
+		return false
+	}
+	if te.StatusCode == http.StatusUnsupportedMediaType {
+		return true
+	}
+	for _, d := range te.Errors {
+		if d.Code == transport.ManifestInvalidErrorCode || d.Code == transport.UnsupportedErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+// writeImage pushes the image in given format, split into parts if its manifest is too large.
+// It returns what was pushed, to be added to an index.
+func writeImage(ref name.Reference, img v1.Image, format string, opts *options) (mutate.Appendable, error) {
+	if format == ManifestFormatDocker {
+		var err error
+		if img, err = dirimage.ToDocker(img); err != nil {
+			return nil, fmt.Errorf("unable to convert image to Docker manifest: %w", err)
+		}
+	}
+	split, err := dirimage.Split(img, opts.maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to split manifest: %w", err)
+	}
+	if split != nil {
+		return split, remote.WriteIndex(ref, split, opts.remoteOptions...)
+	}
+	return img, remote.Write(ref, img, opts.remoteOptions...)
+}
+
+// pushImage pushes the image, detecting format accepted by the registry if the format is not set explicitly.
+// Layers are uploaded only once, as they are the same in both formats.
+func pushImage(ref name.Reference, img v1.Image, opts *options) (mutate.Appendable, error) {
+	format := opts.manifestFormatFor(ref.Context().RegistryStr())
+	if format != ManifestFormatAuto {
+		return writeImage(ref, img, format, opts)
+	}
+	pushed, err := writeImage(ref, img, ManifestFormatOCI, opts)
+	if err == nil || !isManifestRejected(err) {
+		return pushed, err
+	}
+	log.Printf("registry rejected OCI manifest (%v), retrying with Docker v2 schema 2 manifest; set 'manifest_format: docker' for the registry to skip this attempt", err)
+	return writeImage(ref, img, ManifestFormatDocker, opts)
+}
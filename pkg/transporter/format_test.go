@@ -0,0 +1,105 @@
+package transporter
+
+import (
+	"context"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// prepareDockerOnlyRegistry behaves like registries accepting only Docker manifests, and counts rejected ones.
+func prepareDockerOnlyRegistry(rejected *atomic.Int32) http.Handler {
+	h := prepareRegistry()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") && !strings.Contains(r.Header.Get("Content-Type"), "docker") {
+			rejected.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid"}]}`))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestPush_FallsBackToDockerManifest(t *testing.T) {
+	rejected := &atomic.Int32{}
+	s := httptest.NewServer(prepareDockerOnlyRegistry(rejected))
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "test-vm:1.0")
+	sha := makeTestVMAt(t, tempDir, ref)
+
+	require.NoError(t, Push(ref, opts...))
+	assert.Equal(t, int32(1), rejected.Load())
+
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	desc, err := remote.Get(parsed)
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerManifestSchema2, desc.MediaType)
+	img, err := desc.Image()
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerConfigJSON, manifest.Config.MediaType)
+	for _, l := range manifest.Layers {
+		assert.Equal(t, types.DockerLayer, l.MediaType)
+	}
+
+	deleteTestVMAt(t, tempDir, ref)
+	require.NoError(t, Pull(ref, opts...))
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+}
+
+func TestPush_DockerManifestFormat(t *testing.T) {
+	rejected := &atomic.Int32{}
+	s := httptest.NewServer(prepareDockerOnlyRegistry(rejected))
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "test-vm:1.0")
+	sha := makeTestVMAt(t, tempDir, ref)
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	local, err := layout.NewMapper(filepath.Join(tempDir, "images")).Read(context.Background(), parsed)
+	require.NoError(t, err)
+	rawManifest, err := local.RawManifest()
+	require.NoError(t, err)
+
+	t.Run("oci is not retried", func(t *testing.T) {
+		err := Push(ref, append(opts, WithManifestFormat(ManifestFormatOCI))...)
+		assert.ErrorContains(t, err, "MANIFEST_INVALID")
+	})
+
+	t.Run("split docker image", func(t *testing.T) {
+		rejected.Store(0)
+		require.NoError(t, Push(ref, append(opts, WithManifestFormat(ManifestFormatDocker), WithMaxManifestSize(int64(len(rawManifest)-1)))...))
+		assert.Zero(t, rejected.Load())
+
+		desc, err := remote.Get(parsed)
+		require.NoError(t, err)
+		assert.Equal(t, types.DockerManifestList, desc.MediaType)
+		idx, err := desc.ImageIndex()
+		require.NoError(t, err)
+		index, err := idx.IndexManifest()
+		require.NoError(t, err)
+		assert.True(t, dirimage.IsSplit(index))
+
+		deleteTestVMAt(t, tempDir, ref)
+		require.NoError(t, Pull(ref, opts...))
+		assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	})
+}
@@ -0,0 +1,108 @@
+package transporter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/variant"
+	"net/http"
+)
+
+func isNotFound(err error) bool {
+	var te *transport.Error
+	return errors.As(err, &te) && te.StatusCode == http.StatusNotFound
+}
+
+// selectFromIndex returns variant of the index requested by options, or matching the host.
+func (o *options) selectFromIndex(manifest *v1.IndexManifest) (v1.Descriptor, error) {
+	return variant.Select(manifest, o.variant, variant.Host())
+}
+
+// imageFromDescriptor returns the image, or its variant if the descriptor is an index.
+func (o *options) imageFromDescriptor(desc *remote.Descriptor) (v1.Image, error) {
+	if !desc.MediaType.IsIndex() {
+		return desc.Image()
+	}
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	return o.imageFromIndex(idx)
+}
+
+// imageFromIndex assembles split image, or returns the selected variant, which may be split as well.
+func (o *options) imageFromIndex(idx v1.ImageIndex) (v1.Image, error) {
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get index manifest: %w", err)
+	}
+	if dirimage.IsSplit(manifest) {
+		return dirimage.Assemble(idx)
+	}
+	d, err := o.selectFromIndex(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return o.imageFromEntry(idx, d)
+}
+
+func (o *options) imageFromEntry(idx v1.ImageIndex, d v1.Descriptor) (v1.Image, error) {
+	if !d.MediaType.IsIndex() {
+		return idx.Image(d.Digest)
+	}
+	child, err := idx.ImageIndex(d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return o.imageFromIndex(child)
+}
+
+// addToIndex adds image, or split image, pushed to the same repository to the index, replacing a variant
+// with the same properties. The index is created if it does not exist yet.
+func addToIndex(indexRef name.Reference, pushed mutate.Appendable, opts *options) error {
+	if len(opts.variant) == 0 {
+		return errors.New("variant properties are required to add image to an index")
+	}
+	mediaType, err := pushed.MediaType()
+	if err != nil {
+		return err
+	}
+	var idx v1.ImageIndex = empty.Index
+	if dirimage.IsDocker(mediaType) {
+		// registries rejecting OCI manifests reject OCI indexes as well
+		idx = mutate.IndexMediaType(idx, types.DockerManifestList)
+	}
+	desc, err := remote.Get(indexRef, opts.remoteOptions...)
+	switch {
+	case err == nil && !desc.MediaType.IsIndex():
+		return fmt.Errorf("'%v' is an image, not an index", indexRef)
+	case err == nil:
+		if idx, err = desc.ImageIndex(); err != nil {
+			return fmt.Errorf("unable to read index '%v': %w", indexRef, err)
+		}
+	case !isNotFound(err):
+		return fmt.Errorf("unable to fetch index '%v': %w", indexRef, err)
+	}
+	idx = mutate.RemoveManifests(idx, func(d v1.Descriptor) bool {
+		return variant.Descriptor(d).Equal(opts.variant)
+	})
+	idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+		Add: pushed,
+		Descriptor: v1.Descriptor{
+			MediaType:   mediaType,
+			Platform:    opts.variant.Platform(),
+			Annotations: opts.variant.Annotations(),
+		},
+	})
+	if err := remote.WriteIndex(indexRef, idx, opts.remoteOptions...); err != nil {
+		return fmt.Errorf("unable to push index '%v': %w", indexRef, err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package transporter
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/policy"
+	"github.com/macvmio/geranos/pkg/variant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pushVariants pushes two images with different content as variants of the index 'vm:1.0',
+// the first one never matches the host running tests.
+func pushVariants(t *testing.T, s *httptest.Server) (tempDir string, opts []Option, indexRef string, shaM1, shaHost string) {
+	tempDir, opts = optionsForTesting(t)
+	indexRef = refOnServer(s.URL, "vm:1.0")
+	host := variant.Host()
+
+	m1Ref := refOnServer(s.URL, "vm:1.0-m1")
+	shaM1 = makeTestVMWithContent(t, tempDir, m1Ref, "image for M1")
+	m1 := variant.Properties{variant.KeyOS: "darwin", variant.KeyOSVersion: "10.15", variant.KeyCPU: "M1"}
+	require.NoError(t, Push(m1Ref, append(opts, WithIndex(indexRef), WithVariant(m1))...))
+
+	hostRef := refOnServer(s.URL, "vm:1.0-host")
+	shaHost = makeTestVMWithContent(t, tempDir, hostRef, "image for this host")
+	hostVariant := variant.Properties{variant.KeyOS: host[variant.KeyOS], variant.KeyArchitecture: host[variant.KeyArchitecture], "org.example.flavor": "host"}
+	require.NoError(t, Push(hostRef, append(opts, WithIndex(indexRef), WithVariant(hostVariant))...))
+	return tempDir, opts, indexRef, shaM1, shaHost
+}
+
+func TestPush_AddsVariantsToIndex(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts, indexRef, _, _ := pushVariants(t, s)
+	defer os.RemoveAll(tempDir)
+
+	// pushing the same variant again replaces it
+	m1Ref := refOnServer(s.URL, "vm:1.0-m1")
+	makeTestVMWithContent(t, tempDir, m1Ref, "image for M1, updated")
+	m1 := variant.Properties{variant.KeyOS: "darwin", variant.KeyOSVersion: "10.15", variant.KeyCPU: "M1"}
+	require.NoError(t, Push(m1Ref, append(opts, WithIndex(indexRef), WithVariant(m1))...))
+
+	ref, err := name.ParseReference(indexRef)
+	require.NoError(t, err)
+	idx, err := remote.Index(ref)
+	require.NoError(t, err)
+	manifest, err := idx.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 2)
+	assert.Equal(t, "org.example.flavor=host", variant.Properties(manifest.Manifests[0].Annotations).String())
+	assert.True(t, variant.Descriptor(manifest.Manifests[1]).Equal(m1))
+	updated, err := remote.Head(ref.Context().Tag("1.0-m1"))
+	require.NoError(t, err)
+	assert.Equal(t, updated.Digest, manifest.Manifests[1].Digest)
+}
+
+func TestPush_IndexInOtherRepositoryFails(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "vm:1.0-m1")
+	makeTestVMAt(t, tempDir, ref)
+
+	err := Push(ref, append(opts, WithIndex(refOnServer(s.URL, "other:1.0")), WithVariant(variant.Properties{variant.KeyCPU: "M1"}))...)
+	assert.ErrorContains(t, err, "must be in the same repository")
+}
+
+func TestPull_SelectsVariantFromIndex(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts, indexRef, shaM1, shaHost := pushVariants(t, s)
+	defer os.RemoveAll(tempDir)
+	diskPath := filepath.Join(tempDir, "images", portableRef(indexRef), "disk.img")
+
+	t.Run("matching host", func(t *testing.T) {
+		require.NoError(t, Pull(indexRef, opts...))
+		assert.Equal(t, shaHost, hashFromFile(t, diskPath))
+	})
+
+	t.Run("explicit variant", func(t *testing.T) {
+		require.NoError(t, Pull(indexRef, append(opts, WithVariant(variant.Properties{variant.KeyCPU: "M1"}))...))
+		assert.Equal(t, shaM1, hashFromFile(t, diskPath))
+	})
+
+	t.Run("no matching variant", func(t *testing.T) {
+		err := Pull(indexRef, append(opts, WithVariant(variant.Properties{variant.KeyCPU: "M3"}))...)
+		assert.ErrorContains(t, err, "no variant matches 'cpu=M3'")
+	})
+}
+
+func TestPull_PolicyDeniesIndexDigest(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts, indexRef, _, _ := pushVariants(t, s)
+	defer os.RemoveAll(tempDir)
+	ref, err := name.ParseReference(indexRef)
+	require.NoError(t, err)
+	desc, err := remote.Head(ref)
+	require.NoError(t, err)
+
+	err = Pull(indexRef, append(opts, WithPullPolicy(&policy.Policy{DeniedDigests: []string{desc.Digest.String()}}))...)
+	v, ok := policy.IsViolation(err)
+	require.True(t, ok, "expected policy violation, got %v", err)
+	assert.Equal(t, policy.RuleDeniedDigests, v.Rule)
+	assert.NoDirExists(t, filepath.Join(tempDir, "images", portableRef(indexRef)))
+}
+
+func TestInspectRemotely_ListsVariants(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts, indexRef, _, _ := pushVariants(t, s)
+	defer os.RemoveAll(tempDir)
+
+	summary, err := InspectRemotely(indexRef, append(opts, WithVariant(variant.Properties{variant.KeyCPU: "M1"}))...)
+	require.NoError(t, err)
+	require.Len(t, summary.Variants, 2)
+	assert.True(t, summary.Variants[0].Selected)
+	assert.False(t, summary.Variants[1].Selected)
+	assert.Equal(t, "cpu=M1,os=darwin,os.version=10.15", summary.Variants[0].Properties)
+	assert.Equal(t, summary.Variants[0].Digest, summary.Digest)
+	assert.NotEqual(t, summary.Index, summary.Digest)
+	require.Len(t, summary.Files, 1)
+	assert.Equal(t, int64(len("image for M1")), summary.Size)
+}
@@ -34,5 +34,28 @@ func Inspect(rawRef string, opt ...Option) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("unable to marshal manifest to json: %w", err)
 	}
-	return string(outCfg) + "\n" + string(outManifest), nil
+	res := string(outCfg) + "\n" + string(outManifest)
+	origin, err := lm.Origin(ref)
+	if err != nil {
+		return "", err
+	}
+	if origin != nil {
+		outOrigin, err := json.MarshalIndent(origin, "", "\t")
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal origin to json: %w", err)
+		}
+		res += "\n" + string(outOrigin)
+	}
+	return res, nil
+}
+
+// Origin returns where the local image came from, or nil if it is not known.
+func Origin(rawRef string, opt ...Option) (*layout.Origin, error) {
+	opts := makeOptions(opt...)
+	ref, err := name.ParseReference(rawRef, name.StrictValidation)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse reference: %w", err)
+	}
+	lm := layout.NewMapper(opts.imagesPath)
+	return lm.Origin(ref)
 }
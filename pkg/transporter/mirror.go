@@ -0,0 +1,282 @@
+package transporter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/macvmio/geranos/pkg/transport"
+	"io"
+	"log"
+	"sync"
+)
+
+// EndpointStatistics describes what was downloaded from a single registry or mirror.
+type EndpointStatistics struct {
+	Endpoint string
+	Bytes    int64
+	Failures int
+}
+
+// PullStatistics reports how many bytes Pull downloaded from each endpoint. Zero value is ready to use.
+type PullStatistics struct {
+	mu        sync.Mutex
+	endpoints []EndpointStatistics
+}
+
+func (s *PullStatistics) endpoint(name string) *EndpointStatistics {
+	for i := range s.endpoints {
+		if s.endpoints[i].Endpoint == name {
+			return &s.endpoints[i]
+		}
+	}
+	s.endpoints = append(s.endpoints, EndpointStatistics{Endpoint: name})
+	return &s.endpoints[len(s.endpoints)-1]
+}
+
+func (s *PullStatistics) addBytes(name string, n int64) {
+	if s == nil || n == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoint(name).Bytes += n
+}
+
+func (s *PullStatistics) addFailure(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoint(name).Failures++
+}
+
+// Endpoints returns statistics of endpoints in order they were first contacted.
+func (s *PullStatistics) Endpoints() []EndpointStatistics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]EndpointStatistics(nil), s.endpoints...)
+}
+
+// endpoint is a repository on a mirror or on the origin registry.
+type endpoint struct {
+	name string
+	repo name.Repository
+}
+
+func (e endpoint) reference(ref name.Reference) name.Reference {
+	if d, ok := ref.(name.Digest); ok {
+		return e.repo.Digest(d.DigestStr())
+	}
+	return e.repo.Tag(ref.Identifier())
+}
+
+// pullEndpoints returns mirrors of the repository in configured order, followed by the repository itself
+// unless its registry is mirror only.
+func (o *options) pullEndpoints(repo name.Repository) ([]endpoint, bool, error) {
+	origin := endpoint{name: repo.RegistryStr(), repo: repo}
+	host := transport.LookupHost(o.transportHosts, repo.RegistryStr())
+	if host == nil || len(host.Mirrors) == 0 {
+		if host != nil && host.MirrorOnly {
+			return nil, false, fmt.Errorf("registry '%v' is mirror only, but has no mirrors", repo.RegistryStr())
+		}
+		return []endpoint{origin}, false, nil
+	}
+	res := make([]endpoint, 0, len(host.Mirrors)+1)
+	for _, m := range host.Mirrors {
+		mirrorRepo, err := o.parseRepository(m+"/"+repo.RepositoryStr(), name.WeakValidation)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid mirror '%v': %w", m, err)
+		}
+		res = append(res, endpoint{name: m, repo: mirrorRepo})
+	}
+	if !host.MirrorOnly {
+		res = append(res, origin)
+	}
+	return res, host.MirrorOnly, nil
+}
+
+// fetchMirrored fetches image from the first endpoint which serves it. Unless the registry is mirror only,
+// a tag is resolved to a digest at the origin first, so mirrors cannot serve different content. If the origin
+// can not be reached, the tag is resolved by the mirrors instead.
+func (o *options) fetchMirrored(ref name.Reference) (v1.Image, error) {
+	endpoints, mirrorOnly, err := o.pullEndpoints(ref.Context())
+	if err != nil {
+		return nil, err
+	}
+	puller, err := remote.NewPuller(o.remoteOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create puller: %w", err)
+	}
+	remoteOpts := append(append([]remote.Option{}, o.remoteOptions...), remote.Reuse(puller))
+	target := ref
+	if _, isDigest := ref.(name.Digest); !isDigest && len(endpoints) > 1 && !mirrorOnly {
+		desc, err := puller.Head(o.ctx, ref)
+		switch {
+		case err == nil:
+			target = ref.Context().Digest(desc.Digest.String())
+		case isNotFound(err) || o.ctx.Err() != nil:
+			return nil, fmt.Errorf("unable to resolve '%v': %w", ref, err)
+		default:
+			// the origin is unreachable, the tag is resolved by the mirrors like with mirror_only
+			o.pullStats.addFailure(ref.Context().RegistryStr())
+			log.Printf("warning: unable to resolve '%v' at the registry, digest is not pinned and the tag is resolved by mirrors: %v", ref, err)
+		}
+	}
+	errs := make([]error, 0, len(endpoints))
+	for i, e := range endpoints {
+		var img v1.Image
+		var rawIndex, rawManifest, rawConfig []byte
+		desc, err := remote.Get(e.reference(target), remoteOpts...)
+		if err == nil {
+			if desc.MediaType.IsIndex() {
+				rawIndex = desc.Manifest
+			}
+			img, err = o.imageFromDescriptor(desc)
+		}
+		if err == nil {
+			rawManifest, err = img.RawManifest()
+		}
+		if err == nil && i < len(endpoints)-1 {
+			// config is fetched eagerly only when there is an endpoint to fall back to
+			rawConfig, err = img.RawConfigFile()
+		}
+		if err != nil {
+			o.pullStats.addFailure(e.name)
+			errs = append(errs, fmt.Errorf("%v: %w", e.name, err))
+			continue
+		}
+		o.pullStats.addBytes(e.name, int64(len(rawIndex)+len(rawManifest)+len(rawConfig)))
+		return &mirroredImage{Image: img, resolved: desc.Digest, endpoints: endpoints[i:], puller: puller, opts: o}, nil
+	}
+	if len(errs) == 1 {
+		return nil, errors.Unwrap(errs[0])
+	}
+	return nil, fmt.Errorf("unable to fetch '%v' from any endpoint: %w", ref, errors.Join(errs...))
+}
+
+// mirroredImage downloads blobs from the first endpoint which serves them.
+type mirroredImage struct {
+	v1.Image
+	// resolved is digest of what the reference pointed to, an index if the image was selected from one.
+	resolved  v1.Hash
+	endpoints []endpoint
+	puller    *remote.Puller
+	opts      *options
+}
+
+func (mi *mirroredImage) Layers() ([]v1.Layer, error) {
+	ls, err := mi.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]v1.Layer, 0, len(ls))
+	for _, l := range ls {
+		ml, err := mi.wrap(l)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ml)
+	}
+	return res, nil
+}
+
+func (mi *mirroredImage) LayerByDigest(d v1.Hash) (v1.Layer, error) {
+	l, err := mi.Image.LayerByDigest(d)
+	if err != nil {
+		return nil, err
+	}
+	return mi.wrap(l)
+}
+
+func (mi *mirroredImage) wrap(l v1.Layer) (v1.Layer, error) {
+	d, err := l.Digest()
+	if err != nil {
+		return nil, err
+	}
+	return partial.CompressedToLayer(&mirroredLayer{base: l, digest: d, image: mi})
+}
+
+// mirroredLayer reads content from endpoints in order. An endpoint which failed, also in the middle of reading
+// or because of digest mismatch, is skipped by following reads, so retries go to the next endpoint.
+type mirroredLayer struct {
+	base   v1.Layer
+	digest v1.Hash
+	image  *mirroredImage
+
+	mu     sync.Mutex
+	failed int
+}
+
+func (ml *mirroredLayer) Digest() (v1.Hash, error) {
+	return ml.digest, nil
+}
+
+func (ml *mirroredLayer) DiffID() (v1.Hash, error) {
+	return ml.base.DiffID()
+}
+
+func (ml *mirroredLayer) Size() (int64, error) {
+	return ml.base.Size()
+}
+
+func (ml *mirroredLayer) MediaType() (types.MediaType, error) {
+	return ml.base.MediaType()
+}
+
+func (ml *mirroredLayer) current() (int, bool) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	return ml.failed, ml.failed < len(ml.image.endpoints)
+}
+
+func (ml *mirroredLayer) fail(i int) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if ml.failed == i {
+		ml.failed++
+	}
+	ml.image.opts.pullStats.addFailure(ml.image.endpoints[i].name)
+}
+
+func (ml *mirroredLayer) Compressed() (io.ReadCloser, error) {
+	errs := make([]error, 0)
+	for {
+		i, ok := ml.current()
+		if !ok {
+			return nil, fmt.Errorf("unable to fetch blob '%v': %w", ml.digest, errors.Join(errs...))
+		}
+		e := ml.image.endpoints[i]
+		l, err := ml.image.puller.Layer(ml.image.opts.ctx, e.repo.Digest(ml.digest.String()))
+		var rc io.ReadCloser
+		if err == nil {
+			rc, err = l.Compressed()
+		}
+		if err != nil {
+			ml.fail(i)
+			errs = append(errs, fmt.Errorf("%v: %w", e.name, err))
+			continue
+		}
+		return &endpointReader{ReadCloser: rc, layer: ml, index: i}, nil
+	}
+}
+
+// endpointReader counts bytes read from the endpoint and marks it as failed on error.
+type endpointReader struct {
+	io.ReadCloser
+	layer *mirroredLayer
+	index int
+}
+
+func (er *endpointReader) Read(p []byte) (int, error) {
+	n, err := er.ReadCloser.Read(p)
+	er.layer.image.opts.pullStats.addBytes(er.layer.image.endpoints[er.index].name, int64(n))
+	if err != nil && err != io.EOF {
+		er.layer.fail(er.index)
+	}
+	return n, err
+}
@@ -0,0 +1,167 @@
+package transporter
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func bytesFrom(stats *PullStatistics, endpoint string) int64 {
+	for _, e := range stats.Endpoints() {
+		if e.Endpoint == endpoint {
+			return e.Bytes
+		}
+	}
+	return 0
+}
+
+// prepareMirrorTest pushes an image to origin and copies it to the mirror.
+func prepareMirrorTest(t *testing.T, origin, mirror *httptest.Server) (tempDir string, opts []Option, ref string, sha string) {
+	tempDir, opts = optionsForTesting(t)
+	ref = refOnServer(origin.URL, "macvmio/vm:1.0")
+	sha = makeTestVMAt(t, tempDir, ref)
+	require.NoError(t, Push(ref, opts...))
+	if mirror != nil {
+		require.NoError(t, Copy(ref, refOnServer(mirror.URL, "macvmio/vm:1.0"), opts...))
+	}
+	deleteTestVMAt(t, tempDir, ref)
+	return tempDir, opts, ref, sha
+}
+
+func hostOf(s *httptest.Server) string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func TestPull_FromMirror(t *testing.T) {
+	originRequests := make([]http.Request, 0)
+	origin := httptest.NewServer(prepareRegistryWithRecorder(&originRequests))
+	defer origin.Close()
+	mirror := httptest.NewServer(prepareRegistry())
+	defer mirror.Close()
+	tempDir, opts, ref, sha := prepareMirrorTest(t, origin, mirror)
+	defer os.RemoveAll(tempDir)
+	clear(originRequests)
+
+	stats := &PullStatistics{}
+	opts = append(opts, WithPullStatistics(stats), WithTransportHosts([]transport.Host{
+		{Registry: hostOf(origin), Mirrors: []string{hostOf(mirror)}},
+	}))
+	require.NoError(t, Pull(ref, opts...))
+
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	assert.Equal(t, 0, calculateAccessed(originRequests, "GET", "/blobs"))
+	assert.Equal(t, 1, calculateAccessed(originRequests, "HEAD", "/manifests/1.0"))
+	assert.Greater(t, bytesFrom(stats, hostOf(mirror)), int64(0))
+	assert.Equal(t, int64(0), bytesFrom(stats, hostOf(origin)))
+}
+
+func TestPull_FallsBackToOriginWhenMirrorMisses(t *testing.T) {
+	origin := httptest.NewServer(prepareRegistry())
+	defer origin.Close()
+	mirror := httptest.NewServer(prepareRegistry())
+	defer mirror.Close()
+	tempDir, opts, ref, sha := prepareMirrorTest(t, origin, nil)
+	defer os.RemoveAll(tempDir)
+
+	stats := &PullStatistics{}
+	opts = append(opts, WithPullStatistics(stats), WithTransportHosts([]transport.Host{
+		{Registry: hostOf(origin), Mirrors: []string{hostOf(mirror)}},
+	}))
+	require.NoError(t, Pull(ref, opts...))
+
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	assert.Greater(t, bytesFrom(stats, hostOf(origin)), int64(0))
+	assert.Equal(t, int64(0), bytesFrom(stats, hostOf(mirror)))
+	assert.Equal(t, 1, stats.Endpoints()[0].Failures)
+}
+
+func TestPull_ResolvesTagOnMirrorWhenOriginIsDown(t *testing.T) {
+	origin := httptest.NewServer(prepareRegistry())
+	mirror := httptest.NewServer(prepareRegistry())
+	defer mirror.Close()
+	tempDir, opts, ref, sha := prepareMirrorTest(t, origin, mirror)
+	defer os.RemoveAll(tempDir)
+	origin.Close()
+
+	stats := &PullStatistics{}
+	opts = append(opts, WithPullStatistics(stats), WithTransportHosts([]transport.Host{
+		{Registry: hostOf(origin), Mirrors: []string{hostOf(mirror)}},
+	}))
+	require.NoError(t, Pull(ref, opts...))
+
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	assert.Greater(t, bytesFrom(stats, hostOf(mirror)), int64(0))
+	assert.Contains(t, stats.Endpoints(), EndpointStatistics{Endpoint: hostOf(origin), Failures: 1})
+}
+
+func TestPull_RejectsBlobsWithDifferentContentFromMirror(t *testing.T) {
+	origin := httptest.NewServer(prepareRegistry())
+	defer origin.Close()
+	mirrorRegistry := prepareRegistry()
+	var configPath string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/sha256:") && !strings.HasSuffix(r.URL.Path, configPath) {
+			_, _ = w.Write([]byte("tampered content"))
+			return
+		}
+		mirrorRegistry.ServeHTTP(w, r)
+	}))
+	defer mirror.Close()
+	tempDir, opts, ref, sha := prepareMirrorTest(t, origin, mirror)
+	defer os.RemoveAll(tempDir)
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	img, err := remote.Image(parsed)
+	require.NoError(t, err)
+	configName, err := img.ConfigName()
+	require.NoError(t, err)
+	configPath = configName.String()
+
+	stats := &PullStatistics{}
+	opts = append(opts, WithPullStatistics(stats), WithTransportHosts([]transport.Host{
+		{Registry: hostOf(origin), Mirrors: []string{hostOf(mirror)}},
+	}))
+	require.NoError(t, Pull(ref, opts...))
+
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	assert.Greater(t, bytesFrom(stats, hostOf(origin)), int64(0))
+	assert.Greater(t, stats.Endpoints()[0].Failures, 0)
+}
+
+func TestPull_MirrorOnly(t *testing.T) {
+	originRequests := make([]http.Request, 0)
+	origin := httptest.NewServer(prepareRegistryWithRecorder(&originRequests))
+	defer origin.Close()
+	mirror := httptest.NewServer(prepareRegistry())
+	defer mirror.Close()
+	tempDir, opts, ref, sha := prepareMirrorTest(t, origin, mirror)
+	defer os.RemoveAll(tempDir)
+	clear(originRequests)
+
+	t.Run("pulls only from mirror", func(t *testing.T) {
+		mirrorOpts := append(opts, WithTransportHosts([]transport.Host{
+			{Registry: hostOf(origin), Mirrors: []string{hostOf(mirror)}, MirrorOnly: true},
+		}))
+		require.NoError(t, Pull(ref, mirrorOpts...))
+		assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+		assert.Equal(t, 0, calculateAccessed(originRequests, "GET", "/v2/")+calculateAccessed(originRequests, "HEAD", "/v2/"))
+	})
+
+	t.Run("fails when mirror misses", func(t *testing.T) {
+		empty := httptest.NewServer(prepareRegistry())
+		defer empty.Close()
+		mirrorOpts := append(opts, WithForce(true), WithTransportHosts([]transport.Host{
+			{Registry: hostOf(origin), Mirrors: []string{hostOf(empty)}, MirrorOnly: true},
+		}))
+		assert.Error(t, Pull(ref, mirrorOpts...))
+		assert.Equal(t, 0, calculateAccessed(originRequests, "GET", "/v2/")+calculateAccessed(originRequests, "HEAD", "/v2/"))
+	})
+}
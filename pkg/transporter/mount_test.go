@@ -0,0 +1,65 @@
+package transporter
+
+import (
+	"bytes"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPush_MountsBlobsFromOriginsOfLocalImages(t *testing.T) {
+	var mu sync.Mutex
+	mountedFrom := make(map[string]string)
+	registry := prepareRegistry()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the test registry shares blobs between repositories, so they look missing in the new one
+		if r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/derived-vm/blobs/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost && r.URL.Query().Get("mount") != "" {
+			mu.Lock()
+			mountedFrom[r.URL.Query().Get("mount")] = r.URL.Query().Get("from")
+			mu.Unlock()
+		}
+		registry.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	baseRef := refOnServer(s.URL, "base-vm:1")
+	makeTestVMAt(t, tempDir, baseRef)
+	// base image is pulled in practice, so it has a manifest telling which segments it has
+	require.NoError(t, Rehash(baseRef, opts...))
+	require.NoError(t, Push(baseRef, opts...))
+	baseParsed, err := name.ParseReference(baseRef)
+	require.NoError(t, err)
+
+	lm := layout.NewMapper(filepath.Join(tempDir, "images"))
+	origin, err := lm.Origin(baseParsed)
+	require.NoError(t, err)
+	require.NotNil(t, origin)
+	assert.Equal(t, baseRef, origin.Reference)
+
+	derivedRef := refOnServer(s.URL, "derived-vm:1")
+	require.NoError(t, Clone(baseRef, derivedRef, opts...))
+	makeFileAt(t, filepath.Join(tempDir, "images", portableRef(derivedRef), "extra.txt"), "extra file")
+	require.NoError(t, Push(derivedRef, opts...))
+
+	raw, err := lm.RawManifest(baseParsed)
+	require.NoError(t, err)
+	manifest, err := v1.ParseManifest(bytes.NewReader(raw))
+	require.NoError(t, err)
+	for _, l := range manifest.Layers {
+		assert.Equal(t, "base-vm", mountedFrom[l.Digest.String()], "layer %v", l.Digest)
+	}
+}
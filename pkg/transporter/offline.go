@@ -0,0 +1,128 @@
+package transporter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/macvmio/geranos/pkg/ocilayout"
+	"path/filepath"
+)
+
+// baseDigests returns digests of segments of the local base image.
+func baseDigests(lm *layout.Mapper, base string, opts *options) ([]v1.Hash, error) {
+	ref, err := name.ParseReference(base, name.StrictValidation)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse base reference '%v': %w", base, err)
+	}
+	var manifest *v1.Manifest
+	if raw, err := lm.RawManifest(ref); err == nil {
+		manifest, err = v1.ParseManifest(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse manifest of '%v': %w", ref, err)
+		}
+	} else {
+		img, err := lm.Read(opts.ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read base image '%v': %w", ref, err)
+		}
+		if manifest, err = img.Manifest(); err != nil {
+			return nil, fmt.Errorf("unable to get manifest of '%v': %w", ref, err)
+		}
+	}
+	digests := make([]v1.Hash, 0, len(manifest.Layers))
+	for _, l := range manifest.Layers {
+		digests = append(digests, l.Digest)
+	}
+	return digests, nil
+}
+
+// Save writes the local image to an OCI image layout at the path, a tar archive if it ends with '.tar'.
+// Segments of the image set by WithSaveBase are left out.
+func Save(src string, p string, opt ...Option) (*ocilayout.Statistics, error) {
+	opts := makeOptions(opt...)
+	ref, err := name.ParseReference(src, name.StrictValidation)
+	if err != nil {
+		return nil, err
+	}
+	lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
+	img, err := lm.Read(opts.ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image from disk: %w", err)
+	}
+	var saveOpts []ocilayout.Option
+	if opts.saveBase != "" {
+		digests, err := baseDigests(lm, opts.saveBase, opts)
+		if err != nil {
+			return nil, err
+		}
+		saveOpts = append(saveOpts, ocilayout.WithExcludedBlobs(digests))
+	}
+	return ocilayout.Save(img, ref, p, saveOpts...)
+}
+
+// checkExcludedSegments fails if segments left out by Save are not available locally, e.g. the base image
+// was not loaded first.
+func checkExcludedSegments(lm *layout.Mapper, l *ocilayout.Layout, img v1.Image, ref name.Reference, opts *options) error {
+	plan, err := lm.PlanWrite(opts.ctx, img, ref)
+	if err != nil {
+		return err
+	}
+	if plan.UpToDate && !opts.force {
+		return nil
+	}
+	for _, f := range plan.Files {
+		for _, d := range f.SegmentsToDownload {
+			if !l.Has(d.Digest()) {
+				return fmt.Errorf("segment %v of '%v' was left out when saving, its base image has to be present locally", d, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// Load writes the image from an OCI image layout to local images, as Pull does. The image is stored as dst,
+// or under the reference recorded by Save if dst is empty. It returns the reference of the loaded image.
+func Load(p string, dst string, opt ...Option) (string, error) {
+	opts := makeOptions(opt...)
+	l, err := ocilayout.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	img, refStr, err := l.Image(dst)
+	if err != nil {
+		return "", err
+	}
+	ref, err := name.ParseReference(refStr, name.StrictValidation)
+	if err != nil {
+		return "", err
+	}
+	if isTartImage(img) || isGenericArtifact(img) {
+		return "", fmt.Errorf("'%v' is not a geranos image, it can not be loaded", ref)
+	}
+	lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
+	if err := checkExcludedSegments(lm, l, img, ref, opts); err != nil {
+		return "", err
+	}
+	if opts.force {
+		err = lm.Write(opts.ctx, img, ref)
+	} else {
+		err = lm.WriteIfNotPresent(opts.ctx, img, ref)
+	}
+	if err != nil {
+		return "", err
+	}
+	origin := &layout.Origin{Operation: layout.OperationLoad, Source: p}
+	if abs, err := filepath.Abs(p); err == nil {
+		origin.Source = abs
+	}
+	if digest, err := img.Digest(); err == nil {
+		origin.Digest = digest.String()
+	}
+	if err := lm.WriteOrigin(ref, origin); err != nil {
+		return "", err
+	}
+	return ref.String(), nil
+}
@@ -0,0 +1,62 @@
+package transporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := "ghcr.io/macvmio/offline-vm:1"
+	sha := makeTestVMAt(t, tempDir, ref)
+	tarball := filepath.Join(tempDir, "vm.tar")
+
+	stats, err := Save(ref, tarball, opts...)
+	require.NoError(t, err)
+	assert.Zero(t, stats.ExcludedBlobs)
+	deleteTestVMAt(t, tempDir, ref)
+
+	loaded, err := Load(tarball, "", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, ref, loaded)
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+
+	copyRef := "ghcr.io/macvmio/offline-copy:1"
+	_, err = Load(tarball, copyRef, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(copyRef), "disk.img")))
+}
+
+func TestSave_WithBase(t *testing.T) {
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	baseRef := "ghcr.io/macvmio/offline-base:1"
+	ref := "ghcr.io/macvmio/offline-vm:2"
+	makeTestVMAt(t, tempDir, baseRef)
+	// base image is pulled in practice, so it has a manifest which segments are cloned by
+	require.NoError(t, Rehash(baseRef, opts...))
+	sha := makeTestVMAt(t, tempDir, ref)
+	makeFileAt(t, filepath.Join(tempDir, "images", portableRef(ref), "extra.txt"), "extra file")
+	layoutDir := filepath.Join(tempDir, "vm-layout")
+
+	stats, err := Save(ref, layoutDir, append(opts, WithSaveBase(baseRef))...)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.ExcludedBlobs)
+	deleteTestVMAt(t, tempDir, ref)
+
+	// segments left out are cloned from the base image
+	_, err = Load(layoutDir, "", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	assert.FileExists(t, filepath.Join(tempDir, "images", portableRef(ref), "extra.txt"))
+
+	// without the base image they are missing
+	deleteTestVMAt(t, tempDir, ref)
+	deleteTestVMAt(t, tempDir, baseRef)
+	_, err = Load(layoutDir, "", opts...)
+	assert.ErrorContains(t, err, "was left out when saving")
+}
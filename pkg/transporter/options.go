@@ -6,13 +6,26 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/policy"
+	"github.com/macvmio/geranos/pkg/throttle"
+	"github.com/macvmio/geranos/pkg/transport"
+	"github.com/macvmio/geranos/pkg/variant"
 	"log"
+	"net/http"
 )
 
 type options struct {
 	imagesPath       string
 	cachePath        string
 	mountedReference name.Reference
+	indexReference   string
+	maxManifestSize  int64
+	manifestFormat   string
+	format           string
+	rehash           bool
+	saveBase         string
+	syncLock         *SyncSpec
+	variant          variant.Properties
 	insecure         bool
 	remoteOptions    []remote.Option
 	dirimageOptions  []dirimage.Option
@@ -20,6 +33,18 @@ type options struct {
 	workersCount     int
 	verbose          bool
 	force            bool
+	dryRun           bool
+	pullPolicy       *policy.Policy
+	pullStats        *PullStatistics
+	pushStats        *PushStatistics
+	progress         chan<- ProgressUpdate
+	serveCachePath   string
+	serveCacheSize   int64
+	serveUpstream    string
+	transportHosts   []transport.Host
+	limiter          *throttle.Limiter
+	diskLimiter      *throttle.Limiter
+	transport        http.RoundTripper
 	ctx              context.Context
 }
 
@@ -37,9 +62,51 @@ func WithCachePath(cachePath string) Option {
 	}
 }
 
+// WithInsecureTransport makes all registries to be accessed over plain http.
 func WithInsecureTransport() Option {
 	return func(o *options) {
-		o.insecure = false
+		o.insecure = true
+	}
+}
+
+// WithTransportHosts configures how connections to given registries are made,
+// the first host wins if a registry is listed more than once.
+func WithTransportHosts(hosts []transport.Host) Option {
+	return func(o *options) {
+		o.transportHosts = append(o.transportHosts, hosts...)
+	}
+}
+
+// WithKeychain sets keychain resolving credentials of registries, the Docker config is used by default.
+func WithKeychain(kc authn.Keychain) Option {
+	return func(o *options) {
+		if kc != nil {
+			o.remoteOptions = append(o.remoteOptions, remote.WithAuthFromKeychain(kc))
+		}
+	}
+}
+
+// WithBandwidthLimiter throttles all registry traffic, the limiter is shared by all concurrent workers.
+func WithBandwidthLimiter(limiter *throttle.Limiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithDiskLimiter throttles reading and writing of local files, including hashing and checks of existing segments.
+func WithDiskLimiter(limiter *throttle.Limiter) Option {
+	return func(o *options) {
+		if limiter != nil {
+			o.diskLimiter = limiter
+			o.dirimageOptions = append(o.dirimageOptions, dirimage.WithDiskLimiter(limiter))
+		}
+	}
+}
+
+// WithArtifactMode makes Push produce image whose files can be fetched with artifact tools like oras.
+func WithArtifactMode() Option {
+	return func(o *options) {
+		o.dirimageOptions = append(o.dirimageOptions, dirimage.WithArtifactMode())
 	}
 }
 
@@ -49,6 +116,65 @@ func WithMountedReference(ref name.Reference) Option {
 	}
 }
 
+// WithIndex makes Push add the image to the index with given reference as a variant described by WithVariant.
+func WithIndex(indexRef string) Option {
+	return func(o *options) {
+		o.indexReference = indexRef
+	}
+}
+
+// WithVariant describes the variant pushed to an index, or selects the variant to pull or inspect.
+// Without it, the first variant matching the host is pulled.
+func WithVariant(properties variant.Properties) Option {
+	return func(o *options) {
+		o.variant = properties
+	}
+}
+
+// WithMaxManifestSize makes Push split images with larger manifests into parts under an index, 0 disables splitting.
+func WithMaxManifestSize(size int64) Option {
+	return func(o *options) {
+		o.maxManifestSize = size
+	}
+}
+
+// WithManifestFormat makes Push use given manifest format, overriding the one set for the registry.
+func WithManifestFormat(format string) Option {
+	return func(o *options) {
+		o.manifestFormat = format
+	}
+}
+
+// WithFormat makes Pull expect images of given format, e.g. FormatTart.
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithRehash makes Pull of Tart images hash imported files into geranos segments, so they can be pushed
+// and cloned without hashing them again.
+func WithRehash(rehash bool) Option {
+	return func(o *options) {
+		o.rehash = rehash
+	}
+}
+
+// WithSaveBase makes Save leave out segments of given local image, which is present where the image is loaded.
+func WithSaveBase(base string) Option {
+	return func(o *options) {
+		o.saveBase = base
+	}
+}
+
+// WithSyncLock sets the lock file of the previous Sync, its retained entries are not pruned even if they
+// were dropped from the spec.
+func WithSyncLock(lock *SyncSpec) Option {
+	return func(o *options) {
+		o.syncLock = lock
+	}
+}
+
 func WithWorkersCount(workersCount int) Option {
 	return func(o *options) {
 		o.workersCount = workersCount
@@ -86,6 +212,56 @@ func WithForce(force bool) Option {
 	}
 }
 
+// WithDryRun makes operations only report what they would do, without modifying anything.
+func WithDryRun(dryRun bool) Option {
+	return func(o *options) {
+		o.dryRun = dryRun
+	}
+}
+
+// WithServeCachePath makes Serve keep compressed segments in given directory.
+func WithServeCachePath(cachePath string) Option {
+	return func(o *options) {
+		o.serveCachePath = cachePath
+	}
+}
+
+// WithServeCacheSize limits size of the cache used by Serve, least recently used blobs are evicted first.
+func WithServeCacheSize(maxSize int64) Option {
+	return func(o *options) {
+		o.serveCacheSize = maxSize
+	}
+}
+
+// WithServeUpstream makes Serve work as a pull-through caching proxy of the upstream registry.
+func WithServeUpstream(upstream string) Option {
+	return func(o *options) {
+		o.serveUpstream = upstream
+	}
+}
+
+// WithPullPolicy rejects pulls violating the policy before anything is written to disk, nil allows everything.
+func WithPullPolicy(p *policy.Policy) Option {
+	return func(o *options) {
+		o.pullPolicy = p
+	}
+}
+
+// WithPullStatistics makes Pull record how many bytes were downloaded from each registry and mirror.
+func WithPullStatistics(stats *PullStatistics) Option {
+	return func(o *options) {
+		o.pullStats = stats
+	}
+}
+
+// WithPushStatistics makes Push record how many blobs it uploaded, and how many the registry already had
+// or mounted from another repository.
+func WithPushStatistics(stats *PushStatistics) Option {
+	return func(o *options) {
+		o.pushStats = stats
+	}
+}
+
 func WithProgressChannel(c chan<- ProgressUpdate) Option {
 	return func(o *options) {
 		// Create a new dirimage channel to be used internally
@@ -98,9 +274,11 @@ func WithProgressChannel(c chan<- ProgressUpdate) Option {
 				c <- ProgressUpdate{
 					BytesProcessed: progress.BytesProcessed,
 					BytesTotal:     progress.BytesTotal,
+					Stage:          progress.Stage,
 				}
 			}
 		}()
+		o.progress = c
 		o.dirimageOptions = append(o.dirimageOptions, dirimage.WithProgressChannel(dirimageChan))
 	}
 }
@@ -117,11 +295,84 @@ func makeOptions(opts ...Option) *options {
 		dirimageOptions: []dirimage.Option{},
 		refValidation:   name.StrictValidation,
 		workersCount:    8,
+		maxManifestSize: dirimage.DefaultMaxManifestSize,
 		verbose:         false,
 		ctx:             context.Background(),
 	}
 	for _, o := range opts {
 		o(&res)
 	}
+	res.transport = remote.DefaultTransport
+	if len(res.transportHosts) > 0 {
+		mux, err := transport.NewMux(res.transportHosts, remote.DefaultTransport)
+		if err != nil {
+			// invalid profile must not silently fall back to the default transport
+			res.transport = failingTransport{err: err}
+		} else {
+			res.transport = mux
+		}
+	}
+	if res.limiter != nil {
+		res.transport = throttle.NewTransport(res.transport, res.limiter)
+	}
+	if res.pushStats != nil {
+		res.transport = &pushStatsTransport{base: res.transport, stats: res.pushStats}
+	}
+	if len(res.transportHosts) > 0 || res.limiter != nil || res.pushStats != nil {
+		res.remoteOptions = append(res.remoteOptions, remote.WithTransport(res.transport))
+	}
 	return &res
 }
+
+type failingTransport struct {
+	err error
+}
+
+func (ft failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, ft.err
+}
+
+// plainHTTP reports whether registry has to be accessed over http.
+func (o *options) plainHTTP(registry string) bool {
+	if o.insecure {
+		return true
+	}
+	p := transport.Lookup(o.transportHosts, registry)
+	return p != nil && p.PlainHTTP
+}
+
+// manifestFormatFor returns format set by options, or by transport profile of the registry.
+func (o *options) manifestFormatFor(registry string) string {
+	if o.manifestFormat != "" && o.manifestFormat != ManifestFormatAuto {
+		return o.manifestFormat
+	}
+	if h := transport.LookupHost(o.transportHosts, registry); h != nil && h.ManifestFormat != "" {
+		return h.ManifestFormat
+	}
+	return ManifestFormatAuto
+}
+
+// parseReference parses reference, making it use http if transport profile of its registry requires that.
+func (o *options) parseReference(s string, opt ...name.Option) (name.Reference, error) {
+	ref, err := name.ParseReference(s, opt...)
+	if err != nil || !o.plainHTTP(ref.Context().RegistryStr()) {
+		return ref, err
+	}
+	return name.ParseReference(s, append(opt, name.Insecure)...)
+}
+
+func (o *options) parseRepository(s string, opt ...name.Option) (name.Repository, error) {
+	repo, err := name.NewRepository(s, opt...)
+	if err != nil || !o.plainHTTP(repo.RegistryStr()) {
+		return repo, err
+	}
+	return name.NewRepository(s, append(opt, name.Insecure)...)
+}
+
+func (o *options) parseRegistry(s string, opt ...name.Option) (name.Registry, error) {
+	reg, err := name.NewRegistry(s, opt...)
+	if err != nil || !o.plainHTTP(reg.RegistryStr()) {
+		return reg, err
+	}
+	return name.NewRegistry(s, append(opt, name.Insecure)...)
+}
@@ -0,0 +1,62 @@
+package transporter
+
+import (
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOrigin_RecordedByPullCloneAndAdopt(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "origin-vm:1")
+	makeTestVMAt(t, tempDir, ref)
+	require.NoError(t, Push(ref, opts...))
+	deleteTestVMAt(t, tempDir, ref)
+
+	require.NoError(t, Pull(ref, opts...))
+	pulled, err := Origin(ref, opts...)
+	require.NoError(t, err)
+	require.NotNil(t, pulled)
+	assert.Equal(t, layout.OperationPull, pulled.Operation)
+	assert.Equal(t, ref, pulled.Reference)
+	summary, err := InspectRemotely(ref, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, summary.Digest, pulled.Digest)
+	assert.False(t, pulled.Timestamp.IsZero())
+
+	cloneRef := refOnServer(s.URL, "origin-clone:1")
+	require.NoError(t, Clone(ref, cloneRef, opts...))
+	cloned, err := Origin(cloneRef, opts...)
+	require.NoError(t, err)
+	require.NotNil(t, cloned)
+	assert.Equal(t, layout.OperationClone, cloned.Operation)
+	assert.Equal(t, ref, cloned.Parent)
+	assert.Equal(t, pulled.Reference, cloned.Reference)
+	assert.Equal(t, pulled.Digest, cloned.Digest)
+
+	adoptDir := filepath.Join(tempDir, "adopt")
+	require.NoError(t, os.MkdirAll(adoptDir, 0o755))
+	makeFileAt(t, filepath.Join(adoptDir, "disk.img"), "adopted")
+	adoptRef := refOnServer(s.URL, "adopted:1")
+	require.NoError(t, Adopt(adoptDir, adoptRef, opts...))
+	adopted, err := Origin(adoptRef, opts...)
+	require.NoError(t, err)
+	require.NotNil(t, adopted)
+	assert.Equal(t, layout.OperationAdopt, adopted.Operation)
+	assert.Equal(t, adoptDir, adopted.Source)
+
+	out, err := Inspect(adoptRef, opts...)
+	require.NoError(t, err)
+	assert.Contains(t, out, `"operation": "adopt"`)
+
+	unknown, err := Origin(refOnServer(s.URL, "unknown:1"), opts...)
+	require.NoError(t, err)
+	assert.Nil(t, unknown)
+}
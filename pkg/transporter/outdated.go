@@ -0,0 +1,130 @@
+package transporter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/layout"
+)
+
+// ImageStatus compares a local image with what its tag resolves to remotely.
+type ImageStatus struct {
+	Reference    name.Reference
+	LocalDigest  string
+	RemoteDigest string
+	Outdated     bool
+	// Err is set if the image could not be checked, other images are checked regardless.
+	Err error
+}
+
+// localDigest returns digest the local image had remotely, from its origin or from its local manifest.
+// Images without remote counterpart, like clones or adopted directories, return false.
+func localDigest(lm *layout.Mapper, ref name.Reference) (string, bool, error) {
+	origin, err := lm.Origin(ref)
+	if err != nil {
+		return "", false, err
+	}
+	if origin != nil {
+		if origin.Parent != "" || origin.Reference == "" || origin.Reference != ref.String() {
+			return "", false, nil
+		}
+		if origin.Digest != "" {
+			return origin.Digest, true, nil
+		}
+	}
+	raw, err := lm.RawManifest(ref)
+	if err != nil {
+		return "", false, err
+	}
+	digest, _, err := v1.SHA256(bytes.NewReader(raw))
+	if err != nil {
+		return "", false, err
+	}
+	return digest.String(), true, nil
+}
+
+// checkImage resolves the tag of the local image with a HEAD request.
+func checkImage(lm *layout.Mapper, ref name.Reference, opts *options) (ImageStatus, bool) {
+	status := ImageStatus{Reference: ref}
+	local, tracked, err := localDigest(lm, ref)
+	if err != nil {
+		status.Err = err
+		return status, true
+	}
+	if !tracked {
+		return status, false
+	}
+	status.LocalDigest = local
+	remoteRef, err := opts.parseReference(ref.String(), name.StrictValidation)
+	if err != nil {
+		status.Err = err
+		return status, true
+	}
+	desc, err := remote.Head(remoteRef, opts.remoteOptions...)
+	if err != nil {
+		status.Err = fmt.Errorf("unable to resolve '%v': %w", ref, err)
+		return status, true
+	}
+	status.RemoteDigest = desc.Digest.String()
+	status.Outdated = status.RemoteDigest != status.LocalDigest
+	return status, true
+}
+
+// CheckUpdates compares given local images, or all local images pulled from a registry if none are given,
+// with what their tags resolve to remotely. Only HEAD requests are made. Clones and adopted images are skipped
+// unless given explicitly, in which case they are reported with an error.
+func CheckUpdates(refs []string, opt ...Option) ([]ImageStatus, error) {
+	opts := makeOptions(opt...)
+	lm := layout.NewMapper(opts.imagesPath)
+	explicit := len(refs) > 0
+	var parsed []name.Reference
+	if explicit {
+		for _, r := range refs {
+			ref, err := name.ParseReference(r, name.StrictValidation)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse reference '%v': %w", r, err)
+			}
+			parsed = append(parsed, ref)
+		}
+	} else {
+		var err error
+		if parsed, err = lm.Images(); err != nil {
+			return nil, fmt.Errorf("unable to list local images: %w", err)
+		}
+	}
+	res := make([]ImageStatus, 0, len(parsed))
+	for _, ref := range parsed {
+		if err := opts.ctx.Err(); err != nil {
+			return res, err
+		}
+		status, tracked := checkImage(lm, ref, opts)
+		if !tracked {
+			if !explicit {
+				continue
+			}
+			status.Err = fmt.Errorf("'%v' was not pulled from a registry, it can not be updated", ref)
+		}
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+// Update pulls newer versions of given local images, or of all outdated ones if none are given.
+// It returns statuses of checked images, those with Outdated set were pulled.
+func Update(refs []string, opt ...Option) ([]ImageStatus, error) {
+	statuses, err := CheckUpdates(refs, opt...)
+	if err != nil {
+		return statuses, err
+	}
+	for _, s := range statuses {
+		if s.Err != nil || !s.Outdated {
+			continue
+		}
+		if err := Pull(s.Reference.String(), opt...); err != nil {
+			return statuses, fmt.Errorf("unable to update '%v': %w", s.Reference, err)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,67 @@
+package transporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckUpdatesAndUpdate(t *testing.T) {
+	var rec []http.Request
+	s := httptest.NewServer(prepareRegistryWithRecorder(&rec))
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "updated-vm:1")
+	makeTestVMAt(t, tempDir, ref)
+	require.NoError(t, Push(ref, opts...))
+	deleteTestVMAt(t, tempDir, ref)
+	require.NoError(t, Pull(ref, opts...))
+	require.NoError(t, Clone(ref, refOnServer(s.URL, "updated-clone:1"), opts...))
+
+	rec = nil
+	statuses, err := CheckUpdates(nil, opts...)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, ref, statuses[0].Reference.String())
+	assert.NoError(t, statuses[0].Err)
+	assert.False(t, statuses[0].Outdated)
+	manifestRequests := 0
+	for _, r := range rec {
+		if strings.Contains(r.URL.Path, "/manifests/") {
+			manifestRequests++
+			assert.Equal(t, http.MethodHead, r.Method, r.URL.Path)
+		}
+	}
+	assert.Equal(t, 1, manifestRequests)
+
+	// somebody else pushes a new version of the tag
+	otherDir, otherOpts := optionsForTesting(t)
+	defer os.RemoveAll(otherDir)
+	sha := makeTestVMWithContent(t, otherDir, ref, "new version of the disk")
+	require.NoError(t, Push(ref, otherOpts...))
+
+	statuses, err = CheckUpdates([]string{ref}, opts...)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Outdated)
+	assert.NotEqual(t, statuses[0].LocalDigest, statuses[0].RemoteDigest)
+
+	_, err = Update(nil, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	statuses, err = CheckUpdates(nil, opts...)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.False(t, statuses[0].Outdated)
+
+	statuses, err = CheckUpdates([]string{refOnServer(s.URL, "updated-clone:1")}, opts...)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.ErrorContains(t, statuses[0].Err, "was not pulled from a registry")
+}
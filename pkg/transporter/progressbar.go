@@ -4,22 +4,55 @@ import (
 	"fmt"
 	"github.com/macvmio/geranos/pkg/bitarray"
 	"github.com/macvmio/geranos/pkg/dirimage"
+	"strings"
+	"time"
 )
 
 type ProgressUpdate dirimage.ProgressUpdate
 
+// stageLabel returns label printed before the progress bar, updates without stage are labeled as progress.
+func stageLabel(stage string) string {
+	if stage == "" {
+		return "Progress"
+	}
+	return strings.ToUpper(stage[:1]) + stage[1:]
+}
+
+// estimateRemaining extrapolates time left from the rate since the stage started.
+func estimateRemaining(elapsed time.Duration, processed, total int64) string {
+	if processed <= 0 || elapsed < time.Second {
+		return ""
+	}
+	remaining := time.Duration(float64(elapsed) * float64(total-processed) / float64(processed))
+	return fmt.Sprintf(" ETA %v", remaining.Round(time.Second))
+}
+
 func PrintProgress(progress <-chan ProgressUpdate) {
 	const maxSize = 800
 	ba := bitarray.New(maxSize)
-	updateProgress := func(progress int64) {
+	stage := ""
+	started := time.Now()
+	updateProgress := func(progress int64, eta string) {
 		ba.Fill(int(progress))
-		fmt.Printf("\rProgress: %s %d%%", ba, progress/8)
+		fmt.Printf("\r%s: %s %d%%%s", stageLabel(stage), ba, progress/8, eta)
 	}
 	last := int64(0)
 	for p := range progress {
+		if p.Stage != stage {
+			if last > 0 {
+				fmt.Printf("\n")
+			}
+			stage = p.Stage
+			started = time.Now()
+			ba = bitarray.New(maxSize)
+			last = 0
+		}
+		if p.BytesTotal <= 0 {
+			continue
+		}
 		current := maxSize * p.BytesProcessed / p.BytesTotal
 		if current != last {
-			updateProgress(current)
+			updateProgress(current, estimateRemaining(time.Since(started), p.BytesProcessed, p.BytesTotal))
 		}
 		last = current
 	}
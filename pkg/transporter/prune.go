@@ -0,0 +1,144 @@
+package transporter
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which tags of a remote repository are kept by PruneRemotely.
+// A tag is kept if it is one of KeepLast most recently created tags or matches one of KeepPatterns.
+// When OlderThan is set, only tags created earlier than that are deleted.
+type RetentionPolicy struct {
+	KeepLast     int
+	KeepPatterns []string
+	OlderThan    time.Duration
+}
+
+// PruneDecision describes what PruneRemotely does with a single tag.
+type PruneDecision struct {
+	Tag     string
+	Digest  v1.Hash
+	Created time.Time
+	Delete  bool
+	Reason  string
+}
+
+// RemoveRemotely deletes manifest referenced by tag or digest from the registry.
+func RemoveRemotely(imageRef string, opt ...Option) error {
+	opts := makeOptions(opt...)
+	ref, err := opts.parseReference(imageRef, opts.refValidation)
+	if err != nil {
+		return fmt.Errorf("unable to parse reference '%v': %w", imageRef, err)
+	}
+	if err := remote.Delete(ref, opts.remoteOptions...); err != nil {
+		return fmt.Errorf("unable to delete '%v': %w", ref, err)
+	}
+	return nil
+}
+
+func (p *RetentionPolicy) decide(tags []PruneDecision, now time.Time) ([]PruneDecision, error) {
+	if p.KeepLast <= 0 && p.OlderThan <= 0 {
+		return nil, fmt.Errorf("retention policy must keep last tags or delete tags older than given duration")
+	}
+	patterns := make([]*regexp.Regexp, 0, len(p.KeepPatterns))
+	for _, pattern := range p.KeepPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep pattern '%v': %w", pattern, err)
+		}
+		patterns = append(patterns, re)
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].Created.Equal(tags[j].Created) {
+			return tags[i].Tag > tags[j].Tag
+		}
+		return tags[i].Created.After(tags[j].Created)
+	})
+	for i := range tags {
+		d := &tags[i]
+		switch {
+		case p.KeepLast > 0 && i < p.KeepLast:
+			d.Reason = fmt.Sprintf("one of %d most recent tags", p.KeepLast)
+		case matchesAny(patterns, d.Tag):
+			d.Reason = "matches keep pattern"
+		case p.OlderThan > 0 && now.Sub(d.Created) < p.OlderThan:
+			d.Reason = fmt.Sprintf("created less than %v ago", p.OlderThan)
+		default:
+			d.Delete = true
+			d.Reason = "not retained by policy"
+		}
+	}
+	return tags, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// PruneRemotely deletes tags of the remote repository which are not retained by the policy.
+// Creation time is taken from the image config. Index tags without creation time, e.g. with no variant
+// for this host, are always kept. With WithDryRun nothing is deleted, decisions are only returned.
+func PruneRemotely(repoName string, policy RetentionPolicy, opt ...Option) ([]PruneDecision, error) {
+	opts := makeOptions(opt...)
+	repo, err := opts.parseRepository(repoName, opts.refValidation)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse repository '%v': %w", repoName, err)
+	}
+	tags, err := remote.List(repo, opts.remoteOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tags of '%v': %w", repo, err)
+	}
+	decisions := make([]PruneDecision, 0, len(tags))
+	var skipped []PruneDecision
+	for _, tag := range tags {
+		ref := repo.Tag(tag)
+		desc, err := remote.Get(ref, opts.remoteOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch manifest of '%v': %w", ref, err)
+		}
+		// creation time of an index is taken from the variant matching the host, or from the assembled split image
+		img, err := opts.imageFromDescriptor(desc)
+		if err != nil && desc.MediaType.IsIndex() {
+			skipped = append(skipped, PruneDecision{Tag: tag, Digest: desc.Digest, Reason: fmt.Sprintf("creation time of the index is unknown: %v", err)})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch image '%v': %w", ref, err)
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read config of '%v': %w", ref, err)
+		}
+		decisions = append(decisions, PruneDecision{Tag: tag, Digest: desc.Digest, Created: cfg.Created.Time})
+	}
+	decisions, err = policy.decide(decisions, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	decisions = append(decisions, skipped...)
+	if opts.dryRun {
+		return decisions, nil
+	}
+	for _, d := range decisions {
+		if !d.Delete {
+			continue
+		}
+		if opts.verbose {
+			log.Printf("deleting '%v' (%v)", repo.Tag(d.Tag), d.Digest)
+		}
+		if err := remote.Delete(repo.Tag(d.Tag), opts.remoteOptions...); err != nil {
+			return decisions, fmt.Errorf("unable to delete '%v': %w", repo.Tag(d.Tag), err)
+		}
+	}
+	return decisions, nil
+}
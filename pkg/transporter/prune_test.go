@@ -0,0 +1,154 @@
+package transporter
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/variant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func pushImageCreatedAt(t *testing.T, ref string, created time.Time) {
+	t.Helper()
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	img, err = mutate.CreatedAt(img, v1.Time{Time: created})
+	require.NoError(t, err)
+	r, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(r, img))
+}
+
+func remoteTags(t *testing.T, repo string) []string {
+	t.Helper()
+	r, err := name.NewRepository(repo)
+	require.NoError(t, err)
+	tags, err := remote.List(r)
+	require.NoError(t, err)
+	sort.Strings(tags)
+	return tags
+}
+
+func TestRemoveRemotely_ByTagAndDigest(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:1.0"), time.Now())
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:2.0"), time.Now())
+
+	require.NoError(t, RemoveRemotely(refOnServer(s.URL, "vm:1.0"), opts...))
+	assert.Equal(t, []string{"2.0"}, remoteTags(t, refOnServer(s.URL, "vm")))
+
+	tagRef, err := name.ParseReference(refOnServer(s.URL, "vm:2.0"))
+	require.NoError(t, err)
+	desc, err := remote.Head(tagRef)
+	require.NoError(t, err)
+	require.NoError(t, RemoveRemotely(refOnServer(s.URL, "vm@"+desc.Digest.String()), opts...))
+	_, err = remote.Get(tagRef.Context().Digest(desc.Digest.String()))
+	assert.Error(t, err)
+
+	err = RemoveRemotely(refOnServer(s.URL, "vm:missing"), opts...)
+	assert.Error(t, err)
+}
+
+func TestPruneRemotely_KeepsLastAndMatchingTags(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	now := time.Now()
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:nightly-1"), now.Add(-96*time.Hour))
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:nightly-2"), now.Add(-72*time.Hour))
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:release-1"), now.Add(-60*time.Hour))
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:nightly-3"), now.Add(-48*time.Hour))
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:nightly-4"), now.Add(-1*time.Hour))
+
+	policy := RetentionPolicy{KeepLast: 2, KeepPatterns: []string{"^release-"}}
+	decisions, err := PruneRemotely(refOnServer(s.URL, "vm"), policy, append(opts, WithDryRun(true))...)
+	require.NoError(t, err)
+	deleted := make([]string, 0)
+	for _, d := range decisions {
+		if d.Delete {
+			deleted = append(deleted, d.Tag)
+		}
+	}
+	assert.Equal(t, []string{"nightly-2", "nightly-1"}, deleted)
+	assert.Len(t, remoteTags(t, refOnServer(s.URL, "vm")), 5, "dry run must not delete anything")
+
+	_, err = PruneRemotely(refOnServer(s.URL, "vm"), policy, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nightly-3", "nightly-4", "release-1"}, remoteTags(t, refOnServer(s.URL, "vm")))
+}
+
+func TestPruneRemotely_DeletesOnlyOlderThan(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	now := time.Now()
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:old"), now.Add(-30*24*time.Hour))
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:recent"), now.Add(-2*time.Hour))
+
+	_, err := PruneRemotely(refOnServer(s.URL, "vm"), RetentionPolicy{OlderThan: 7 * 24 * time.Hour}, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"recent"}, remoteTags(t, refOnServer(s.URL, "vm")))
+}
+
+func TestPruneRemotely_RequiresPolicy(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:1.0"), time.Now())
+
+	_, err := PruneRemotely(refOnServer(s.URL, "vm"), RetentionPolicy{KeepPatterns: []string{"^v"}}, opts...)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"1.0"}, remoteTags(t, refOnServer(s.URL, "vm")))
+}
+
+func TestPruneRemotely_HandlesIndexTags(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts, indexRef, _, _ := pushVariants(t, s)
+	defer os.RemoveAll(tempDir)
+
+	// index without a variant for this host has no creation time, it is kept
+	m1 := variant.Properties{variant.KeyOS: "darwin", variant.KeyOSVersion: "10.15", variant.KeyCPU: "M1"}
+	require.NoError(t, Push(refOnServer(s.URL, "vm:1.0-m1"), append(opts, WithIndex(refOnServer(s.URL, "vm:m1-only")), WithVariant(m1))...))
+	pushImageCreatedAt(t, refOnServer(s.URL, "vm:old"), time.Now().Add(-30*24*time.Hour))
+
+	decisions, err := PruneRemotely(refOnServer(s.URL, "vm"), RetentionPolicy{OlderThan: 7 * 24 * time.Hour}, opts...)
+	require.NoError(t, err)
+	ref, err := name.ParseReference(indexRef)
+	require.NoError(t, err)
+	desc, err := remote.Head(ref)
+	require.NoError(t, err)
+	tags := make([]string, 0, len(decisions))
+	for _, d := range decisions {
+		tags = append(tags, d.Tag)
+		switch d.Tag {
+		case "1.0":
+			assert.Equal(t, desc.Digest, d.Digest)
+			assert.False(t, d.Delete)
+		case "m1-only":
+			assert.False(t, d.Delete)
+			assert.Contains(t, d.Reason, "creation time of the index is unknown")
+		}
+	}
+	sort.Strings(tags)
+	assert.Equal(t, []string{"1.0", "1.0-host", "1.0-m1", "m1-only", "old"}, tags)
+	assert.Equal(t, []string{"1.0", "1.0-host", "1.0-m1", "m1-only"}, remoteTags(t, refOnServer(s.URL, "vm")))
+}
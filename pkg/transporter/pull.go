@@ -1,21 +1,74 @@
 package transporter
 
 import (
+	"fmt"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/macvmio/geranos/pkg/layout"
 )
 
+func fetchImage(src string, opts *options) (name.Reference, v1.Image, error) {
+	ref, err := opts.parseReference(src, name.StrictValidation)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := opts.pullPolicy.CheckReference(ref); err != nil {
+		return nil, nil, err
+	}
+	img, err := opts.fetchMirrored(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := opts.pullPolicy.CheckImage(ref, img); err != nil {
+		return nil, nil, err
+	}
+	// digest of the index, if the image was selected from one
+	resolved, err := resolvedDigest(img)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get digest: %w", err)
+	}
+	if err := opts.pullPolicy.CheckDigest(ref, resolved.String()); err != nil {
+		return nil, nil, err
+	}
+	return ref, img, nil
+}
+
 func Pull(src string, opt ...Option) error {
 	opts := makeOptions(opt...)
-	ref, err := name.ParseReference(src, name.StrictValidation)
+	ref, img, err := fetchImage(src, opts)
 	if err != nil {
 		return err
 	}
-	img, err := remote.Image(ref, opts.remoteOptions...)
-	if err != nil {
+	if err := writePulled(ref, img, opts); err != nil {
 		return err
 	}
+	digest, err := resolvedDigest(img)
+	if err != nil {
+		return fmt.Errorf("unable to get digest: %w", err)
+	}
+	lm := layout.NewMapper(opts.imagesPath)
+	return lm.WriteOrigin(ref, &layout.Origin{Operation: layout.OperationPull, Reference: ref.String(), Digest: digest.String()})
+}
+
+// resolvedDigest returns digest the reference of the fetched image resolved to, which is what HEAD request returns.
+func resolvedDigest(img v1.Image) (v1.Hash, error) {
+	if mi, ok := img.(*mirroredImage); ok {
+		return mi.resolved, nil
+	}
+	return img.Digest()
+}
+
+// writePulled writes the image to local images according to its format.
+func writePulled(ref name.Reference, img v1.Image, opts *options) error {
+	if opts.format == FormatTart {
+		return pullTart(ref, img, opts)
+	}
+	if isTartImage(img) {
+		return fmt.Errorf("'%v' is a Tart image, it can be pulled with format '%v'", ref, FormatTart)
+	}
+	if isGenericArtifact(img) {
+		return pullArtifact(ref, img, opts)
+	}
 	// Cache is not important if Sketch is working properly
 	//img = cache.Image(img, diskcache.NewFilesystemCache(opts.cachePath))
 	lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
@@ -24,3 +77,21 @@ func Pull(src string, opt ...Option) error {
 	}
 	return lm.WriteIfNotPresent(opts.ctx, img, ref)
 }
+
+// PlanPull reports which files Pull would clone from local images and how much it would download,
+// only manifest and config are fetched and nothing is modified.
+func PlanPull(src string, opt ...Option) (*layout.WritePlan, error) {
+	opts := makeOptions(opt...)
+	ref, img, err := fetchImage(src, opts)
+	if err != nil {
+		return nil, err
+	}
+	if isTartImage(img) {
+		return nil, fmt.Errorf("'%v' is a Tart image, transfer plan is not available for it", ref)
+	}
+	if isGenericArtifact(img) {
+		return nil, fmt.Errorf("'%v' is not a geranos image, transfer plan is not available for it", ref)
+	}
+	lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
+	return lm.PlanWrite(opts.ctx, img, ref)
+}
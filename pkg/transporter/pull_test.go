@@ -3,6 +3,7 @@ package transporter
 import (
 	"fmt"
 	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/macvmio/geranos/pkg/policy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
@@ -232,3 +233,78 @@ func TestPull_WithForceOption(t *testing.T) {
 		assert.Equal(t, shaBefore, shaAfter)
 	})
 }
+
+func TestPull_PolicyViolationDoesNotWriteAnything(t *testing.T) {
+	recordedRequests := make([]http.Request, 0)
+	s := httptest.NewServer(prepareRegistryWithRecorder(&recordedRequests))
+	defer s.Close()
+
+	tempDir, opts := optionsForTesting(t)
+
+	ref := refOnServer(s.URL, "test-vm:1.0")
+	makeTestVMAt(t, tempDir, ref)
+	err := Push(ref, opts...)
+	require.NoError(t, err)
+	deleteTestVMAt(t, tempDir, ref)
+
+	t.Run("reference rejected before contacting registry", func(t *testing.T) {
+		clear(recordedRequests)
+		err := Pull(ref, append(opts, WithPullPolicy(&policy.Policy{AllowedRegistries: []string{"ghcr.io"}}))...)
+		v, ok := policy.IsViolation(err)
+		require.True(t, ok, "expected policy violation, got %v", err)
+		assert.Equal(t, policy.RuleAllowedRegistries, v.Rule)
+		assert.Equal(t, 0, calculateAccessed(recordedRequests, "GET", "/manifests"))
+	})
+
+	t.Run("image rejected before writing", func(t *testing.T) {
+		err := Pull(ref, append(opts, WithPullPolicy(&policy.Policy{RequiredLabels: []string{"com.example.approved"}}))...)
+		v, ok := policy.IsViolation(err)
+		require.True(t, ok, "expected policy violation, got %v", err)
+		assert.Equal(t, policy.RuleRequiredLabels, v.Rule)
+		assert.NoDirExists(t, filepath.Join(tempDir, "images", portableRef(ref)))
+	})
+}
+
+func TestPlanPull_ReportsClonesAndBytesToDownload(t *testing.T) {
+	recordedRequests := make([]http.Request, 0)
+	s := httptest.NewServer(prepareRegistryWithRecorder(&recordedRequests))
+	defer s.Close()
+
+	tempDir, opts := optionsForTesting(t)
+	baseRef := refOnServer(s.URL, "test-vm:1.0")
+	updatedRef := refOnServer(s.URL, "test-vm:1.1")
+	makeBigTestVMAt(t, tempDir, baseRef)
+	require.NoError(t, Push(baseRef, opts...))
+	require.NoError(t, Clone(baseRef, updatedRef, opts...))
+	modifyBigTestVMAt(t, tempDir, updatedRef, 100)
+	require.NoError(t, Push(updatedRef, opts...))
+	require.NoError(t, os.RemoveAll(tempDir))
+
+	tempDir, opts = optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, Pull(baseRef, opts...))
+
+	plan, err := PlanPull(baseRef, opts...)
+	require.NoError(t, err)
+	assert.True(t, plan.UpToDate)
+
+	recordedRequests = recordedRequests[:0]
+	plan, err = PlanPull(updatedRef, opts...)
+	require.NoError(t, err)
+	assert.False(t, plan.UpToDate)
+	require.Len(t, plan.Files, 1)
+	f := plan.Files[0]
+	assert.Equal(t, "disk.img", f.Filename)
+	assert.False(t, f.Exists)
+	assert.Equal(t, filepath.Join(tempDir, "images", portableRef(baseRef), "disk.img"), f.CloneSource)
+	assert.Equal(t, 4, f.MatchedSegments)
+	assert.Equal(t, 4, f.MatchingSegments)
+	assert.Equal(t, int64(270*1024*1024-4*64*1024*1024), f.BytesToDownload)
+	assert.Equal(t, f.BytesToDownload, plan.BytesToDownload)
+	assert.Greater(t, plan.CompressedBytesToDownload, int64(0))
+
+	// only config blob is downloaded and nothing is written
+	assert.Equal(t, 1, calculateAccessed(recordedRequests, "GET", "/blobs/"))
+	_, err = os.Stat(filepath.Join(tempDir, "images", portableRef(updatedRef)))
+	assert.True(t, os.IsNotExist(err))
+}
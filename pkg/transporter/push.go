@@ -10,6 +10,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"log"
 	"os"
+	"time"
 )
 
 func prePushConcurrently(repo name.Repository, img v1.Image, opts *options) error {
@@ -17,13 +18,20 @@ func prePushConcurrently(repo name.Repository, img v1.Image, opts *options) erro
 	if err != nil {
 		return fmt.Errorf("unable to extract layers from image: %w", err)
 	}
+	return prePushLayersConcurrently(repo, layers, opts)
+}
+
+// prePushLayersConcurrently uploads layers missing in the repository, layers shared by several images
+// are uploaded once.
+func prePushLayersConcurrently(repo name.Repository, layers []v1.Layer, opts *options) error {
 	g, ctx := errgroup.WithContext(opts.ctx)
 	g.SetLimit(opts.workersCount)
 
 	seen := make(map[string]bool, 0)
+	unique := make([]v1.Layer, 0, len(layers))
+	var bytesTotal int64
 	for _, l := range layers {
-		currentLayer := l
-		h, err := currentLayer.Digest()
+		h, err := l.Digest()
 		if err != nil {
 			return err
 		}
@@ -31,17 +39,45 @@ func prePushConcurrently(repo name.Repository, img v1.Image, opts *options) erro
 			continue
 		}
 		seen[h.String()] = true
+		size, err := l.Size()
+		if err != nil {
+			return err
+		}
+		bytesTotal += size
+		unique = append(unique, l)
+	}
+	var progress *uploadProgress
+	if opts.progress != nil {
+		progress = newUploadProgress(opts.progress, bytesTotal)
+	}
+	for _, l := range unique {
+		currentLayer := l
 		g.Go(func() error {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
 			default:
 			}
+			h, err := currentLayer.Digest()
+			if err != nil {
+				return err
+			}
+			size, err := currentLayer.Size()
+			if err != nil {
+				return err
+			}
+			remoteOpts := opts.remoteOptions
+			if progress != nil {
+				remoteOpts = append(append([]remote.Option{}, remoteOpts...), progress.option(size))
+			}
 			log.Printf("pushing layer: %v", h)
-			return remote.WriteLayer(repo, currentLayer, opts.remoteOptions...)
+			return remote.WriteLayer(repo, currentLayer, remoteOpts...)
 		})
 	}
-	err = g.Wait()
+	err := g.Wait()
+	if progress != nil {
+		progress.wait()
+	}
 	if err != nil {
 		return fmt.Errorf("error occured while pushing layers concurrently: %w", err)
 	}
@@ -51,20 +87,45 @@ func prePushConcurrently(repo name.Repository, img v1.Image, opts *options) erro
 func Push(imageRef string, opt ...Option) error {
 	logs.Progress = log.New(os.Stdout, "", log.LstdFlags)
 	opts := makeOptions(opt...)
+	start := time.Now()
+	defer func() {
+		opts.pushStats.setElapsed(time.Since(start))
+	}()
 
-	ref, err := name.ParseReference(imageRef)
+	ref, err := opts.parseReference(imageRef)
 	if err != nil {
 		return fmt.Errorf("unable to parse reference '%v': %w", imageRef, err)
 	}
 
-	lm := layout.NewMapper(opts.imagesPath)
+	var indexRef name.Reference
+	if opts.indexReference != "" {
+		indexRef, err = opts.parseReference(opts.indexReference)
+		if err != nil {
+			return fmt.Errorf("unable to parse index reference '%v': %w", opts.indexReference, err)
+		}
+		if indexRef.Context() != ref.Context() {
+			return fmt.Errorf("index '%v' must be in the same repository as the image", indexRef)
+		}
+	}
+
+	lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
 
 	img, err := lm.Read(opts.ctx, ref)
 	if err != nil {
 		return fmt.Errorf("unable to read image from disk: %w", err)
 	}
-	if opts.mountedReference != nil {
-		img = layout.NewMountableImage(img, opts.mountedReference)
+	sources, err := lm.MountSources(img, ref)
+	if err != nil {
+		log.Printf("unable to find local images to mount blobs from: %v", err)
+	}
+	if len(sources) > 0 {
+		log.Printf("%d blobs are shared with local images from %v, they are mounted before uploading", len(sources), ref.Context().RegistryStr())
+	}
+	if opts.mountedReference != nil || len(sources) > 0 {
+		img = layout.NewMountableImageWithSources(img, opts.mountedReference, sources)
+	}
+	if err := opts.pushStats.addSizes(img); err != nil {
+		return err
 	}
 
 	if opts.workersCount > 0 {
@@ -74,8 +135,19 @@ func Push(imageRef string, opt ...Option) error {
 		}
 	}
 
-	if err := remote.Write(ref, img, opts.remoteOptions...); err != nil {
+	pushed, err := pushImage(ref, img, opts)
+	if err != nil {
 		return fmt.Errorf("unable to push image to registry: %w", err)
 	}
+	origin := &layout.Origin{Operation: layout.OperationPush, Reference: ref.String()}
+	if digest, err := pushed.Digest(); err == nil {
+		origin.Digest = digest.String()
+	}
+	if err := lm.WriteOrigin(ref, origin); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	if opts.indexReference != "" {
+		return addToIndex(indexRef, pushed, opts)
+	}
 	return nil
 }
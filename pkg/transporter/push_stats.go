@@ -0,0 +1,202 @@
+package transporter
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"net/http"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StageUploading is set in progress updates sent by Push while blobs are uploaded.
+const StageUploading = "uploading"
+
+type blobState int
+
+const (
+	blobMissing blobState = iota
+	blobExisting
+	blobMounted
+)
+
+// PushSummary describes blobs handled by Push. Blobs the registry already had are not uploaded,
+// neither are blobs mounted from another repository.
+type PushSummary struct {
+	UploadedBlobs int
+	UploadedBytes int64
+	ExistingBlobs int
+	ExistingBytes int64
+	MountedBlobs  int
+	MountedBytes  int64
+	Elapsed       time.Duration
+}
+
+// PushStatistics is filled by Push from requests sent to the registry. Zero value is ready to use.
+type PushStatistics struct {
+	mu            sync.Mutex
+	sizes         map[v1.Hash]int64
+	blobs         map[v1.Hash]blobState
+	uploadedBlobs int
+	uploadedBytes int64
+	elapsed       time.Duration
+}
+
+// addSizes records sizes of the blobs of the image, which are not part of mount responses.
+func (s *PushStatistics) addSizes(img v1.Image) error {
+	if s == nil {
+		return nil
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("unable to get manifest: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sizes == nil {
+		s.sizes = make(map[v1.Hash]int64)
+	}
+	s.sizes[manifest.Config.Digest] = manifest.Config.Size
+	for _, l := range manifest.Layers {
+		s.sizes[l.Digest] = l.Size
+	}
+	return nil
+}
+
+func (s *PushStatistics) setElapsed(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.elapsed = d
+}
+
+// observe classifies blobs by the first existence check, so blobs uploaded earlier in the same push
+// are not counted as existing when layers are checked again.
+func (s *PushStatistics) observe(req *http.Request, resp *http.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.blobs == nil {
+		s.blobs = make(map[v1.Hash]blobState)
+	}
+	if s.sizes == nil {
+		s.sizes = make(map[v1.Hash]int64)
+	}
+	switch req.Method {
+	case http.MethodHead:
+		h, err := v1.NewHash(path.Base(req.URL.Path))
+		if err != nil || path.Base(path.Dir(req.URL.Path)) != "blobs" {
+			return
+		}
+		if _, seen := s.blobs[h]; seen {
+			return
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			s.blobs[h] = blobExisting
+			if resp.ContentLength > 0 {
+				s.sizes[h] = resp.ContentLength
+			}
+		case http.StatusNotFound:
+			s.blobs[h] = blobMissing
+		}
+	case http.MethodPost:
+		h, err := v1.NewHash(req.URL.Query().Get("mount"))
+		if err != nil || resp.StatusCode != http.StatusCreated || s.blobs[h] == blobExisting {
+			return
+		}
+		s.blobs[h] = blobMounted
+	case http.MethodPatch:
+		if resp.StatusCode/100 == 2 && req.ContentLength > 0 {
+			s.uploadedBytes += req.ContentLength
+		}
+	case http.MethodPut:
+		if req.URL.Query().Get("digest") != "" && resp.StatusCode == http.StatusCreated {
+			s.uploadedBlobs++
+		}
+	}
+}
+
+// Summary returns statistics of the push.
+func (s *PushStatistics) Summary() PushSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := PushSummary{UploadedBlobs: s.uploadedBlobs, UploadedBytes: s.uploadedBytes, Elapsed: s.elapsed}
+	for h, state := range s.blobs {
+		switch state {
+		case blobExisting:
+			res.ExistingBlobs++
+			res.ExistingBytes += s.sizes[h]
+		case blobMounted:
+			res.MountedBlobs++
+			res.MountedBytes += s.sizes[h]
+		}
+	}
+	return res
+}
+
+// pushStatsTransport records blob requests of Push.
+type pushStatsTransport struct {
+	base  http.RoundTripper
+	stats *PushStatistics
+}
+
+func (t *pushStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.stats.observe(req, resp)
+	}
+	return resp, err
+}
+
+// uploadProgress turns progress of concurrent layer uploads into updates of the whole push.
+type uploadProgress struct {
+	c         chan<- ProgressUpdate
+	total     int64
+	processed atomic.Int64
+	wg        sync.WaitGroup
+}
+
+func newUploadProgress(c chan<- ProgressUpdate, total int64) *uploadProgress {
+	return &uploadProgress{c: c, total: total}
+}
+
+// option returns option reporting progress of a single upload of the blob of given size, remote closes
+// the channel when it is done. Bytes remote does not report, e.g. the last read of a blob streamed from
+// another registry, are added when the upload succeeds.
+func (up *uploadProgress) option(size int64) remote.Option {
+	updates := make(chan v1.Update)
+	up.wg.Add(1)
+	go func() {
+		defer up.wg.Done()
+		var last int64
+		failed := false
+		for u := range updates {
+			if u.Error != nil {
+				failed = true
+				continue
+			}
+			up.send(u.Complete - last)
+			last = u.Complete
+		}
+		if !failed && last < size {
+			up.send(size - last)
+		}
+	}()
+	return remote.WithProgress(updates)
+}
+
+func (up *uploadProgress) send(delta int64) {
+	current := up.processed.Add(delta)
+	select {
+	case up.c <- ProgressUpdate{BytesProcessed: current, BytesTotal: up.total, Stage: StageUploading}:
+	default:
+	}
+}
+
+func (up *uploadProgress) wait() {
+	up.wg.Wait()
+}
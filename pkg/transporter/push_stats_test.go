@@ -0,0 +1,63 @@
+package transporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPush_Statistics(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "stats-vm:1")
+	makeTestVMAt(t, tempDir, ref)
+
+	progress := make(chan ProgressUpdate, 1000)
+	stats := &PushStatistics{}
+	require.NoError(t, Push(ref, append(opts, WithPushStatistics(stats), WithProgressChannel(progress))...))
+	summary := stats.Summary()
+	assert.Equal(t, 3, summary.UploadedBlobs)
+	assert.Positive(t, summary.UploadedBytes)
+	assert.Zero(t, summary.ExistingBlobs)
+	assert.Zero(t, summary.MountedBlobs)
+	assert.Positive(t, summary.Elapsed)
+
+	var lastUpload ProgressUpdate
+	for len(progress) > 0 {
+		if p := <-progress; p.Stage == StageUploading {
+			lastUpload = p
+		}
+	}
+	assert.Positive(t, lastUpload.BytesTotal)
+	assert.Equal(t, lastUpload.BytesTotal, lastUpload.BytesProcessed)
+
+	// segments are already in the registry, only the config with new creation time is uploaded
+	stats = &PushStatistics{}
+	require.NoError(t, Push(ref, append(opts, WithPushStatistics(stats))...))
+	summary = stats.Summary()
+	assert.Equal(t, 1, summary.UploadedBlobs)
+	assert.Equal(t, 2, summary.ExistingBlobs)
+	assert.Positive(t, summary.ExistingBytes)
+}
+
+func TestPushStatistics_Mounted(t *testing.T) {
+	stats := &PushStatistics{}
+	digest := "sha256:" + strings.Repeat("a", 64)
+	head := httptest.NewRequest(http.MethodHead, "/v2/vm/blobs/"+digest, nil)
+	stats.observe(head, &http.Response{StatusCode: http.StatusNotFound})
+	mount := httptest.NewRequest(http.MethodPost, "/v2/vm/blobs/uploads/?mount="+digest+"&from=base", nil)
+	stats.observe(mount, &http.Response{StatusCode: http.StatusCreated})
+	// later existence checks do not change the classification
+	stats.observe(head, &http.Response{StatusCode: http.StatusOK, ContentLength: 10})
+
+	summary := stats.Summary()
+	assert.Equal(t, 1, summary.MountedBlobs)
+	assert.Zero(t, summary.ExistingBlobs)
+	assert.Zero(t, summary.UploadedBlobs)
+}
@@ -0,0 +1,199 @@
+package transporter
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/filesegment"
+	"github.com/macvmio/geranos/pkg/sketch"
+	"sort"
+)
+
+// SegmentState describes a segment of one image compared with the other image.
+type SegmentState string
+
+const (
+	// SegmentShared is present in both images at the same range of the same file.
+	SegmentShared SegmentState = "shared"
+	// SegmentChanged covers a range which exists in both images but has different content.
+	SegmentChanged SegmentState = "changed"
+	// SegmentAdded is present only in the newer image, beyond end of the file in the older one.
+	SegmentAdded SegmentState = "added"
+	// SegmentRemoved is present only in the older image, beyond end of the file in the newer one.
+	SegmentRemoved SegmentState = "removed"
+)
+
+// FileDiff compares a single file of two images. From and To are segment maps of the file in both images.
+type FileDiff struct {
+	Filename        string         `json:"filename"`
+	Status          string         `json:"status"`
+	SharedSegments  int            `json:"shared_segments"`
+	SharedBytes     int64          `json:"shared_bytes"`
+	ChangedSegments int            `json:"changed_segments"`
+	ChangedBytes    int64          `json:"changed_bytes"`
+	AddedSegments   int            `json:"added_segments"`
+	AddedBytes      int64          `json:"added_bytes"`
+	RemovedSegments int            `json:"removed_segments"`
+	RemovedBytes    int64          `json:"removed_bytes"`
+	DownloadBytes   int64          `json:"download_bytes"`
+	From            []SegmentState `json:"from,omitempty"`
+	To              []SegmentState `json:"to,omitempty"`
+}
+
+// ImageDiff compares two images using only their manifests and configs.
+// DownloadBytes is compressed size of segments a host holding From needs to download to get To.
+type ImageDiff struct {
+	From          string     `json:"from"`
+	To            string     `json:"to"`
+	Files         []FileDiff `json:"files"`
+	SharedBytes   int64      `json:"shared_bytes"`
+	ChangedBytes  int64      `json:"changed_bytes"`
+	AddedBytes    int64      `json:"added_bytes"`
+	RemovedBytes  int64      `json:"removed_bytes"`
+	DownloadBytes int64      `json:"download_bytes"`
+}
+
+type segmentKey struct {
+	start  int64
+	stop   int64
+	digest v1.Hash
+}
+
+func keyOf(d *filesegment.Descriptor) segmentKey {
+	return segmentKey{start: d.Start(), stop: d.Stop(), digest: d.Digest()}
+}
+
+func remoteBlueprints(rawRef string, opts *options) (map[string]*sketch.FileBlueprint, map[v1.Hash]int64, error) {
+	ref, err := opts.parseReference(rawRef, opts.refValidation)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse reference '%v': %w", rawRef, err)
+	}
+	desc, err := remote.Get(ref, opts.remoteOptions...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to fetch image '%v': %w", ref, err)
+	}
+	img, err := opts.imageFromDescriptor(desc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to fetch image '%v': %w", ref, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get manifest of '%v': %w", ref, err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get config file of '%v': %w", ref, err)
+	}
+	blueprints, err := sketch.Blueprints(*manifest, cfg.RootFS.DiffIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse segments of '%v': %w", ref, err)
+	}
+	res := make(map[string]*sketch.FileBlueprint, len(blueprints))
+	for _, bp := range blueprints {
+		res[bp.Filename] = bp
+	}
+	compressedSizes := make(map[v1.Hash]int64, len(manifest.Layers))
+	for _, l := range manifest.Layers {
+		compressedSizes[l.Digest] = l.Size
+	}
+	return res, compressedSizes, nil
+}
+
+func diffFile(filename string, from, to *sketch.FileBlueprint, compressedSizes map[v1.Hash]int64) FileDiff {
+	fd := FileDiff{Filename: filename}
+	fromKeys := make(map[segmentKey]bool)
+	toKeys := make(map[segmentKey]bool)
+	var fromSize, toSize int64
+	if from != nil {
+		fromSize = from.Size()
+		for _, s := range from.Segments {
+			fromKeys[keyOf(s)] = true
+		}
+	}
+	if to != nil {
+		toSize = to.Size()
+		for _, s := range to.Segments {
+			toKeys[keyOf(s)] = true
+		}
+	}
+	if from != nil {
+		for _, s := range from.Segments {
+			switch {
+			case toKeys[keyOf(s)]:
+				fd.From = append(fd.From, SegmentShared)
+			case s.Start() < toSize:
+				fd.From = append(fd.From, SegmentChanged)
+			default:
+				fd.From = append(fd.From, SegmentRemoved)
+				fd.RemovedSegments++
+				fd.RemovedBytes += s.Length()
+			}
+		}
+	}
+	if to != nil {
+		for _, s := range to.Segments {
+			switch {
+			case fromKeys[keyOf(s)]:
+				fd.To = append(fd.To, SegmentShared)
+				fd.SharedSegments++
+				fd.SharedBytes += s.Length()
+				continue
+			case s.Start() < fromSize:
+				fd.To = append(fd.To, SegmentChanged)
+				fd.ChangedSegments++
+				fd.ChangedBytes += s.Length()
+			default:
+				fd.To = append(fd.To, SegmentAdded)
+				fd.AddedSegments++
+				fd.AddedBytes += s.Length()
+			}
+			fd.DownloadBytes += compressedSizes[s.Digest()]
+		}
+	}
+	switch {
+	case from == nil:
+		fd.Status = "added"
+	case to == nil:
+		fd.Status = "removed"
+	case fd.ChangedSegments+fd.AddedSegments+fd.RemovedSegments == 0 && fromSize == toSize:
+		fd.Status = "unchanged"
+	default:
+		fd.Status = "modified"
+	}
+	return fd
+}
+
+// DiffRemotely compares two remote images file by file, only manifests and configs are downloaded.
+func DiffRemotely(fromRef, toRef string, opt ...Option) (*ImageDiff, error) {
+	opts := makeOptions(opt...)
+	fromFiles, _, err := remoteBlueprints(fromRef, opts)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, compressedSizes, err := remoteBlueprints(toRef, opts)
+	if err != nil {
+		return nil, err
+	}
+	filenames := make([]string, 0, len(fromFiles)+len(toFiles))
+	for filename := range fromFiles {
+		filenames = append(filenames, filename)
+	}
+	for filename := range toFiles {
+		if _, present := fromFiles[filename]; !present {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	res := &ImageDiff{From: fromRef, To: toRef, Files: make([]FileDiff, 0, len(filenames))}
+	for _, filename := range filenames {
+		fd := diffFile(filename, fromFiles[filename], toFiles[filename], compressedSizes)
+		res.Files = append(res.Files, fd)
+		res.SharedBytes += fd.SharedBytes
+		res.ChangedBytes += fd.ChangedBytes
+		res.AddedBytes += fd.AddedBytes
+		res.RemovedBytes += fd.RemovedBytes
+		res.DownloadBytes += fd.DownloadBytes
+	}
+	return res, nil
+}
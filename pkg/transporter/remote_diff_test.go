@@ -0,0 +1,93 @@
+package transporter
+
+import (
+	"context"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pushWithFiles pushes image consisting of given files, split into 7 bytes long segments
+func pushWithFiles(t *testing.T, tempDir, ref string, files map[string]string) {
+	t.Helper()
+	d := filepath.Join(tempDir, "images", portableRef(ref))
+	require.NoError(t, os.MkdirAll(d, os.ModePerm))
+	for filename, content := range files {
+		makeFileAt(t, filepath.Join(d, filename), content)
+	}
+	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
+	require.NoError(t, err)
+	img, err := layout.NewMapper(filepath.Join(tempDir, "images"), dirimage.WithChunkSize(7)).Read(context.Background(), parsedRef)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(parsedRef, img))
+}
+
+func TestDiffRemotely_ComparesSegmentsOfFiles(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	fromRef := refOnServer(s.URL, "vm:1.0")
+	toRef := refOnServer(s.URL, "vm:1.1")
+	pushWithFiles(t, tempDir, fromRef, map[string]string{
+		"disk.img":    "aaaaaaabbbbbbbccccc",
+		"config.json": "{}",
+		"notes.txt":   "removed",
+	})
+	pushWithFiles(t, tempDir, toRef, map[string]string{
+		"disk.img":    "aaaaaaaBBBBBBBcccccccddddd",
+		"config.json": "{}",
+		"extra.txt":   "added",
+	})
+
+	diff, err := DiffRemotely(fromRef, toRef, opts...)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Files, 4)
+	byName := make(map[string]FileDiff)
+	for _, f := range diff.Files {
+		byName[f.Filename] = f
+	}
+	assert.Equal(t, "unchanged", byName["config.json"].Status)
+	assert.Equal(t, int64(0), byName["config.json"].DownloadBytes)
+
+	disk := byName["disk.img"]
+	assert.Equal(t, "modified", disk.Status)
+	assert.Equal(t, []SegmentState{SegmentShared, SegmentChanged, SegmentChanged}, disk.From)
+	assert.Equal(t, []SegmentState{SegmentShared, SegmentChanged, SegmentChanged, SegmentAdded}, disk.To)
+	assert.Equal(t, int64(7), disk.SharedBytes)
+	assert.Equal(t, int64(14), disk.ChangedBytes)
+	assert.Equal(t, int64(5), disk.AddedBytes)
+	assert.Greater(t, disk.DownloadBytes, int64(0))
+
+	assert.Equal(t, "removed", byName["notes.txt"].Status)
+	assert.Equal(t, int64(7), byName["notes.txt"].RemovedBytes)
+	assert.Equal(t, "added", byName["extra.txt"].Status)
+	assert.Equal(t, int64(5), byName["extra.txt"].AddedBytes)
+
+	assert.Equal(t, disk.DownloadBytes+byName["extra.txt"].DownloadBytes, diff.DownloadBytes)
+	assert.Equal(t, int64(10), diff.AddedBytes)
+}
+
+func TestDiffRemotely_SameImageHasNothingToDownload(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	ref := refOnServer(s.URL, "vm:1.0")
+	pushWithFiles(t, tempDir, ref, map[string]string{"disk.img": "aaaaaaabbbbbbbccccc"})
+
+	diff, err := DiffRemotely(ref, ref, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), diff.DownloadBytes)
+	assert.Equal(t, int64(19), diff.SharedBytes)
+}
@@ -0,0 +1,156 @@
+package transporter
+
+import (
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/sketch"
+	"github.com/macvmio/geranos/pkg/variant"
+	"time"
+)
+
+// FileSummary describes a single file of an image.
+type FileSummary struct {
+	Filename       string `json:"filename"`
+	Size           int64  `json:"size"`
+	Segments       int    `json:"segments"`
+	CompressedSize int64  `json:"compressed_size"`
+}
+
+// VariantSummary describes an image listed in an index.
+type VariantSummary struct {
+	Digest     string `json:"digest"`
+	Properties string `json:"properties"`
+	Selected   bool   `json:"selected"`
+}
+
+// ImageSummary describes an image using only its manifest and config. If the reference points to an index,
+// its variants are listed and the selected variant, if any, is described. Image split into parts is described as a whole.
+type ImageSummary struct {
+	Reference      string            `json:"reference"`
+	Digest         string            `json:"digest"`
+	Index          string            `json:"index,omitempty"`
+	Variants       []VariantSummary  `json:"variants,omitempty"`
+	Parts          int               `json:"parts,omitempty"`
+	Created        time.Time         `json:"created"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Files          []FileSummary     `json:"files"`
+	Size           int64             `json:"size"`
+	Segments       int               `json:"segments"`
+	CompressedSize int64             `json:"compressed_size"`
+}
+
+func summarize(ref name.Reference, img v1.Image) (*ImageSummary, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %w", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to calculate digest: %w", err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get config file: %w", err)
+	}
+	blueprints, err := sketch.Blueprints(*manifest, cfg.RootFS.DiffIDs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse segments: %w", err)
+	}
+	compressedSizes := make(map[v1.Hash]int64, len(manifest.Layers))
+	for _, l := range manifest.Layers {
+		compressedSizes[l.Digest] = l.Size
+	}
+	res := &ImageSummary{
+		Reference: ref.String(),
+		Digest:    digest.String(),
+		Created:   cfg.Created.Time,
+		Labels:    cfg.Config.Labels,
+		Files:     make([]FileSummary, 0, len(blueprints)),
+	}
+	for _, bp := range blueprints {
+		fs := FileSummary{
+			Filename: bp.Filename,
+			Size:     bp.Size(),
+			Segments: len(bp.Segments),
+		}
+		for _, s := range bp.Segments {
+			fs.CompressedSize += compressedSizes[s.Digest()]
+		}
+		res.Files = append(res.Files, fs)
+		res.Size += fs.Size
+		res.Segments += fs.Segments
+		res.CompressedSize += fs.CompressedSize
+	}
+	return res, nil
+}
+
+// InspectRemotely describes remote image, only its manifest and config are downloaded.
+func InspectRemotely(rawRef string, opt ...Option) (*ImageSummary, error) {
+	opts := makeOptions(opt...)
+	ref, err := opts.parseReference(rawRef, opts.refValidation)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse reference: %w", err)
+	}
+	desc, err := remote.Get(ref, opts.remoteOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch image '%v': %w", ref, err)
+	}
+	if !desc.MediaType.IsIndex() {
+		img, err := desc.Image()
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch image '%v': %w", ref, err)
+		}
+		return summarize(ref, img)
+	}
+	return summarizeIndex(ref, desc, opts)
+}
+
+func summarizeIndex(ref name.Reference, desc *remote.Descriptor, opts *options) (*ImageSummary, error) {
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read index '%v': %w", ref, err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get index manifest: %w", err)
+	}
+	if dirimage.IsSplit(manifest) {
+		img, err := dirimage.Assemble(idx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to assemble split image '%v': %w", ref, err)
+		}
+		res, err := summarize(ref, img)
+		if err != nil {
+			return nil, err
+		}
+		res.Index = desc.Digest.String()
+		res.Parts = len(manifest.Manifests)
+		return res, nil
+	}
+	selected, selectErr := opts.selectFromIndex(manifest)
+	if selectErr != nil && len(opts.variant) > 0 {
+		return nil, selectErr
+	}
+	res := &ImageSummary{Reference: ref.String(), Digest: desc.Digest.String()}
+	if selectErr == nil {
+		img, err := opts.imageFromEntry(idx, selected)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch variant '%v': %w", selected.Digest, err)
+		}
+		if res, err = summarize(ref, img); err != nil {
+			return nil, err
+		}
+	}
+	res.Index = desc.Digest.String()
+	for _, d := range manifest.Manifests {
+		res.Variants = append(res.Variants, VariantSummary{
+			Digest:     d.Digest.String(),
+			Properties: variant.Descriptor(d).String(),
+			Selected:   selectErr == nil && d.Digest == selected.Digest,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package transporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestInspectRemotely_DescribesFilesWithoutDownloadingSegments(t *testing.T) {
+	recordedRequests := make([]http.Request, 0)
+	s := httptest.NewServer(prepareRegistryWithRecorder(&recordedRequests))
+	defer s.Close()
+
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "test-vm:1.0")
+	makeTestVMAt(t, tempDir, ref)
+	require.NoError(t, Push(ref, opts...))
+	blobsBefore := calculateAccessed(recordedRequests, "GET", "/blobs/")
+
+	summary, err := InspectRemotely(ref, opts...)
+	require.NoError(t, err)
+
+	require.Len(t, summary.Files, 2)
+	assert.Equal(t, FileSummary{Filename: "config.json", Size: 19, Segments: 1, CompressedSize: summary.Files[0].CompressedSize}, summary.Files[0])
+	assert.Equal(t, FileSummary{Filename: "disk.img", Size: 20, Segments: 1, CompressedSize: summary.Files[1].CompressedSize}, summary.Files[1])
+	assert.Equal(t, int64(39), summary.Size)
+	assert.Equal(t, 2, summary.Segments)
+	assert.Equal(t, summary.Files[0].CompressedSize+summary.Files[1].CompressedSize, summary.CompressedSize)
+	assert.Greater(t, summary.CompressedSize, int64(0))
+	assert.False(t, summary.Created.IsZero())
+	assert.Equal(t, ref, summary.Reference)
+
+	// only config blob is downloaded
+	assert.Equal(t, 1, calculateAccessed(recordedRequests, "GET", "/blobs/")-blobsBefore)
+}
+
+func TestInspectRemotely_MissingImage(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	_, opts := optionsForTesting(t)
+
+	_, err := InspectRemotely(refOnServer(s.URL, "missing:1.0"), opts...)
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package transporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/macvmio/geranos/pkg/server"
+	"log"
+	"net/http"
+	"path/filepath"
+)
+
+func newServer(opts *options) (*server.Server, error) {
+	printf := func(fmt string, args ...any) {}
+	if opts.verbose {
+		printf = log.Printf
+	}
+	serverOpts := []server.Option{
+		server.WithLogFunction(printf),
+		server.WithCacheMaxSize(opts.serveCacheSize),
+	}
+	if opts.serveUpstream == "" {
+		if opts.serveCachePath != "" {
+			serverOpts = append(serverOpts, server.WithCacheDir(opts.serveCachePath))
+		}
+		lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
+		return server.New(lm, serverOpts...)
+	}
+
+	upstream, err := opts.parseRegistry(opts.serveUpstream, name.StrictValidation)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse upstream registry '%v': %w", opts.serveUpstream, err)
+	}
+	cachePath := opts.serveCachePath
+	if cachePath == "" {
+		cachePath = filepath.Join(opts.cachePath, "proxy")
+	}
+	serverOpts = append(serverOpts,
+		server.WithCacheDir(cachePath),
+		server.WithRemoteOptions(opts.remoteOptions...))
+	return server.NewProxy(upstream, serverOpts...)
+}
+
+// Serve exposes local images as a read-only OCI registry until context is cancelled.
+// With WithServeUpstream it works as a pull-through caching proxy instead.
+func Serve(addr string, opt ...Option) error {
+	opts := makeOptions(opt...)
+	handler, err := newServer(opts)
+	if err != nil {
+		return fmt.Errorf("unable to create server: %w", err)
+	}
+	srv := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-opts.ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	if opts.serveUpstream != "" {
+		log.Printf("proxying '%v' on %v", opts.serveUpstream, addr)
+	} else {
+		log.Printf("serving images from '%v' on %v", opts.imagesPath, addr)
+	}
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
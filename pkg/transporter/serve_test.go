@@ -0,0 +1,119 @@
+package transporter
+
+import (
+	"context"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/macvmio/geranos/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// prepareServedImage creates local image with manifest, ready to be served
+func prepareServedImage(t *testing.T, tempDir, ref string) (sha string) {
+	t.Helper()
+	sha = makeTestVMAt(t, tempDir, ref)
+	parsedRef, err := name.ParseReference(ref, name.StrictValidation)
+	require.NoError(t, err)
+	lm := layout.NewMapper(filepath.Join(tempDir, "images"), dirimage.WithChunkSize(7))
+	require.NoError(t, lm.Rehash(context.Background(), parsedRef))
+	return sha
+}
+
+func TestServe_PullFromServedImages(t *testing.T) {
+	for testName, withCache := range map[string]bool{"compressed on the fly": false, "compressed into cache": true} {
+		t.Run(testName, func(t *testing.T) {
+			servedDir, _ := optionsForTesting(t)
+			shaBefore := prepareServedImage(t, servedDir, "local.test/vm:1.0")
+
+			var serverOpts []server.Option
+			if withCache {
+				serverOpts = append(serverOpts, server.WithCacheDir(filepath.Join(servedDir, "cache")))
+			}
+			srv, err := server.New(layout.NewMapper(filepath.Join(servedDir, "images")), serverOpts...)
+			require.NoError(t, err)
+			s := httptest.NewServer(srv)
+			defer s.Close()
+
+			tempDir, opts := optionsForTesting(t)
+			ref := refOnServer(s.URL, "local.test/vm:1.0")
+			err = Pull(ref, opts...)
+			require.NoError(t, err)
+
+			shaAfter := hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img"))
+			assert.Equal(t, shaBefore, shaAfter)
+			assert.FileExists(t, filepath.Join(tempDir, "images", portableRef(ref), "config.json"))
+		})
+	}
+}
+
+func TestServe_ListsTagsAndRejectsWrites(t *testing.T) {
+	servedDir, _ := optionsForTesting(t)
+	prepareServedImage(t, servedDir, "local.test/vm:1.0")
+
+	srv, err := server.New(layout.NewMapper(filepath.Join(servedDir, "images")))
+	require.NoError(t, err)
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	repo, err := name.NewRepository(refOnServer(s.URL, "local.test/vm"))
+	require.NoError(t, err)
+	tags, err := remote.List(repo)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0"}, tags)
+
+	_, err = remote.Head(repo.Tag("2.0"))
+	assert.Error(t, err)
+
+	resp, err := http.Post(s.URL+"/v2/local.test/vm/blobs/uploads/", "application/octet-stream", strings.NewReader(""))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServe_ProxyCachesBlobsFromUpstream(t *testing.T) {
+	recordedRequests := make([]http.Request, 0)
+	upstream := httptest.NewServer(prepareRegistryWithRecorder(&recordedRequests))
+	defer upstream.Close()
+
+	pushDir, pushOpts := optionsForTesting(t)
+	upstreamRef := refOnServer(upstream.URL, "macvmio/test-vm:1.0")
+	shaBefore := makeTestVMAt(t, pushDir, upstreamRef)
+	require.NoError(t, Push(upstreamRef, pushOpts...))
+
+	upstreamRegistry, err := name.NewRegistry(strings.TrimPrefix(upstream.URL, "http://"))
+	require.NoError(t, err)
+	srv, err := server.NewProxy(upstreamRegistry, server.WithCacheDir(filepath.Join(pushDir, "proxy")))
+	require.NoError(t, err)
+	proxy := httptest.NewServer(srv)
+	defer proxy.Close()
+
+	proxiedRef := refOnServer(proxy.URL, "macvmio/test-vm:1.0")
+	for i := 0; i < 2; i++ {
+		clear(recordedRequests)
+		tempDir, opts := optionsForTesting(t)
+		err = Pull(proxiedRef, opts...)
+		require.NoError(t, err)
+		shaAfter := hashFromFile(t, filepath.Join(tempDir, "images", portableRef(proxiedRef), "disk.img"))
+		assert.Equal(t, shaBefore, shaAfter)
+		if i == 0 {
+			assert.Equal(t, 3, calculateAccessed(recordedRequests, "GET", "/blobs"))
+		} else {
+			assert.Equal(t, 0, calculateAccessed(recordedRequests, "GET", "/blobs"))
+			assert.Equal(t, 0, calculateAccessed(recordedRequests, "GET", "/manifests"))
+		}
+	}
+
+	status := srv.Status()
+	assert.Equal(t, "proxy", status.Mode)
+	require.NotNil(t, status.Cache)
+	assert.Equal(t, int64(4), status.Cache.Entries)
+	assert.Equal(t, int64(4), status.Cache.Hits)
+}
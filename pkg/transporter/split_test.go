@@ -0,0 +1,59 @@
+package transporter
+
+import (
+	"context"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPushAndPull_SplitManifest(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "test-vm:1.0")
+	sha := makeTestVMAt(t, tempDir, ref)
+	// fixed config makes digest of the image the same on every read
+	makeFileAt(t, filepath.Join(tempDir, "images", portableRef(ref), dirimage.LocalConfigFilename), `{"created":"2024-01-01T00:00:00Z"}`)
+
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	local, err := layout.NewMapper(filepath.Join(tempDir, "images")).Read(context.Background(), parsed)
+	require.NoError(t, err)
+	localDigest, err := local.Digest()
+	require.NoError(t, err)
+	rawManifest, err := local.RawManifest()
+	require.NoError(t, err)
+
+	require.NoError(t, Push(ref, append(opts, WithMaxManifestSize(int64(len(rawManifest)-1)))...))
+
+	idx, err := remote.Index(parsed)
+	require.NoError(t, err)
+	index, err := idx.IndexManifest()
+	require.NoError(t, err)
+	assert.True(t, dirimage.IsSplit(index))
+	assert.Len(t, index.Manifests, 2)
+
+	summary, err := InspectRemotely(ref, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Parts)
+	assert.Equal(t, localDigest.String(), summary.Digest)
+	assert.Len(t, summary.Files, 2)
+
+	deleteTestVMAt(t, tempDir, ref)
+	require.NoError(t, Pull(ref, opts...))
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+	pulled, err := layout.NewMapper(filepath.Join(tempDir, "images")).Read(context.Background(), parsed)
+	require.NoError(t, err)
+	pulledDigest, err := pulled.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, localDigest, pulledDigest)
+}
@@ -0,0 +1,262 @@
+package transporter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/macvmio/geranos/pkg/layout"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+)
+
+// SyncEntry is a single image of the desired state.
+type SyncEntry struct {
+	Reference string `yaml:"reference"`
+	// Digest pins the image to the manifest digest, the image is pulled by digest and stored under Reference.
+	Digest string `yaml:"digest,omitempty"`
+	// Retain keeps the local image when pruning after the entry is dropped from the spec, as long as the lock
+	// file of the previous sync is given. Otherwise it is pulled, updated and pinned like other entries.
+	Retain bool `yaml:"retain,omitempty"`
+	// NoUpdate pulls the image if it is missing, but keeps the local version when the tag moves.
+	NoUpdate bool `yaml:"no_update,omitempty"`
+}
+
+// SyncSpec is the desired set of local images. Lock files have the same format with digests of all pulled entries.
+type SyncSpec struct {
+	Images []SyncEntry `yaml:"images"`
+}
+
+// ReadSyncSpec reads the desired state from the YAML file.
+func ReadSyncSpec(path string) (*SyncSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%v': %w", path, err)
+	}
+	var spec SyncSpec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("unable to parse '%v': %w", path, err)
+	}
+	return &spec, nil
+}
+
+// WriteSyncSpec writes the spec as YAML, it is used for lock files.
+func WriteSyncSpec(path string, spec *SyncSpec) error {
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("unable to marshal '%v': %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("unable to write '%v': %w", path, err)
+	}
+	return nil
+}
+
+// SyncAction is what Sync does with a single image.
+type SyncAction string
+
+const (
+	SyncActionPull     SyncAction = "pull"
+	SyncActionUpdate   SyncAction = "update"
+	SyncActionUpToDate SyncAction = "up-to-date"
+	SyncActionRetain   SyncAction = "retain"
+	SyncActionRemove   SyncAction = "remove"
+)
+
+// SyncStep describes an image of the plan made by Sync.
+type SyncStep struct {
+	Reference name.Reference
+	Action    SyncAction
+	// LocalDigest is the digest of the local image, empty if the image is missing or was not pulled from a registry.
+	LocalDigest string
+	// Digest is the pinned digest or what the tag resolved to.
+	Digest string
+	// Err is set if the image could not be planned or synced, other images are synced regardless.
+	Err error
+}
+
+// planEntry decides what to do with the entry, the registry is only asked with a HEAD request for unpinned entries.
+func planEntry(lm *layout.Mapper, present map[string]bool, e SyncEntry, opts *options) SyncStep {
+	ref, err := name.ParseReference(e.Reference, name.StrictValidation)
+	if err != nil {
+		return SyncStep{Action: SyncActionPull, Err: fmt.Errorf("unable to parse reference '%v': %w", e.Reference, err)}
+	}
+	step := SyncStep{Reference: ref, Digest: e.Digest}
+	if e.Digest != "" {
+		if _, err := v1.NewHash(e.Digest); err != nil {
+			step.Action = SyncActionPull
+			step.Err = fmt.Errorf("invalid digest of '%v': %w", ref, err)
+			return step
+		}
+	}
+	exists := present[ref.String()]
+	if exists {
+		local, tracked, err := localDigest(lm, ref)
+		if err != nil {
+			step.Action = SyncActionUpdate
+			step.Err = err
+			return step
+		}
+		if tracked {
+			step.LocalDigest = local
+		}
+	}
+	switch {
+	case !exists:
+		step.Action = SyncActionPull
+	case e.NoUpdate:
+		step.Action = SyncActionUpToDate
+		if step.Digest == "" {
+			step.Digest = step.LocalDigest
+		}
+		return step
+	default:
+		step.Action = SyncActionUpdate
+	}
+	if step.Digest == "" {
+		remoteRef, err := opts.parseReference(ref.String(), name.StrictValidation)
+		if err != nil {
+			step.Err = err
+			return step
+		}
+		desc, err := remote.Head(remoteRef, opts.remoteOptions...)
+		if err != nil {
+			step.Err = fmt.Errorf("unable to resolve '%v': %w", ref, err)
+			return step
+		}
+		step.Digest = desc.Digest.String()
+	}
+	if exists && step.LocalDigest == step.Digest {
+		step.Action = SyncActionUpToDate
+	}
+	return step
+}
+
+// PlanSync compares the desired state with local images. With prune, local images not listed in the spec
+// are planned for removal. Retained entries of the lock set by WithSyncLock are kept even if they are not listed.
+func PlanSync(spec *SyncSpec, prune bool, opt ...Option) ([]SyncStep, error) {
+	return planSync(spec, prune, makeOptions(opt...))
+}
+
+func planSync(spec *SyncSpec, prune bool, opts *options) ([]SyncStep, error) {
+	lm := layout.NewMapper(opts.imagesPath)
+	local, err := lm.Images()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list local images: %w", err)
+	}
+	present := make(map[string]bool, len(local))
+	for _, ref := range local {
+		present[ref.String()] = true
+	}
+	steps := make([]SyncStep, 0, len(spec.Images))
+	listed := make(map[string]bool, len(spec.Images))
+	for _, e := range spec.Images {
+		if err := opts.ctx.Err(); err != nil {
+			return steps, err
+		}
+		step := planEntry(lm, present, e, opts)
+		if step.Reference != nil {
+			listed[step.Reference.String()] = true
+		}
+		steps = append(steps, step)
+	}
+	retained := make(map[string]bool)
+	if opts.syncLock != nil {
+		for _, e := range opts.syncLock.Images {
+			if ref, err := name.ParseReference(e.Reference, name.StrictValidation); err == nil && e.Retain {
+				retained[ref.String()] = true
+			}
+		}
+	}
+	for _, ref := range local {
+		switch {
+		case listed[ref.String()]:
+		case retained[ref.String()]:
+			step := SyncStep{Reference: ref, Action: SyncActionRetain}
+			if digest, tracked, err := localDigest(lm, ref); err == nil && tracked {
+				step.LocalDigest = digest
+			}
+			steps = append(steps, step)
+		case prune:
+			steps = append(steps, SyncStep{Reference: ref, Action: SyncActionRemove})
+		}
+	}
+	return steps, nil
+}
+
+// pullPinned pulls the image by digest and stores it under the tag of the entry.
+func pullPinned(ref name.Reference, digest string, opts *options) error {
+	src := ref.Context().Digest(digest).String()
+	_, img, err := fetchImage(src, opts)
+	if err != nil {
+		return err
+	}
+	if err := writePulled(ref, img, opts); err != nil {
+		return err
+	}
+	lm := layout.NewMapper(opts.imagesPath)
+	return lm.WriteOrigin(ref, &layout.Origin{Operation: layout.OperationPull, Reference: ref.String(), Digest: digest})
+}
+
+// Sync brings local images to the desired state: missing and outdated images are pulled, and with prune
+// local images not listed are removed. Images already in the desired state are not touched, so running it
+// again does nothing. With WithDryRun only the plan is returned.
+func Sync(spec *SyncSpec, prune bool, opt ...Option) ([]SyncStep, error) {
+	opts := makeOptions(opt...)
+	steps, err := planSync(spec, prune, opts)
+	if err != nil || opts.dryRun {
+		return steps, err
+	}
+	lm := layout.NewMapper(opts.imagesPath)
+	for i := range steps {
+		s := &steps[i]
+		if s.Err != nil {
+			continue
+		}
+		if err := opts.ctx.Err(); err != nil {
+			return steps, err
+		}
+		switch s.Action {
+		case SyncActionPull, SyncActionUpdate:
+			if opts.verbose {
+				log.Printf("pulling '%v' (%v)", s.Reference, s.Digest)
+			}
+			if err := pullPinned(s.Reference, s.Digest, opts); err != nil {
+				s.Err = fmt.Errorf("unable to pull '%v': %w", s.Reference, err)
+				continue
+			}
+			s.LocalDigest = s.Digest
+		case SyncActionRemove:
+			if opts.verbose {
+				log.Printf("removing '%v'", s.Reference)
+			}
+			if err := lm.Remove(s.Reference); err != nil {
+				s.Err = fmt.Errorf("unable to remove '%v': %w", s.Reference, err)
+			}
+		}
+	}
+	return steps, nil
+}
+
+// Lock returns the spec with each entry pinned to the digest it was synced to. Entries which failed
+// are kept as they are. Images kept by retained entries of the previous lock stay retained, so later
+// syncs do not prune them either.
+func (s *SyncSpec) Lock(steps []SyncStep) *SyncSpec {
+	res := &SyncSpec{Images: make([]SyncEntry, 0, len(s.Images))}
+	for i, e := range s.Images {
+		if i < len(steps) && steps[i].Err == nil && steps[i].LocalDigest != "" {
+			e.Digest = steps[i].LocalDigest
+		}
+		res.Images = append(res.Images, e)
+	}
+	for _, step := range steps {
+		if step.Action == SyncActionRetain {
+			res.Images = append(res.Images, SyncEntry{Reference: step.Reference.String(), Digest: step.LocalDigest, Retain: true})
+		}
+	}
+	return res
+}
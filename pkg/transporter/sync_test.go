@@ -0,0 +1,170 @@
+package transporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func syncActions(steps []SyncStep) map[string]SyncAction {
+	res := make(map[string]SyncAction, len(steps))
+	for _, s := range steps {
+		res[s.Reference.String()] = s.Action
+	}
+	return res
+}
+
+func TestSync(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	remoteDir, remoteOpts := optionsForTesting(t)
+	defer os.RemoveAll(remoteDir)
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	refA := refOnServer(s.URL, "sync-a:1")
+	refB := refOnServer(s.URL, "sync-b:1")
+	shaA := makeTestVMWithContent(t, remoteDir, refA, "first version of a")
+	require.NoError(t, Push(refA, remoteOpts...))
+	makeTestVMWithContent(t, remoteDir, refB, "first version of b")
+	require.NoError(t, Push(refB, remoteOpts...))
+	kept := refOnServer(s.URL, "sync-kept:1")
+	makeTestVMWithContent(t, remoteDir, kept, "retained")
+	require.NoError(t, Push(kept, remoteOpts...))
+
+	require.NoError(t, Pull(refA, opts...))
+	unlisted := refOnServer(s.URL, "sync-unlisted:1")
+	require.NoError(t, Clone(refA, unlisted, opts...))
+	require.NoError(t, Remove(refA, opts...))
+
+	spec := &SyncSpec{Images: []SyncEntry{
+		{Reference: refA},
+		{Reference: refB},
+		{Reference: kept, Retain: true},
+	}}
+	steps, err := Sync(spec, true, append(opts, WithDryRun(true))...)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SyncAction{
+		refA:     SyncActionPull,
+		refB:     SyncActionPull,
+		kept:     SyncActionPull,
+		unlisted: SyncActionRemove,
+	}, syncActions(steps))
+	_, err = os.Stat(filepath.Join(tempDir, "images", portableRef(refA)))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tempDir, "images", portableRef(unlisted)))
+	assert.NoError(t, err)
+
+	steps, err = Sync(spec, true, opts...)
+	require.NoError(t, err)
+	for _, step := range steps {
+		assert.NoError(t, step.Err)
+	}
+	assert.Equal(t, shaA, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(refA), "disk.img")))
+	_, err = os.Stat(filepath.Join(tempDir, "images", portableRef(unlisted)))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tempDir, "images", portableRef(kept)))
+	assert.NoError(t, err)
+
+	lock := spec.Lock(steps)
+	require.Len(t, lock.Images, 3)
+	assert.Equal(t, steps[0].Digest, lock.Images[0].Digest)
+	assert.NotEmpty(t, lock.Images[1].Digest)
+	assert.NotEmpty(t, lock.Images[2].Digest)
+	assert.Equal(t, steps[2].Digest, lock.Images[2].Digest)
+	lockPath := filepath.Join(tempDir, "images.lock.yaml")
+	require.NoError(t, WriteSyncSpec(lockPath, lock))
+	readLock, err := ReadSyncSpec(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, lock, readLock)
+
+	// running again does nothing
+	steps, err = Sync(spec, true, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SyncAction{
+		refA: SyncActionUpToDate,
+		refB: SyncActionUpToDate,
+		kept: SyncActionUpToDate,
+	}, syncActions(steps))
+
+	// new version of a is pushed, the lock file keeps the synced one
+	makeTestVMWithContent(t, remoteDir, refA, "second version of a")
+	require.NoError(t, Push(refA, remoteOpts...))
+	steps, err = PlanSync(readLock, false, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, SyncActionUpToDate, steps[0].Action)
+	steps, err = PlanSync(spec, false, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, SyncActionUpdate, steps[0].Action)
+	assert.NotEqual(t, steps[0].LocalDigest, steps[0].Digest)
+	noUpdate := &SyncSpec{Images: []SyncEntry{{Reference: refA, NoUpdate: true}}}
+	steps, err = PlanSync(noUpdate, false, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, SyncActionUpToDate, steps[0].Action)
+}
+
+func TestSync_RetainedEntryIsKeptAfterItIsDropped(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	remoteDir, remoteOpts := optionsForTesting(t)
+	defer os.RemoveAll(remoteDir)
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	refA := refOnServer(s.URL, "retain-a:1")
+	dropped := refOnServer(s.URL, "retain-dropped:1")
+	kept := refOnServer(s.URL, "retain-kept:1")
+	for _, ref := range []string{refA, dropped, kept} {
+		makeTestVMWithContent(t, remoteDir, ref, "content of "+ref)
+		require.NoError(t, Push(ref, remoteOpts...))
+	}
+
+	spec := &SyncSpec{Images: []SyncEntry{{Reference: refA}, {Reference: dropped}, {Reference: kept, Retain: true}}}
+	steps, err := Sync(spec, true, opts...)
+	require.NoError(t, err)
+	lock := spec.Lock(steps)
+
+	spec = &SyncSpec{Images: []SyncEntry{{Reference: refA}}}
+	steps, err = PlanSync(spec, true, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SyncAction{
+		refA:    SyncActionUpToDate,
+		dropped: SyncActionRemove,
+		kept:    SyncActionRemove,
+	}, syncActions(steps))
+
+	steps, err = Sync(spec, true, append(opts, WithSyncLock(lock))...)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SyncAction{
+		refA:    SyncActionUpToDate,
+		dropped: SyncActionRemove,
+		kept:    SyncActionRetain,
+	}, syncActions(steps))
+	_, err = os.Stat(filepath.Join(tempDir, "images", portableRef(dropped)))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tempDir, "images", portableRef(kept)))
+	assert.NoError(t, err)
+
+	// the new lock keeps the image retained for following syncs
+	lock = spec.Lock(steps)
+	require.Len(t, lock.Images, 2)
+	assert.Equal(t, SyncEntry{Reference: kept, Digest: steps[len(steps)-1].LocalDigest, Retain: true}, lock.Images[1])
+	assert.NotEmpty(t, lock.Images[1].Digest)
+	steps, err = PlanSync(spec, true, append(opts, WithSyncLock(lock))...)
+	require.NoError(t, err)
+	assert.Equal(t, SyncActionRetain, syncActions(steps)[kept])
+}
+
+func TestReadSyncSpecRejectsUnknownFields(t *testing.T) {
+	tempDir := t.TempDir()
+	p := filepath.Join(tempDir, "images.yaml")
+	makeFileAt(t, p, "images:\n  - reference: ghcr.io/macvmio/vm:1\n    retian: true\n")
+	_, err := ReadSyncSpec(p)
+	assert.ErrorContains(t, err, "retian")
+
+	makeFileAt(t, p, "images:\n  - reference: ghcr.io/macvmio/vm:1\n    digest: sha256:abc\n    retain: true\n")
+	spec, err := ReadSyncSpec(p)
+	require.NoError(t, err)
+	assert.Equal(t, []SyncEntry{{Reference: "ghcr.io/macvmio/vm:1", Digest: "sha256:abc", Retain: true}}, spec.Images)
+}
@@ -14,27 +14,49 @@ func RetagRemotely(oldImageRef, newImageRef string, opt ...Option) error {
 	opts := makeOptions(opt...)
 
 	// Parse the old image reference (e.g., my-image:1.0)
-	oldRef, err := name.ParseReference(oldImageRef)
+	oldRef, err := opts.parseReference(oldImageRef)
 	if err != nil {
 		return fmt.Errorf("unable to parse old reference '%v': %w", oldImageRef, err)
 	}
 
-	// Retrieve the image manifest and config from the registry
-	img, err := remote.Image(oldRef, opts.remoteOptions...)
+	// Retrieve the manifest as it is, indexes are retagged without selecting a variant
+	desc, err := remote.Get(oldRef, opts.remoteOptions...)
 	if err != nil {
 		return fmt.Errorf("unable to fetch image from registry: %w", err)
 	}
 
 	// Parse the new image reference (e.g., my-image:latest)
-	newRef, err := name.ParseReference(newImageRef)
+	newRef, err := opts.parseReference(newImageRef)
 	if err != nil {
 		return fmt.Errorf("unable to parse new reference '%v': %w", newImageRef, err)
 	}
 
-	// Push the image with the new reference (re-tagging it)
-	if err := remote.Write(newRef, img, opts.remoteOptions...); err != nil {
+	newTag, ok := newRef.(name.Tag)
+	if !ok {
+		return fmt.Errorf("new reference '%v' must be a tag", newImageRef)
+	}
+	if err := retag(desc, oldRef, newTag, opts); err != nil {
 		return fmt.Errorf("unable to push image with new tag: %w", err)
 	}
 
 	return nil
 }
+
+// retag puts the same manifest under the new tag, blobs are copied first if the tag is in another repository.
+func retag(desc *remote.Descriptor, oldRef name.Reference, newTag name.Tag, opts *options) error {
+	if newTag.Context().Name() == oldRef.Context().Name() {
+		return remote.Tag(newTag, desc, opts.remoteOptions...)
+	}
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+		return remote.WriteIndex(newTag, idx, opts.remoteOptions...)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return remote.Write(newTag, img, opts.remoteOptions...)
+}
@@ -1,10 +1,13 @@
 package transporter
 
 import (
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -85,3 +88,24 @@ func TestRetagRemotely_FailToFetchImage(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unable to fetch image from registry")
 }
+
+func TestRetagRemotely_KeepsIndexUnchanged(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts, indexRef, _, _ := pushVariants(t, s)
+	defer os.RemoveAll(tempDir)
+
+	newRef := refOnServer(s.URL, "vm:stable")
+	require.NoError(t, RetagRemotely(indexRef, newRef, opts...))
+
+	old, err := name.ParseReference(indexRef)
+	require.NoError(t, err)
+	oldDesc, err := remote.Head(old)
+	require.NoError(t, err)
+	retagged, err := name.ParseReference(newRef)
+	require.NoError(t, err)
+	newDesc, err := remote.Head(retagged)
+	require.NoError(t, err)
+	assert.Equal(t, oldDesc.Digest, newDesc.Digest)
+	assert.True(t, newDesc.MediaType.IsIndex())
+}
@@ -0,0 +1,52 @@
+package transporter
+
+import (
+	"fmt"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/macvmio/geranos/pkg/layout"
+	"github.com/macvmio/geranos/pkg/tart"
+	"log"
+)
+
+// Formats of images Pull understands.
+const (
+	FormatGeranos = "geranos"
+	FormatTart    = "tart"
+)
+
+// ValidateFormat returns error if the format is not known, empty format means FormatGeranos.
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatGeranos, FormatTart:
+		return nil
+	}
+	return fmt.Errorf("unknown image format '%v', expected one of: %v, %v", format, FormatGeranos, FormatTart)
+}
+
+// isTartImage reports whether the image was pushed by Tart, so it can be pulled only with FormatTart.
+func isTartImage(img v1.Image) bool {
+	manifest, err := img.Manifest()
+	return err == nil && tart.IsImage(manifest)
+}
+
+func pullTart(ref name.Reference, img v1.Image, opts *options) error {
+	if !isTartImage(img) {
+		return fmt.Errorf("'%v' is not a Tart image", ref)
+	}
+	lm := layout.NewMapper(opts.imagesPath, opts.dirimageOptions...)
+	tartOpts := []tart.Option{tart.WithWorkersCount(opts.workersCount), tart.WithDiskLimiter(opts.diskLimiter)}
+	if opts.verbose {
+		tartOpts = append(tartOpts, tart.WithLogFunction(log.Printf))
+	}
+	if err := lm.WriteTart(opts.ctx, img, ref, tartOpts...); err != nil {
+		return err
+	}
+	if !opts.rehash {
+		return nil
+	}
+	if err := lm.Rehash(opts.ctx, ref); err != nil {
+		return fmt.Errorf("unable to rehash '%v': %w", ref, err)
+	}
+	return nil
+}
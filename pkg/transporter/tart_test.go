@@ -0,0 +1,74 @@
+package transporter
+
+import (
+	"encoding/binary"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/macvmio/geranos/pkg/dirimage"
+	"github.com/macvmio/geranos/pkg/tart"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// pushTartImage pushes disk split into chunks of Apple LZ4 streams with uncompressed blocks, as Tart v2 disks.
+func pushTartImage(t *testing.T, ref string, disk []byte, chunkSize int) {
+	t.Helper()
+	addendums := []mutate.Addendum{
+		{Layer: static.NewLayer([]byte(`{"os":"darwin"}`), tart.ConfigMediaType), MediaType: tart.ConfigMediaType},
+	}
+	for start := 0; start < len(disk); start += chunkSize {
+		data := disk[start:min(start+chunkSize, len(disk))]
+		stream := binary.LittleEndian.AppendUint32([]byte("bv4-"), uint32(len(data)))
+		stream = append(append(stream, data...), "bv4$"...)
+		addendums = append(addendums, mutate.Addendum{
+			Layer:       static.NewLayer(stream, tart.DiskV2MediaType),
+			MediaType:   tart.DiskV2MediaType,
+			Annotations: map[string]string{tart.UncompressedSizeAnnotationKey: strconv.Itoa(len(data))},
+		})
+	}
+	addendums = append(addendums, mutate.Addendum{Layer: static.NewLayer([]byte("nvram"), tart.NVRAMMediaType), MediaType: tart.NVRAMMediaType})
+	img, err := mutate.Append(empty.Image, addendums...)
+	require.NoError(t, err)
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(parsed, img))
+}
+
+func TestPull_TartFormat(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	ref := refOnServer(s.URL, "tart-vm:14")
+	disk := make([]byte, 10*1024)
+	copy(disk[4096:], "tart disk content")
+	pushTartImage(t, ref, disk, 4*1024)
+	dir := filepath.Join(tempDir, "images", portableRef(ref))
+
+	err := Pull(ref, opts...)
+	assert.ErrorContains(t, err, "is a Tart image")
+
+	require.NoError(t, Pull(ref, append(opts, WithFormat(FormatTart), WithRehash(true))...))
+	actual, err := os.ReadFile(filepath.Join(dir, tart.DiskFilename))
+	require.NoError(t, err)
+	assert.Equal(t, disk, actual)
+	assert.FileExists(t, filepath.Join(dir, tart.NVRAMFilename))
+	assert.FileExists(t, filepath.Join(dir, tart.ConfigFilename))
+	assert.FileExists(t, filepath.Join(dir, dirimage.LocalManifestFilename))
+
+	// rehashed image can be pushed as a native one
+	nativeRef := refOnServer(s.URL, "vm:14")
+	require.NoError(t, Clone(ref, nativeRef, opts...))
+	require.NoError(t, Push(nativeRef, opts...))
+	summary, err := InspectRemotely(nativeRef, opts...)
+	require.NoError(t, err)
+	assert.Len(t, summary.Files, 3)
+}
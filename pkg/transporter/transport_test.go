@@ -0,0 +1,121 @@
+package transporter
+
+import (
+	"crypto/rand"
+	"github.com/macvmio/geranos/pkg/throttle"
+	"github.com/macvmio/geranos/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportHosts_PlainHTTPRegistryBehindProxy(t *testing.T) {
+	recordedRequests := make([]http.Request, 0)
+	// registry handler serves proxied requests as well, since it only looks at the path
+	handler := prepareRegistryWithRecorder(&recordedRequests)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+	opts = append(opts, WithTransportHosts([]transport.Host{
+		{Registry: "lab.example:5000", Profile: transport.Profile{PlainHTTP: true, Proxy: proxy.URL}},
+	}))
+
+	ref := "lab.example:5000/vm:1.0"
+	sha := makeTestVMAt(t, tempDir, ref)
+	require.NoError(t, Push(ref, opts...))
+	assert.Greater(t, calculateAccessed(recordedRequests, "PUT", "http://lab.example:5000/v2/vm/manifests/1.0"), 0)
+
+	tags, err := ListTagsRemotely("lab.example:5000/vm", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0"}, tags)
+
+	summary, err := InspectRemotely(ref, opts...)
+	require.NoError(t, err)
+	assert.Len(t, summary.Files, 2)
+
+	deleteTestVMAt(t, tempDir, ref)
+	require.NoError(t, Pull(ref, opts...))
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+}
+
+func TestTransportHosts_InvalidProfileFails(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	_, opts := optionsForTesting(t)
+	opts = append(opts, WithTransportHosts([]transport.Host{
+		{Registry: "lab.example:5000", Profile: transport.Profile{CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+	}))
+
+	_, err := ListTagsRemotely(refOnServer(s.URL, "vm"), opts...)
+	assert.ErrorContains(t, err, "missing.pem")
+}
+
+func TestParseReference_PlainHTTP(t *testing.T) {
+	opts := makeOptions(WithTransportHosts([]transport.Host{
+		{Registry: "lab.example:5000", Profile: transport.Profile{PlainHTTP: true}},
+	}))
+	ref, err := opts.parseReference("lab.example:5000/vm:1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "http", ref.Context().Scheme())
+
+	ref, err = opts.parseReference("ghcr.io/macvmio/vm:1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "https", ref.Context().Scheme())
+
+	opts = makeOptions(WithInsecureTransport())
+	ref, err = opts.parseReference("ghcr.io/macvmio/vm:1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "http", ref.Context().Scheme())
+}
+
+func TestCheckCredentials(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	_, opts := optionsForTesting(t)
+	require.NoError(t, CheckCredentials(strings.TrimPrefix(s.URL, "http://"), "user", "secret", opts...))
+
+	denying := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer denying.Close()
+	err := CheckCredentials(strings.TrimPrefix(denying.URL, "http://"), "user", "wrong", opts...)
+	assert.Error(t, err)
+}
+
+func TestBandwidthLimiter_ThrottlesPull(t *testing.T) {
+	s := httptest.NewServer(prepareRegistry())
+	defer s.Close()
+	tempDir, opts := optionsForTesting(t)
+	defer os.RemoveAll(tempDir)
+
+	ref := refOnServer(s.URL, "vm:1.0")
+	content := make([]byte, 96*1024)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+	sha := makeTestVMWithContent(t, tempDir, ref, string(content))
+	require.NoError(t, Push(ref, opts...))
+	deleteTestVMAt(t, tempDir, ref)
+
+	start := time.Now()
+	limiter := throttle.NewLimiter(throttle.Constant(32 * 1024))
+	require.NoError(t, Pull(ref, append(opts, WithBandwidthLimiter(limiter))...))
+
+	// random content does not compress, the first 32K are the burst
+	assert.GreaterOrEqual(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, sha, hashFromFile(t, filepath.Join(tempDir, "images", portableRef(ref), "disk.img")))
+}
@@ -0,0 +1,21 @@
+package variant
+
+import (
+	"golang.org/x/sys/unix"
+	"runtime"
+)
+
+// Host returns properties of the machine the process runs on.
+func Host() Properties {
+	res := Properties{
+		KeyOS:           runtime.GOOS,
+		KeyArchitecture: runtime.GOARCH,
+	}
+	if v, err := unix.Sysctl("kern.osproductversion"); err == nil && v != "" {
+		res[KeyOSVersion] = v
+	}
+	if v, err := unix.Sysctl("machdep.cpu.brand_string"); err == nil && v != "" {
+		res[KeyCPU] = v
+	}
+	return res
+}
@@ -0,0 +1,13 @@
+//go:build !darwin
+
+package variant
+
+import "runtime"
+
+// Host returns properties of the machine the process runs on.
+func Host() Properties {
+	return Properties{
+		KeyOS:           runtime.GOOS,
+		KeyArchitecture: runtime.GOARCH,
+	}
+}
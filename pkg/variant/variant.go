@@ -0,0 +1,168 @@
+package variant
+
+import (
+	"fmt"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"sort"
+	"strings"
+)
+
+// Keys of properties stored in the platform of an index entry, all other keys are stored as annotations.
+const (
+	KeyOS           = "os"
+	KeyArchitecture = "architecture"
+	KeyVariant      = "variant"
+	KeyOSVersion    = "os.version"
+	// KeyCPU is never stored in the platform, but it is matched against the host CPU, e.g. cpu=M2.
+	KeyCPU = "cpu"
+)
+
+var aliases = map[string]string{
+	"arch": KeyArchitecture,
+}
+
+// Properties describe a variant of an image in an index, e.g. os=darwin,architecture=arm64,cpu=M2.
+type Properties map[string]string
+
+// Parse parses comma separated key=value pairs.
+func Parse(s string) (Properties, error) {
+	res := Properties{}
+	if strings.TrimSpace(s) == "" {
+		return res, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid variant property '%v', expected key=value", pair)
+		}
+		if alias, ok := aliases[k]; ok {
+			k = alias
+		}
+		res[k] = strings.TrimSpace(v)
+	}
+	return res, nil
+}
+
+// String returns properties as sorted comma separated key=value pairs, as accepted by Parse.
+func (p Properties) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+p[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Equal reports whether both describe the same variant.
+func (p Properties) Equal(other Properties) bool {
+	return len(p) == len(other) && p.Contains(other)
+}
+
+// Contains reports whether all selector properties are present with the same values.
+func (p Properties) Contains(selector Properties) bool {
+	for k, v := range selector {
+		if p[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Platform returns platform part of properties, or nil if there is none.
+func (p Properties) Platform() *v1.Platform {
+	if p[KeyOS] == "" && p[KeyArchitecture] == "" && p[KeyVariant] == "" && p[KeyOSVersion] == "" {
+		return nil
+	}
+	return &v1.Platform{
+		OS:           p[KeyOS],
+		Architecture: p[KeyArchitecture],
+		Variant:      p[KeyVariant],
+		OSVersion:    p[KeyOSVersion],
+	}
+}
+
+// Annotations returns properties which are not part of the platform.
+func (p Properties) Annotations() map[string]string {
+	res := make(map[string]string)
+	for k, v := range p {
+		switch k {
+		case KeyOS, KeyArchitecture, KeyVariant, KeyOSVersion:
+		default:
+			res[k] = v
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// Descriptor returns properties of an index entry.
+func Descriptor(d v1.Descriptor) Properties {
+	res := Properties{}
+	for k, v := range d.Annotations {
+		res[k] = v
+	}
+	if d.Platform != nil {
+		for k, v := range map[string]string{
+			KeyOS:           d.Platform.OS,
+			KeyArchitecture: d.Platform.Architecture,
+			KeyVariant:      d.Platform.Variant,
+			KeyOSVersion:    d.Platform.OSVersion,
+		} {
+			if v != "" {
+				res[k] = v
+			}
+		}
+	}
+	return res
+}
+
+// MatchesHost reports whether variant with given properties can run on the host. Only properties the host
+// knows about are compared: os.version matches the host version or its prefix (14 matches 14.5),
+// and cpu matches a part of the host CPU name (M2 matches 'Apple M2 Pro'); other properties are ignored.
+func (p Properties) MatchesHost(host Properties) bool {
+	for k, want := range p {
+		have, known := host[k]
+		if !known {
+			continue
+		}
+		switch k {
+		case KeyOSVersion:
+			if have != want && !strings.HasPrefix(have, want+".") {
+				return false
+			}
+		case KeyCPU:
+			if !strings.Contains(strings.ToLower(have), strings.ToLower(want)) {
+				return false
+			}
+		default:
+			if have != want {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Select returns the first index entry containing selector properties, or matching the host if selector is empty.
+func Select(index *v1.IndexManifest, selector Properties, host Properties) (v1.Descriptor, error) {
+	available := make([]string, 0, len(index.Manifests))
+	for _, d := range index.Manifests {
+		props := Descriptor(d)
+		if len(selector) > 0 && props.Contains(selector) || len(selector) == 0 && props.MatchesHost(host) {
+			return d, nil
+		}
+		available = append(available, "'"+props.String()+"'")
+	}
+	wanted := "host " + host.String()
+	if len(selector) > 0 {
+		wanted = "'" + selector.String() + "'"
+	}
+	return v1.Descriptor{}, fmt.Errorf("no variant matches %v, available variants: %v", wanted, strings.Join(available, ", "))
+}
@@ -0,0 +1,70 @@
+package variant
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse("os=darwin, arch=arm64,cpu=M2")
+	require.NoError(t, err)
+	assert.Equal(t, Properties{KeyOS: "darwin", KeyArchitecture: "arm64", KeyCPU: "M2"}, p)
+	assert.Equal(t, "architecture=arm64,cpu=M2,os=darwin", p.String())
+
+	p, err = Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, p)
+
+	_, err = Parse("os")
+	assert.Error(t, err)
+	_, err = Parse("os=darwin,=x")
+	assert.Error(t, err)
+}
+
+func TestDescriptor_RoundTrip(t *testing.T) {
+	p := Properties{KeyOS: "darwin", KeyOSVersion: "14.5", KeyCPU: "M1", "org.example.disk": "ssd"}
+	d := v1.Descriptor{Platform: p.Platform(), Annotations: p.Annotations()}
+	assert.Equal(t, &v1.Platform{OS: "darwin", OSVersion: "14.5"}, d.Platform)
+	assert.Equal(t, map[string]string{KeyCPU: "M1", "org.example.disk": "ssd"}, d.Annotations)
+	assert.True(t, Descriptor(d).Equal(p))
+
+	assert.Nil(t, Properties{KeyCPU: "M1"}.Platform())
+	assert.Nil(t, Properties{KeyOS: "darwin"}.Annotations())
+}
+
+func TestMatchesHost(t *testing.T) {
+	host := Properties{KeyOS: "darwin", KeyArchitecture: "arm64", KeyOSVersion: "14.5", KeyCPU: "Apple M2 Pro"}
+	assert.True(t, Properties{}.MatchesHost(host))
+	assert.True(t, Properties{KeyOS: "darwin", KeyArchitecture: "arm64"}.MatchesHost(host))
+	assert.True(t, Properties{KeyOSVersion: "14"}.MatchesHost(host))
+	assert.True(t, Properties{KeyOSVersion: "14.5"}.MatchesHost(host))
+	assert.False(t, Properties{KeyOSVersion: "14.4"}.MatchesHost(host))
+	assert.False(t, Properties{KeyOSVersion: "1"}.MatchesHost(host))
+	assert.True(t, Properties{KeyCPU: "m2"}.MatchesHost(host))
+	assert.False(t, Properties{KeyCPU: "M1"}.MatchesHost(host))
+	assert.False(t, Properties{KeyArchitecture: "amd64"}.MatchesHost(host))
+	assert.True(t, Properties{"org.example.disk": "ssd"}.MatchesHost(host))
+}
+
+func TestSelect(t *testing.T) {
+	m1 := v1.Descriptor{Digest: v1.Hash{Algorithm: "sha256", Hex: "01"}, Platform: &v1.Platform{OS: "darwin", Architecture: "arm64"}, Annotations: map[string]string{KeyCPU: "M1"}}
+	m2 := v1.Descriptor{Digest: v1.Hash{Algorithm: "sha256", Hex: "02"}, Platform: &v1.Platform{OS: "darwin", Architecture: "arm64"}, Annotations: map[string]string{KeyCPU: "M2"}}
+	index := &v1.IndexManifest{Manifests: []v1.Descriptor{m1, m2}}
+
+	d, err := Select(index, nil, Properties{KeyOS: "darwin", KeyArchitecture: "arm64", KeyCPU: "Apple M2"})
+	require.NoError(t, err)
+	assert.Equal(t, m2.Digest, d.Digest)
+
+	d, err = Select(index, Properties{KeyCPU: "M1"}, Properties{KeyOS: "linux"})
+	require.NoError(t, err)
+	assert.Equal(t, m1.Digest, d.Digest)
+
+	_, err = Select(index, Properties{KeyCPU: "M3"}, nil)
+	assert.ErrorContains(t, err, "no variant matches 'cpu=M3'")
+	assert.ErrorContains(t, err, "'architecture=arm64,cpu=M1,os=darwin'")
+
+	_, err = Select(index, nil, Properties{KeyOS: "linux"})
+	assert.ErrorContains(t, err, "no variant matches host os=linux")
+}